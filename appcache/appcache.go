@@ -0,0 +1,200 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package appcache
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	cacheFlushInterval = 10 * time.Second
+	defaultTTL         = 5 * time.Minute
+)
+
+// rep emits every container gauge with at least these metrics
+var containerMetricNames = []string{"cpu", "memory", "disk"}
+
+//AppCache keeps the latest container metrics for every application instance
+type AppCache struct {
+	sync.RWMutex
+	ttl    time.Duration
+	logger *gosteno.Logger
+	apps   map[string]map[string]*Instance
+}
+
+//App is an application and the container metrics of its instances
+type App struct {
+	AppID     string
+	Instances []*Instance
+}
+
+//Instance holds the latest container metrics and quotas of an application instance
+type Instance struct {
+	InstanceID string
+	Timestamp  int64
+	Metrics    map[string]float64
+	expires    time.Time
+}
+
+var instance *AppCache
+var once sync.Once
+
+//GetInstance retrieves the singleton cache
+func GetInstance() *AppCache {
+	return instance
+}
+
+func CreateInstance(logger *gosteno.Logger, ttl time.Duration) {
+	once.Do(func() {
+		if logger == nil {
+			panic("App cache initialized without logger")
+		}
+		instance = createAppCache(logger, ttl)
+	})
+}
+
+//UpdateContainerMetrics stores rep container gauges, it returns false for any other envelope
+func (c *AppCache) UpdateContainerMetrics(e *loggregator_v2.Envelope) bool {
+	if !isContainerMetric(e) {
+		return false
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	appID, instanceID := e.GetSourceId(), e.GetInstanceId()
+	app, ok := c.apps[appID]
+	if !ok {
+		app = make(map[string]*Instance)
+		c.apps[appID] = app
+	}
+
+	i, ok := app[instanceID]
+	if !ok {
+		i = &Instance{
+			InstanceID: instanceID,
+			Metrics:    make(map[string]float64),
+		}
+		app[instanceID] = i
+	}
+
+	for k, v := range e.GetGauge().GetMetrics() {
+		i.Metrics[k] = v.GetValue()
+	}
+	i.Timestamp = e.GetTimestamp()
+	i.expires = time.Now().Add(c.ttl)
+
+	c.logger.Debugf("Adding container metrics for app %s instance %s", appID, instanceID)
+	return true
+}
+
+//GetApp returns a single application with its instances
+func (c *AppCache) GetApp(appID string) (app *App, found bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	instances, found := c.apps[appID]
+	if !found {
+		return nil, false
+	}
+	return newApp(appID, instances), true
+}
+
+//GetApps returns every application with its instances
+func (c *AppCache) GetApps() []*App {
+	c.RLock()
+	defer c.RUnlock()
+
+	apps := make([]*App, 0, len(c.apps))
+	for appID, instances := range c.apps {
+		apps = append(apps, newApp(appID, instances))
+	}
+	return apps
+}
+
+// private utility func, public methods using it are expected to have mutex RLock minimum
+func newApp(appID string, instances map[string]*Instance) *App {
+	app := &App{
+		AppID:     appID,
+		Instances: make([]*Instance, 0, len(instances)),
+	}
+
+	for _, i := range instances {
+		metrics := make(map[string]float64, len(i.Metrics))
+		for k, v := range i.Metrics {
+			metrics[k] = v
+		}
+
+		app.Instances = append(app.Instances, &Instance{
+			InstanceID: i.InstanceID,
+			Timestamp:  i.Timestamp,
+			Metrics:    metrics,
+		})
+	}
+	return app
+}
+
+func (c *AppCache) cleanup() {
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+	for appID, instances := range c.apps {
+		for instanceID, i := range instances {
+			if i.expires.Before(now) {
+				delete(instances, instanceID)
+			}
+		}
+
+		if len(instances) == 0 {
+			delete(c.apps, appID)
+		}
+	}
+}
+
+func (c *AppCache) startCleanupTimer() {
+	ticker := time.Tick(cacheFlushInterval)
+	go (func() {
+		for {
+			select {
+			case <-ticker:
+				c.cleanup()
+			}
+		}
+	})()
+}
+
+func createAppCache(logger *gosteno.Logger, ttl time.Duration) *AppCache {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	c := &AppCache{
+		ttl:    ttl,
+		logger: logger,
+		apps:   make(map[string]map[string]*Instance),
+	}
+	c.logger.Info("Built App Cache")
+
+	c.startCleanupTimer()
+	return c
+}
+
+func isContainerMetric(e *loggregator_v2.Envelope) bool {
+	g := e.GetGauge()
+	if g == nil || e.GetSourceId() == "" || e.GetInstanceId() == "" {
+		return false
+	}
+
+	for _, name := range containerMetricNames {
+		if _, ok := g.GetMetrics()[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
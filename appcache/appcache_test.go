@@ -0,0 +1,182 @@
+package appcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/logger"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultLogDirectory = "../logs"
+	cacheLogFile        = "appcache.log"
+	cacheLogName        = "appcache"
+	cacheLogLevel       = "debug"
+)
+
+var testLogger *gosteno.Logger
+
+func GetTestLogger() *gosteno.Logger {
+	if testLogger == nil {
+		logger.CreateLogDirectory(defaultLogDirectory)
+		testLogger = logger.New(defaultLogDirectory, cacheLogFile, cacheLogName, cacheLogLevel)
+	}
+
+	return testLogger
+}
+
+func TestCreateAppCache(t *testing.T) {
+	testCases := []struct {
+		testName string
+		ttl      time.Duration
+		want     time.Duration
+	}{
+		{testName: "Default TTL", ttl: 0, want: defaultTTL},
+		{testName: "Configured TTL", ttl: time.Minute, want: time.Minute},
+	}
+
+	for _, tc := range testCases {
+		cache := createAppCache(GetTestLogger(), tc.ttl)
+
+		if cache.ttl != tc.want || cache.apps == nil {
+			t.Errorf("Test Case %s returned ttl %v expected %v", tc.testName, cache.ttl, tc.want)
+		}
+	}
+}
+
+func TestIsContainerMetric(t *testing.T) {
+	testCases := []struct {
+		testName string
+		envelope *loggregator_v2.Envelope
+		want     bool
+	}{
+		{
+			testName: "Container Metric",
+			envelope: newContainerEnvelope("app", "0", 10),
+			want:     true,
+		},
+		{
+			testName: "Missing Instance ID",
+			envelope: newContainerEnvelope("app", "", 10),
+			want:     false,
+		},
+		{
+			testName: "Rep Gauge",
+			envelope: &loggregator_v2.Envelope{
+				SourceId:   "rep",
+				InstanceId: "0",
+				Message: &loggregator_v2.Envelope_Gauge{
+					Gauge: &loggregator_v2.Gauge{
+						Metrics: map[string]*loggregator_v2.GaugeValue{
+							"CapacityRemainingMemory": &loggregator_v2.GaugeValue{Unit: "MiB", Value: 1024},
+						},
+					},
+				},
+			},
+			want: false,
+		},
+		{
+			testName: "Counter",
+			envelope: &loggregator_v2.Envelope{
+				SourceId:   "app",
+				InstanceId: "0",
+				Message: &loggregator_v2.Envelope_Counter{
+					Counter: &loggregator_v2.Counter{Name: "cpu", Total: 1},
+				},
+			},
+			want: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		if got := isContainerMetric(tc.envelope); got != tc.want {
+			t.Errorf("Test Case %s returned %v expected %v", tc.testName, got, tc.want)
+		}
+	}
+}
+
+func TestUpdateContainerMetrics(t *testing.T) {
+	cache := createAppCache(GetTestLogger(), time.Minute)
+
+	if !cache.UpdateContainerMetrics(newContainerEnvelope("app", "0", 10)) {
+		t.Error("Container metric was not stored")
+	}
+	cache.UpdateContainerMetrics(newContainerEnvelope("app", "1", 20))
+	cache.UpdateContainerMetrics(newContainerEnvelope("app", "0", 30))
+	cache.UpdateContainerMetrics(newContainerEnvelope("other-app", "0", 40))
+
+	app, found := cache.GetApp("app")
+	if !found {
+		t.Fatal("App not found")
+	}
+
+	if len(app.Instances) != 2 {
+		t.Fatalf("Expected 2 instances, got %d", len(app.Instances))
+	}
+
+	for _, i := range app.Instances {
+		want := float64(30)
+		if i.InstanceID == "1" {
+			want = 20
+		}
+
+		if i.Metrics["cpu"] != want || i.Metrics["memory_quota"] != 1024 {
+			t.Errorf("Instance %s has metrics %v expected cpu %f", i.InstanceID, i.Metrics, want)
+		}
+	}
+
+	if apps := cache.GetApps(); len(apps) != 2 {
+		t.Errorf("Expected 2 apps, got %d", len(apps))
+	}
+
+	if _, found := cache.GetApp("missing"); found {
+		t.Error("Found app that didn't exist")
+	}
+}
+
+func TestAppCacheCleanup(t *testing.T) {
+	cache := createAppCache(GetTestLogger(), time.Second)
+
+	cache.UpdateContainerMetrics(newContainerEnvelope("app", "0", 10))
+	cache.cleanup()
+
+	if len(cache.apps) != 1 {
+		t.Error("Cache cleaned up before expiration")
+	}
+
+	time.Sleep(2 * time.Second)
+	cache.cleanup()
+
+	if len(cache.apps) != 0 {
+		t.Error("Failed to fully clean out cache after expiration")
+	}
+}
+
+func newContainerEnvelope(appID, instanceID string, cpu float64) *loggregator_v2.Envelope {
+	return &loggregator_v2.Envelope{
+		Timestamp:  time.Now().UnixNano(),
+		SourceId:   appID,
+		InstanceId: instanceID,
+		Tags: map[string]string{
+			"origin":     "rep",
+			"deployment": "cf",
+			"job":        "diego_cell",
+			"index":      "0",
+			"ip":         "10.0.0.1",
+		},
+		Message: &loggregator_v2.Envelope_Gauge{
+			Gauge: &loggregator_v2.Gauge{
+				Metrics: map[string]*loggregator_v2.GaugeValue{
+					"cpu":          &loggregator_v2.GaugeValue{Unit: "percentage", Value: cpu},
+					"memory":       &loggregator_v2.GaugeValue{Unit: "bytes", Value: 512},
+					"disk":         &loggregator_v2.GaugeValue{Unit: "bytes", Value: 256},
+					"memory_quota": &loggregator_v2.GaugeValue{Unit: "bytes", Value: 1024},
+					"disk_quota":   &loggregator_v2.GaugeValue{Unit: "bytes", Value: 1024},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package applatency
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultWindow = 60 * time.Second
+
+	//maxSamplesPerBucket bounds the durations kept per second for percentile calculation, busier seconds keep a random sample
+	maxSamplesPerBucket = 1000
+
+	goRouterOrigin = "gorouter"
+	httpTimerName  = "http"
+)
+
+//AppLatencyCache aggregates gorouter http timers per application over a sliding window
+type AppLatencyCache struct {
+	sync.RWMutex
+	window time.Duration
+	logger *gosteno.Logger
+	apps   map[string]*appWindow
+	now    func() time.Time
+}
+
+//AppLatency is the summary of an application's requests over the window
+type AppLatency struct {
+	AppID         string            `json:"app_id"`
+	WindowSeconds uint32            `json:"window_seconds"`
+	Requests      uint64            `json:"requests"`
+	StatusCodes   map[string]uint64 `json:"status_codes"`
+	Latency       LatencyStats      `json:"latency"`
+}
+
+//LatencyStats holds request latency statistics in nanoseconds
+type LatencyStats struct {
+	Mean int64 `json:"mean"`
+	P50  int64 `json:"p50"`
+	P90  int64 `json:"p90"`
+	P95  int64 `json:"p95"`
+	P99  int64 `json:"p99"`
+	Max  int64 `json:"max"`
+}
+
+var instance *AppLatencyCache
+var once sync.Once
+
+//GetInstance retrieves the singleton cache
+func GetInstance() *AppLatencyCache {
+	return instance
+}
+
+func CreateInstance(logger *gosteno.Logger, window time.Duration) {
+	once.Do(func() {
+		if logger == nil {
+			panic("App latency cache initialized without logger")
+		}
+		instance = createAppLatencyCache(logger, window)
+	})
+}
+
+//UpdateTimer records gorouter http timers, all other envelopes are ignored
+func (c *AppLatencyCache) UpdateTimer(e *loggregator_v2.Envelope) {
+	tm := e.GetTimer()
+	if tm == nil || tm.GetName() != httpTimerName || e.Tags["origin"] != goRouterOrigin {
+		return
+	}
+
+	appID := getAppID(e)
+	if appID == "" {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	w, ok := c.apps[appID]
+	if !ok {
+		w = newAppWindow(c.window)
+		c.apps[appID] = w
+	}
+
+	w.add(c.now(), tm.GetStop()-tm.GetStart(), statusClass(e.Tags["status_code"]))
+	c.logger.Debugf("Adding http timer for app %s, Duration %d", appID, tm.GetStop()-tm.GetStart())
+}
+
+//GetApp returns the summary for a single application
+func (c *AppLatencyCache) GetApp(appID string) (latency *AppLatency, found bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	w, ok := c.apps[appID]
+	if !ok {
+		return nil, false
+	}
+
+	latency = w.summarize(appID, c.now())
+	if latency.Requests == 0 {
+		return nil, false
+	}
+	return latency, true
+}
+
+//GetApps returns the summaries of every application with requests in the window
+func (c *AppLatencyCache) GetApps() []*AppLatency {
+	c.RLock()
+	defer c.RUnlock()
+
+	now := c.now()
+	latencies := make([]*AppLatency, 0, len(c.apps))
+	for appID, w := range c.apps {
+		if latency := w.summarize(appID, now); latency.Requests > 0 {
+			latencies = append(latencies, latency)
+		}
+	}
+	return latencies
+}
+
+func (c *AppLatencyCache) cleanup() {
+	c.Lock()
+	defer c.Unlock()
+
+	now := c.now()
+	for appID, w := range c.apps {
+		if w.isEmpty(now) {
+			delete(c.apps, appID)
+		}
+	}
+}
+
+func (c *AppLatencyCache) startCleanupTimer() {
+	ticker := time.Tick(c.window)
+	go (func() {
+		for {
+			select {
+			case <-ticker:
+				c.cleanup()
+			}
+		}
+	})()
+}
+
+func createAppLatencyCache(logger *gosteno.Logger, window time.Duration) *AppLatencyCache {
+	if window < time.Second {
+		window = defaultWindow
+	}
+
+	c := &AppLatencyCache{
+		window: window,
+		logger: logger,
+		apps:   make(map[string]*appWindow),
+		now:    time.Now,
+	}
+	c.logger.Info("Built App Latency Cache")
+
+	c.startCleanupTimer()
+	return c
+}
+
+// gorouter tags http timers with app_id, older versions only set the source id
+func getAppID(e *loggregator_v2.Envelope) string {
+	if appID := e.Tags["app_id"]; appID != "" {
+		return appID
+	}
+	return e.GetSourceId()
+}
+
+func statusClass(statusCode string) string {
+	if len(statusCode) != 3 || statusCode[0] < '1' || statusCode[0] > '5' {
+		return "unknown"
+	}
+	return statusCode[:1] + "xx"
+}
+
+//bucket holds the requests received during a single second
+type bucket struct {
+	second        int64
+	requests      uint64
+	durationSum   int64
+	maxDuration   int64
+	statusClasses map[string]uint64
+	durations     []int64
+}
+
+//appWindow is a ring of one second buckets covering the window
+type appWindow struct {
+	buckets []bucket
+}
+
+func newAppWindow(window time.Duration) *appWindow {
+	return &appWindow{
+		buckets: make([]bucket, int(window/time.Second)),
+	}
+}
+
+func (w *appWindow) add(now time.Time, duration int64, class string) {
+	second := now.Unix()
+	b := &w.buckets[second%int64(len(w.buckets))]
+	if b.second != second {
+		*b = bucket{
+			second:        second,
+			statusClasses: make(map[string]uint64),
+		}
+	}
+
+	b.requests++
+	b.durationSum += duration
+	if duration > b.maxDuration {
+		b.maxDuration = duration
+	}
+	b.statusClasses[class]++
+	// reservoir sampling gives every request of the second the same chance to be kept
+	if len(b.durations) < maxSamplesPerBucket {
+		b.durations = append(b.durations, duration)
+	} else if i := rand.Int63n(int64(b.requests)); i < maxSamplesPerBucket {
+		b.durations[i] = duration
+	}
+}
+
+func (w *appWindow) inWindow(b *bucket, now time.Time) bool {
+	return b.requests > 0 && now.Unix()-b.second < int64(len(w.buckets))
+}
+
+func (w *appWindow) isEmpty(now time.Time) bool {
+	for i := range w.buckets {
+		if w.inWindow(&w.buckets[i], now) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *appWindow) summarize(appID string, now time.Time) *AppLatency {
+	latency := &AppLatency{
+		AppID:         appID,
+		WindowSeconds: uint32(len(w.buckets)),
+		StatusCodes:   make(map[string]uint64),
+	}
+
+	var durationSum, maxDuration int64
+	var samples []sample
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if !w.inWindow(b, now) {
+			continue
+		}
+
+		latency.Requests += b.requests
+		durationSum += b.durationSum
+		if b.maxDuration > maxDuration {
+			maxDuration = b.maxDuration
+		}
+		for class, count := range b.statusClasses {
+			latency.StatusCodes[class] += count
+		}
+		// a sampled bucket's durations stand for all of its requests so busy seconds are not under counted
+		weight := float64(b.requests) / float64(len(b.durations))
+		for _, d := range b.durations {
+			samples = append(samples, sample{duration: d, weight: weight})
+		}
+	}
+
+	if latency.Requests == 0 || len(samples) == 0 {
+		return latency
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i].duration < samples[j].duration })
+	total := float64(latency.Requests)
+	latency.Latency = LatencyStats{
+		Mean: durationSum / int64(latency.Requests),
+		P50:  percentile(samples, total, 50),
+		P90:  percentile(samples, total, 90),
+		P95:  percentile(samples, total, 95),
+		P99:  percentile(samples, total, 99),
+		Max:  maxDuration,
+	}
+	return latency
+}
+
+//sample is a sampled duration and the number of requests it stands for
+type sample struct {
+	duration int64
+	weight   float64
+}
+
+//percentile uses the nearest rank method on samples sorted by duration, weighted by the requests they stand for
+func percentile(sorted []sample, total float64, p int) int64 {
+	rank := float64(p) * total / 100
+	seen := 0.0
+	for _, s := range sorted {
+		seen += s.weight
+		if seen >= rank {
+			return s.duration
+		}
+	}
+	return sorted[len(sorted)-1].duration
+}
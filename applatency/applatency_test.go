@@ -0,0 +1,222 @@
+package applatency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/logger"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultLogDirectory = "../logs"
+	cacheLogFile        = "applatency.log"
+	cacheLogName        = "applatency"
+	cacheLogLevel       = "debug"
+)
+
+var testLogger *gosteno.Logger
+
+func GetTestLogger() *gosteno.Logger {
+	if testLogger == nil {
+		logger.CreateLogDirectory(defaultLogDirectory)
+		testLogger = logger.New(defaultLogDirectory, cacheLogFile, cacheLogName, cacheLogLevel)
+	}
+
+	return testLogger
+}
+
+func TestCreateAppLatencyCache(t *testing.T) {
+	testCases := []struct {
+		testName string
+		window   time.Duration
+		want     time.Duration
+	}{
+		{testName: "Default Window", window: 0, want: defaultWindow},
+		{testName: "Configured Window", window: 30 * time.Second, want: 30 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		cache := createAppLatencyCache(GetTestLogger(), tc.window)
+
+		if cache.window != tc.want || cache.apps == nil {
+			t.Errorf("Test Case %s returned window %v expected %v", tc.testName, cache.window, tc.want)
+		}
+	}
+}
+
+func TestUpdateTimerIgnoresOtherEnvelopes(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := newTestCache(&now)
+
+	cache.UpdateTimer(newTimerEnvelope("bbs", "http", "app", "200", 10))
+	cache.UpdateTimer(newTimerEnvelope(goRouterOrigin, "other", "app", "200", 10))
+	cache.UpdateTimer(newTimerEnvelope(goRouterOrigin, "http", "", "200", 10))
+	cache.UpdateTimer(&loggregator_v2.Envelope{
+		Tags: map[string]string{"origin": goRouterOrigin},
+		Message: &loggregator_v2.Envelope_Counter{
+			Counter: &loggregator_v2.Counter{Name: "http", Total: 1},
+		},
+	})
+
+	if len(cache.apps) != 0 {
+		t.Errorf("Expected no apps to be tracked, got %d", len(cache.apps))
+	}
+}
+
+func TestAppLatencySummary(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := newTestCache(&now)
+
+	for i := int64(1); i <= 100; i++ {
+		status := "200"
+		if i > 95 {
+			status = "503"
+		}
+		cache.UpdateTimer(newTimerEnvelope(goRouterOrigin, "http", "app", status, i))
+	}
+	cache.UpdateTimer(newTimerEnvelope(goRouterOrigin, "http", "other-app", "404", 5))
+
+	latency, found := cache.GetApp("app")
+	if !found {
+		t.Fatal("App latency not found")
+	}
+
+	if latency.Requests != 100 {
+		t.Errorf("Expected 100 requests, got %d", latency.Requests)
+	}
+
+	if latency.StatusCodes["2xx"] != 95 || latency.StatusCodes["5xx"] != 5 {
+		t.Errorf("Unexpected status code counts %v", latency.StatusCodes)
+	}
+
+	want := LatencyStats{Mean: 50, P50: 50, P90: 90, P95: 95, P99: 99, Max: 100}
+	if latency.Latency != want {
+		t.Errorf("Expected latency %v, got %v", want, latency.Latency)
+	}
+
+	if apps := cache.GetApps(); len(apps) != 2 {
+		t.Errorf("Expected 2 apps, got %d", len(apps))
+	}
+}
+
+func TestAppLatencySampling(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := newTestCache(&now)
+
+	t.Log("Checking requests after the first samples of a second can be sampled...")
+	total := 10 * maxSamplesPerBucket
+	for i := 1; i <= total; i++ {
+		cache.UpdateTimer(newTimerEnvelope(goRouterOrigin, "http", "app", "200", int64(i)))
+	}
+
+	b := &cache.apps["app"].buckets[now.Unix()%10]
+	if len(b.durations) != maxSamplesPerBucket {
+		t.Fatalf("Expected %d samples, got %d", maxSamplesPerBucket, len(b.durations))
+	}
+
+	late := 0
+	for _, d := range b.durations {
+		if d > maxSamplesPerBucket {
+			late++
+		}
+	}
+	// 90% of the requests came after the first samples, so close to 900 samples should be from them
+	if late < 800 {
+		t.Errorf("Expected most samples from the later requests, got %d", late)
+	}
+
+	latency, _ := cache.GetApp("app")
+	if p50 := latency.Latency.P50; p50 < 4000 || p50 > 6000 {
+		t.Errorf("Expected a median near %d, got %d", total/2, p50)
+	}
+
+	t.Log("Checking a busy second is weighted by its requests rather than its samples...")
+	now = now.Add(time.Second)
+	for i := 0; i < 100; i++ {
+		cache.UpdateTimer(newTimerEnvelope(goRouterOrigin, "http", "app", "200", 1))
+	}
+
+	latency, _ = cache.GetApp("app")
+	if p50 := latency.Latency.P50; p50 < 4000 {
+		t.Errorf("Expected the median to stay with the busy second, got %d", p50)
+	}
+}
+
+func TestAppLatencyWindowExpiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := newTestCache(&now)
+
+	cache.UpdateTimer(newTimerEnvelope(goRouterOrigin, "http", "app", "200", 10))
+
+	now = now.Add(5 * time.Second)
+	cache.UpdateTimer(newTimerEnvelope(goRouterOrigin, "http", "app", "200", 20))
+
+	if latency, _ := cache.GetApp("app"); latency.Requests != 2 {
+		t.Errorf("Expected 2 requests in window, got %d", latency.Requests)
+	}
+
+	//Move past the first request but not the second
+	now = now.Add(6 * time.Second)
+	if latency, _ := cache.GetApp("app"); latency.Requests != 1 || latency.Latency.Max != 20 {
+		t.Errorf("Expected only the newest request in window, got %v", latency)
+	}
+
+	now = now.Add(time.Minute)
+	if _, found := cache.GetApp("app"); found {
+		t.Error("Found app after its window expired")
+	}
+
+	cache.cleanup()
+	if len(cache.apps) != 0 {
+		t.Error("Failed to clean out expired apps")
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	testCases := map[string]string{
+		"200": "2xx",
+		"302": "3xx",
+		"404": "4xx",
+		"502": "5xx",
+		"":    "unknown",
+		"abc": "unknown",
+	}
+
+	for input, want := range testCases {
+		if got := statusClass(input); got != want {
+			t.Errorf("Status code %s returned %s expected %s", input, got, want)
+		}
+	}
+}
+
+// newTestCache builds a cache with a ten second window whose clock is read from now
+func newTestCache(now *time.Time) *AppLatencyCache {
+	cache := &AppLatencyCache{
+		window: 10 * time.Second,
+		logger: GetTestLogger(),
+		apps:   make(map[string]*appWindow),
+	}
+	cache.now = func() time.Time { return *now }
+	return cache
+}
+
+func newTimerEnvelope(origin, name, appID, status string, duration int64) *loggregator_v2.Envelope {
+	return &loggregator_v2.Envelope{
+		SourceId: appID,
+		Tags: map[string]string{
+			"origin":      origin,
+			"app_id":      appID,
+			"status_code": status,
+		},
+		Message: &loggregator_v2.Envelope_Timer{
+			Timer: &loggregator_v2.Timer{
+				Name:  name,
+				Start: 1000,
+				Stop:  1000 + duration,
+			},
+		},
+	}
+}
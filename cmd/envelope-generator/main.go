@@ -0,0 +1,62 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"strings"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/generator"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/logger"
+)
+
+const (
+	defaultLogDirectory = "./logs/envelope-generator"
+	generatorLogFile    = "bm_generator.log"
+	generatorLogName    = "bm_generator"
+	generatorLogLevel   = "info"
+)
+
+var (
+	listen      = flag.String("listen", ":8088", "Address to serve the fake RLP gateway /v2/read endpoint on")
+	deployments = flag.Uint("deployments", 1, "Number of bosh deployments to generate envelopes for")
+	instances   = flag.Uint("instances", 2, "Number of instances of each origin in every deployment")
+	metrics     = flag.Uint("metrics", 10, "Number of metrics each instance emits")
+	apps        = flag.Uint("apps", 10, "Number of apps gorouter http timers are spread across")
+	rate        = flag.Uint("rate", 1000, "Envelopes generated per second, shared by all connected streams")
+	origins     = flag.String("origins", "", "Comma separated origins to generate, defaults to every origin the nozzle serves")
+	logLevel    = flag.String("log-level", generatorLogLevel, "Set log level to control verbosity - defaults to info")
+)
+
+//envelope-generator serves synthetic envelopes from a fake RLP gateway so the nozzle can be load and soak tested without a foundation
+func main() {
+	flag.Parse()
+	logger.CreateLogDirectory(defaultLogDirectory)
+	l := logger.New(defaultLogDirectory, generatorLogFile, generatorLogName, *logLevel)
+
+	c := &configuration.Configuration{
+		GeneratorDeployments: uint32(*deployments),
+		GeneratorInstances:   uint32(*instances),
+		GeneratorMetrics:     uint32(*metrics),
+		GeneratorApps:        uint32(*apps),
+		GeneratorRate:        uint32(*rate),
+	}
+	if *origins != "" {
+		for _, o := range strings.Split(*origins, ",") {
+			c.GeneratorOrigins = append(c.GeneratorOrigins, strings.TrimSpace(o))
+		}
+	}
+
+	g := generator.New(c, l)
+	g.Start(context.Background())
+
+	http.Handle("/v2/read", g.Handler())
+	l.Infof("Serving generated envelopes on %s/v2/read", *listen)
+	if err := http.ListenAndServe(*listen, nil); err != nil {
+		l.Fatalf("Error serving generated envelopes: %s", err.Error())
+	}
+}
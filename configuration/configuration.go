@@ -12,14 +12,36 @@ import (
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 const (
 	uaaURLEnv                     = "UAA_HOST"
 	uaaUsernameEnv                = "BM_UAA_USERNAME"
 	uaaPasswordEnv                = "BM_UAA_PASSWORD"
+	uaaGrantTypeEnv               = "BM_UAA_GRANT_TYPE"
+	uaaClientIDEnv                = "BM_UAA_CLIENT_ID"
+	uaaClientSecretEnv            = "BM_UAA_CLIENT_SECRET"
 	cloudControllerURLEnv         = "CC_HOST"
 	rlpUrlEnv                     = "RLP_URL"
+	trafficControllerURLEnv       = "BM_TRAFFIC_CONTROLLER_URL"
+	inputModeEnv                  = "BM_INPUT_MODE"
+	replayFileEnv                 = "BM_REPLAY_FILE"
+	replaySpeedEnv                = "BM_REPLAY_SPEED"
+	replayLoopEnv                 = "BM_REPLAY_LOOP"
+	recordDirectoryEnv            = "BM_RECORD_DIRECTORY"
+	recordFormatEnv               = "BM_RECORD_FORMAT"
+	recordOriginAllowListEnv      = "BM_RECORD_ORIGIN_ALLOW_LIST"
+	recordMaxFileSizeMBEnv        = "BM_RECORD_MAX_FILE_SIZE_MB"
+	recordRotateSecondsEnv        = "BM_RECORD_ROTATE_SECONDS"
+	recordMaxDiskMBEnv            = "BM_RECORD_MAX_DISK_MB"
+	recordDurationSecondsEnv      = "BM_RECORD_DURATION_SECONDS"
+	generatorDeploymentsEnv       = "BM_GENERATOR_DEPLOYMENTS"
+	generatorInstancesEnv         = "BM_GENERATOR_INSTANCES"
+	generatorMetricsEnv           = "BM_GENERATOR_METRICS"
+	generatorAppsEnv              = "BM_GENERATOR_APPS"
+	generatorRateEnv              = "BM_GENERATOR_RATE"
+	generatorOriginsEnv           = "BM_GENERATOR_ORIGINS"
 	subscriptionIDEnv             = "BM_SUBSCRIPTION_ID"
 	disableAccessControlEnv       = "BM_DISABLE_ACCESS_CONTROL"
 	insecureSSLSkipVerifyEnv      = "BM_INSECURE_SSL_SKIP_VERIFY"
@@ -29,6 +51,74 @@ const (
 	webServerUseSSLENV            = "BM_WEBSERVER_USE_SSL"
 	webServerCertLocation         = "BM_WEBSERVER_CERT_LOCATION"
 	webServerKeyLocation          = "BM_WEBSERVER_KEY_LOCATION"
+	appLatencyWindowSecondsEnv    = "BM_APP_LATENCY_WINDOW_SECONDS"
+	eventLogSizeEnv               = "BM_EVENT_LOG_SIZE"
+	maxReconnectAttemptsEnv       = "BM_MAX_RECONNECT_ATTEMPTS"
+	maxReconnectDelaySecondsEnv   = "BM_MAX_RECONNECT_DELAY_SECONDS"
+	backpressurePolicyEnv         = "BM_BACKPRESSURE_POLICY"
+	messageBufferSizeEnv          = "BM_MESSAGE_BUFFER_SIZE"
+	streamCountEnv                = "BM_STREAM_COUNT"
+	readinessTimeoutSecondsEnv    = "BM_READINESS_TIMEOUT_SECONDS"
+	prometheusAuthEnv             = "BM_PROMETHEUS_AUTH"
+	prometheusUsernameEnv         = "BM_PROMETHEUS_USERNAME"
+	prometheusPasswordEnv         = "BM_PROMETHEUS_PASSWORD"
+	prometheusBearerTokenEnv      = "BM_PROMETHEUS_BEARER_TOKEN"
+	graphiteAddressEnv            = "BM_GRAPHITE_ADDRESS"
+	graphitePathTemplateEnv       = "BM_GRAPHITE_PATH_TEMPLATE"
+	graphiteFlushSecondsEnv       = "BM_GRAPHITE_FLUSH_SECONDS"
+	statsdAddressEnv              = "BM_STATSD_ADDRESS"
+	statsdModeEnv                 = "BM_STATSD_MODE"
+	statsdPrefixEnv               = "BM_STATSD_PREFIX"
+	sourceIDAllowListEnv          = "BM_SOURCE_ID_ALLOW_LIST"
+	sourceIDDenyListEnv           = "BM_SOURCE_ID_DENY_LIST"
+	originAllowListEnv            = "BM_ORIGIN_ALLOW_LIST"
+	originDenyListEnv             = "BM_ORIGIN_DENY_LIST"
+	caCertLocationEnv             = "BM_CA_CERT_LOCATION"
+	clientCertLocationEnv         = "BM_CLIENT_CERT_LOCATION"
+	clientKeyLocationEnv          = "BM_CLIENT_KEY_LOCATION"
+	proxyURLEnv                   = "BM_PROXY_URL"
+	proxyUsernameEnv              = "BM_PROXY_USERNAME"
+	proxyPasswordEnv              = "BM_PROXY_PASSWORD"
+)
+
+//UAA grant types the nozzle can authenticate with
+const (
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypePassword          = "password"
+)
+
+//Inputs the nozzle can read envelopes from
+const (
+	InputModeRLP       = "rlp"
+	InputModeFirehose  = "firehose"
+	InputModeReplay    = "replay"
+	InputModeGenerator = "generator"
+)
+
+//Formats of envelope recording and replay files
+const (
+	RecordFormatJSONL    = "jsonl"
+	RecordFormatProtobuf = "protobuf"
+)
+
+//Policies for envelopes read while the message buffer is full
+const (
+	BackpressureBlock      = "block"
+	BackpressureDropNewest = "drop_newest"
+	BackpressureDropOldest = "drop_oldest"
+)
+
+//Authentication of the Prometheus /metrics endpoint
+const (
+	PrometheusAuthBasic  = "basic"
+	PrometheusAuthBearer = "bearer"
+	PrometheusAuthNone   = "none"
+)
+
+//Protocols the StatsD exporter can send
+const (
+	StatsdModeStatsd    = "statsd"
+	StatsdModeDogStatsd = "dogstatsd"
 )
 
 //NozzleConfiguration represents configuration file
@@ -36,16 +126,65 @@ type Configuration struct {
 	UAAURL                     string
 	UAAUsername                string
 	UAAPassword                string
+	UAAGrantType               string
+	UAAClientID                string
+	UAAClientSecret            string
 	RLPURL                     string
+	TrafficControllerURL       string
+	InputMode                  string
+	ReplayFile                 string
+	ReplaySpeed                float64
+	ReplayLoop                 bool
+	RecordDirectory            string
+	RecordFormat               string
+	RecordOriginAllowList      []string
+	RecordMaxFileSizeMB        uint32
+	RecordRotateSeconds        uint32
+	RecordMaxDiskMB            uint32
+	RecordDurationSeconds      uint32
+	GeneratorDeployments       uint32
+	GeneratorInstances         uint32
+	GeneratorMetrics           uint32
+	GeneratorApps              uint32
+	GeneratorRate              uint32
+	GeneratorOrigins           []string
 	SubscriptionID             string
 	DisableAccessControl       bool
 	InsecureSSLSkipVerify      bool
+	CACertLocation             string
+	ClientCertLocation         string
+	ClientKeyLocation          string
+	ProxyURL                   string
+	ProxyUsername              string
+	ProxyPassword              string
 	IdleTimeoutSeconds         uint32
 	MetricCacheDurationSeconds uint32
 	WebServerPort              uint32
 	WebServerUseSSL            bool
 	WebServerCertLocation      string
 	WebServerKeyLocation       string
+	AppLatencyWindowSeconds    uint32
+	EventLogSize               uint32
+	MaxReconnectAttempts       uint32
+	MaxReconnectDelaySeconds   uint32
+	BackpressurePolicy         string
+	MessageBufferSize          uint32
+	StreamCount                uint32
+	ReadinessTimeoutSeconds    uint32
+	PrometheusAuth             string
+	PrometheusUsername         string
+	PrometheusPassword         string
+	PrometheusBearerToken      string
+	GraphiteAddress            string
+	GraphitePathTemplate       string
+	GraphiteFlushSeconds       uint32
+	StatsdAddress              string
+	StatsdMode                 string
+	StatsdPrefix               string
+	SourceIDAllowList          []string
+	SourceIDDenyList           []string
+	OriginAllowList            []string
+	OriginDenyList             []string
 }
 
 //New NozzleConfiguration
@@ -67,16 +206,105 @@ func New(configPath string, logger *gosteno.Logger) (*Configuration, error) {
 	overrideWithEnvVar(uaaURLEnv, &c.UAAURL)
 	overrideWithEnvVar(uaaUsernameEnv, &c.UAAUsername)
 	overrideWithEnvVar(uaaPasswordEnv, &c.UAAPassword)
+	overrideWithEnvVar(uaaGrantTypeEnv, &c.UAAGrantType)
+	overrideWithEnvVar(uaaClientIDEnv, &c.UAAClientID)
+	overrideWithEnvVar(uaaClientSecretEnv, &c.UAAClientSecret)
 
+	overrideWithEnvVar(trafficControllerURLEnv, &c.TrafficControllerURL)
+	overrideWithEnvVar(inputModeEnv, &c.InputMode)
+	overrideWithEnvVar(replayFileEnv, &c.ReplayFile)
+	overrideWithEnvFloat64(replaySpeedEnv, &c.ReplaySpeed)
+	overrideWithEnvBool(replayLoopEnv, &c.ReplayLoop)
+	overrideWithEnvVar(recordDirectoryEnv, &c.RecordDirectory)
+	overrideWithEnvVar(recordFormatEnv, &c.RecordFormat)
+	overrideWithEnvList(recordOriginAllowListEnv, &c.RecordOriginAllowList)
+	overrideWithEnvUint32(recordMaxFileSizeMBEnv, &c.RecordMaxFileSizeMB)
+	overrideWithEnvUint32(recordRotateSecondsEnv, &c.RecordRotateSeconds)
+	overrideWithEnvUint32(recordMaxDiskMBEnv, &c.RecordMaxDiskMB)
+	overrideWithEnvUint32(recordDurationSecondsEnv, &c.RecordDurationSeconds)
+	overrideWithEnvUint32(generatorDeploymentsEnv, &c.GeneratorDeployments)
+	overrideWithEnvUint32(generatorInstancesEnv, &c.GeneratorInstances)
+	overrideWithEnvUint32(generatorMetricsEnv, &c.GeneratorMetrics)
+	overrideWithEnvUint32(generatorAppsEnv, &c.GeneratorApps)
+	overrideWithEnvUint32(generatorRateEnv, &c.GeneratorRate)
+	overrideWithEnvList(generatorOriginsEnv, &c.GeneratorOrigins)
 	overrideWithEnvVar(subscriptionIDEnv, &c.SubscriptionID)
 	overrideWithEnvBool(disableAccessControlEnv, &c.DisableAccessControl)
 	overrideWithEnvBool(insecureSSLSkipVerifyEnv, &c.InsecureSSLSkipVerify)
+	overrideWithEnvVar(caCertLocationEnv, &c.CACertLocation)
+	overrideWithEnvVar(clientCertLocationEnv, &c.ClientCertLocation)
+	overrideWithEnvVar(clientKeyLocationEnv, &c.ClientKeyLocation)
+	overrideWithEnvVar(proxyURLEnv, &c.ProxyURL)
+	overrideWithEnvVar(proxyUsernameEnv, &c.ProxyUsername)
+	overrideWithEnvVar(proxyPasswordEnv, &c.ProxyPassword)
 	overrideWithEnvUint32(idleTimeoutSecondsEnv, &c.IdleTimeoutSeconds)
 	overrideWithEnvUint32(metricCacheDurationSecondsEnv, &c.MetricCacheDurationSeconds)
 	overrideWithEnvUint32(webServerPortEnv, &c.WebServerPort)
 	overrideWithEnvBool(webServerUseSSLENV, &c.WebServerUseSSL)
 	overrideWithEnvVar(webServerCertLocation, &c.WebServerCertLocation)
 	overrideWithEnvVar(webServerKeyLocation, &c.WebServerKeyLocation)
+	overrideWithEnvUint32(appLatencyWindowSecondsEnv, &c.AppLatencyWindowSeconds)
+	overrideWithEnvUint32(eventLogSizeEnv, &c.EventLogSize)
+	overrideWithEnvUint32(maxReconnectAttemptsEnv, &c.MaxReconnectAttempts)
+	overrideWithEnvUint32(maxReconnectDelaySecondsEnv, &c.MaxReconnectDelaySeconds)
+	overrideWithEnvVar(backpressurePolicyEnv, &c.BackpressurePolicy)
+	overrideWithEnvUint32(messageBufferSizeEnv, &c.MessageBufferSize)
+	overrideWithEnvUint32(streamCountEnv, &c.StreamCount)
+	overrideWithEnvUint32(readinessTimeoutSecondsEnv, &c.ReadinessTimeoutSeconds)
+	overrideWithEnvVar(prometheusAuthEnv, &c.PrometheusAuth)
+	overrideWithEnvVar(prometheusUsernameEnv, &c.PrometheusUsername)
+	overrideWithEnvVar(prometheusPasswordEnv, &c.PrometheusPassword)
+	overrideWithEnvVar(prometheusBearerTokenEnv, &c.PrometheusBearerToken)
+	overrideWithEnvVar(graphiteAddressEnv, &c.GraphiteAddress)
+	overrideWithEnvVar(graphitePathTemplateEnv, &c.GraphitePathTemplate)
+	overrideWithEnvUint32(graphiteFlushSecondsEnv, &c.GraphiteFlushSeconds)
+	overrideWithEnvVar(statsdAddressEnv, &c.StatsdAddress)
+	overrideWithEnvVar(statsdModeEnv, &c.StatsdMode)
+	overrideWithEnvVar(statsdPrefixEnv, &c.StatsdPrefix)
+	overrideWithEnvList(sourceIDAllowListEnv, &c.SourceIDAllowList)
+	overrideWithEnvList(sourceIDDenyListEnv, &c.SourceIDDenyList)
+	overrideWithEnvList(originAllowListEnv, &c.OriginAllowList)
+	overrideWithEnvList(originDenyListEnv, &c.OriginDenyList)
+
+	switch c.UAAGrantType {
+	case "", GrantTypeClientCredentials, GrantTypePassword:
+	default:
+		return nil, fmt.Errorf("Unsupported UAAGrantType <%s>, expected %s or %s", c.UAAGrantType, GrantTypeClientCredentials, GrantTypePassword)
+	}
+
+	switch c.InputMode {
+	case "", InputModeRLP, InputModeFirehose, InputModeReplay, InputModeGenerator:
+	default:
+		return nil, fmt.Errorf("Unsupported InputMode <%s>, expected %s, %s, %s or %s", c.InputMode, InputModeRLP, InputModeFirehose, InputModeReplay, InputModeGenerator)
+	}
+
+	switch c.RecordFormat {
+	case "", RecordFormatJSONL, RecordFormatProtobuf:
+	default:
+		return nil, fmt.Errorf("Unsupported RecordFormat <%s>, expected %s or %s", c.RecordFormat, RecordFormatJSONL, RecordFormatProtobuf)
+	}
+
+	switch c.BackpressurePolicy {
+	case "", BackpressureBlock, BackpressureDropNewest, BackpressureDropOldest:
+	default:
+		return nil, fmt.Errorf("Unsupported BackpressurePolicy <%s>, expected %s, %s or %s", c.BackpressurePolicy, BackpressureBlock, BackpressureDropNewest, BackpressureDropOldest)
+	}
+
+	switch c.PrometheusAuth {
+	case "", PrometheusAuthBasic, PrometheusAuthNone:
+	case PrometheusAuthBearer:
+		if c.PrometheusBearerToken == "" {
+			return nil, fmt.Errorf("PrometheusAuth <%s> requires a PrometheusBearerToken", c.PrometheusAuth)
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported PrometheusAuth <%s>, expected %s, %s or %s", c.PrometheusAuth, PrometheusAuthBasic, PrometheusAuthBearer, PrometheusAuthNone)
+	}
+
+	switch c.StatsdMode {
+	case "", StatsdModeStatsd, StatsdModeDogStatsd:
+	default:
+		return nil, fmt.Errorf("Unsupported StatsdMode <%s>, expected %s or %s", c.StatsdMode, StatsdModeStatsd, StatsdModeDogStatsd)
+	}
 
 	// we use the specified RLP URL over converting the CC URL
 	rlp := os.Getenv(rlpUrlEnv)
@@ -88,8 +316,8 @@ func New(configPath string, logger *gosteno.Logger) (*Configuration, error) {
 		c.RLPURL = r.ReplaceAllString(c.RLPURL, "://log-stream")
 	}
 
-	logger.Debug(fmt.Sprintf("Loaded configuration to UAAURL <%s>, UAA Username <%s>, RLP URL <%s>, Disable Access Control <%v>, Insecure SSL Skip Verify <%v>",
-		c.UAAURL, c.UAAUsername, c.RLPURL, c.DisableAccessControl, c.InsecureSSLSkipVerify))
+	logger.Debug(fmt.Sprintf("Loaded configuration to UAAURL <%s>, UAA Username <%s>, UAA Grant Type <%s>, UAA Client ID <%s>, RLP URL <%s>, Traffic Controller URL <%s>, Input Mode <%s>, Disable Access Control <%v>, Insecure SSL Skip Verify <%v>",
+		c.UAAURL, c.UAAUsername, c.UAAGrantType, c.UAAClientID, c.RLPURL, c.TrafficControllerURL, c.InputMode, c.DisableAccessControl, c.InsecureSSLSkipVerify))
 
 	return &c, nil
 }
@@ -113,6 +341,19 @@ func overrideWithEnvVar(name string, value *string) {
 	}
 }
 
+//overrideWithEnvList reads a comma separated list
+func overrideWithEnvList(name string, value *[]string) {
+	envValue := os.Getenv(name)
+	if envValue != "" {
+		*value = nil
+		for _, v := range strings.Split(envValue, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				*value = append(*value, v)
+			}
+		}
+	}
+}
+
 func overrideWithEnvUint32(name string, value *uint32) {
 	envValue := os.Getenv(name)
 	if envValue != "" {
@@ -124,6 +365,17 @@ func overrideWithEnvUint32(name string, value *uint32) {
 	}
 }
 
+func overrideWithEnvFloat64(name string, value *float64) {
+	envValue := os.Getenv(name)
+	if envValue != "" {
+		var err error
+		*value, err = strconv.ParseFloat(envValue, 64)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
 func overrideWithEnvBool(name string, value *bool) {
 	envValue := os.Getenv(name)
 	if envValue != "" {
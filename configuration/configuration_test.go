@@ -27,28 +27,122 @@ const (
 	testUAAURL                = "UAAURL"
 	testUsername              = "username"
 	testPassword              = "password"
+	testGrantType             = "password"
+	testClientID              = "cf"
+	testClientSecret          = "secret"
 	testRLPURL                = "traffic_url"
+	testTrafficControllerURL  = "wss://doppler.example.com:443"
+	testInputMode             = "firehose"
+	testReplayFile            = "../recordings/envelopes.jsonl"
+	testReplaySpeed           = 2.5
+	testReplayLoop            = true
+	testRecordDirectory       = "../recordings"
+	testRecordFormat          = "protobuf"
+	testRecordOriginAllowList = "rep,bbs"
+	testRecordMaxFileSizeMB   = uint32(50)
+	testRecordRotateSeconds   = uint32(300)
+	testRecordMaxDiskMB       = uint32(500)
+	testRecordDuration        = uint32(600)
+	testGeneratorDeployments  = uint32(2)
+	testGeneratorInstances    = uint32(3)
+	testGeneratorMetrics      = uint32(20)
+	testGeneratorApps         = uint32(50)
+	testGeneratorRate         = uint32(100000)
+	testGeneratorOrigins      = "gorouter,rep,bbs"
 	testSubscriptionID        = "bluemedora-nozzle"
 	testDisableAccessControl  = false
 	testInsecureSSLSkipVerify = false
+	testCACertLocation        = "../certs/ca.pem"
+	testClientCertLocation    = "../certs/client.pem"
+	testClientKeyLocation     = "../certs/client-key.pem"
+	testProxyURL              = "http://proxy.example.com:3128"
+	testProxyUsername         = "proxy_user"
+	testProxyPassword         = "proxy_password"
 	testIdleTimeout           = uint32(60)
 	testMetricCacheDuration   = uint32(60)
 	testWebServerPort         = uint32(8081)
 	testWebServerUseSSL       = true
 	testWebServerCertLocation = "../certs/cert.pem"
 	testWebServerKeyLocation  = "../certs/key.pem"
+	testAppLatencyWindow      = uint32(60)
+	testEventLogSize          = uint32(500)
+	testMaxReconnectAttempts  = uint32(10)
+	testMaxReconnectDelay     = uint32(30)
+	testBackpressurePolicy    = "drop_oldest"
+	testMessageBufferSize     = uint32(5000)
+	testStreamCount           = uint32(4)
+	testReadinessTimeout      = uint32(120)
+	testPrometheusAuth        = "bearer"
+	testPrometheusUsername    = "prometheus_user"
+	testPrometheusPassword    = "prometheus_password"
+	testPrometheusBearerToken = "prometheus_token"
+	testGraphiteAddress       = "graphite.example.com:2003"
+	testGraphitePathTemplate  = "cf.{origin}.{job}.{index}.{metric}"
+	testGraphiteFlushSeconds  = uint32(30)
+	testStatsdAddress         = "127.0.0.1:8125"
+	testStatsdMode            = "dogstatsd"
+	testStatsdPrefix          = "cf"
+	testSourceIDAllowList     = "cc,gorouter"
+	testOriginDenyList        = "rep"
 
 	testEnvUAAURL                = "env_UAAURL"
 	testEnvUsername              = "env_username"
 	testEnvPassword              = "env_password"
+	testEnvGrantType             = "client_credentials"
+	testEnvClientID              = "env_client"
+	testEnvClientSecret          = "env_secret"
 	testEnvRLPURL                = "env_traffic_url"
+	testEnvTrafficControllerURL  = "wss://env-doppler.example.com:443"
+	testEnvInputMode             = "rlp"
+	testEnvReplayFile            = "/var/vcap/data/envelopes.pb"
+	testEnvReplaySpeed           = "10"
+	testEnvReplayLoop            = "false"
+	testEnvRecordDirectory       = "/var/vcap/data/recordings"
+	testEnvRecordFormat          = "jsonl"
+	testEnvRecordOriginAllowList = "gorouter"
+	testEnvRecordMaxFileSizeMB   = "20"
+	testEnvRecordRotateSeconds   = "60"
+	testEnvRecordMaxDiskMB       = "200"
+	testEnvRecordDuration        = "900"
+	testEnvGeneratorDeployments  = "4"
+	testEnvGeneratorInstances    = "10"
+	testEnvGeneratorMetrics      = "40"
+	testEnvGeneratorApps         = "200"
+	testEnvGeneratorRate         = "50000"
+	testEnvGeneratorOrigins      = "DopplerServer"
 	testEnvsubscriptionID        = "env_bluemedora-nozzle"
 	testEnvDisableAccessControl  = "true"
 	testEnvInsecureSSLSkipVerify = "true"
+	testEnvCACertLocation        = "/etc/ssl/env-ca.pem"
+	testEnvClientCertLocation    = "/etc/ssl/env-client.pem"
+	testEnvClientKeyLocation     = "/etc/ssl/env-client-key.pem"
+	testEnvProxyURL              = "http://env-proxy.example.com:8080"
+	testEnvProxyUsername         = "env_proxy_user"
+	testEnvProxyPassword         = "env_proxy_password"
 	testEnvIdleTimeout           = "120"
 	testEnvMetricCacheDuration   = "90"
 	testEnvWebServerPort         = "9080"
 	testEnvWebServerUseSSL       = "true"
+	testEnvAppLatencyWindow      = "120"
+	testEnvEventLogSize          = "2000"
+	testEnvMaxReconnectAttempts  = "20"
+	testEnvMaxReconnectDelay     = "120"
+	testEnvBackpressurePolicy    = "drop_newest"
+	testEnvMessageBufferSize     = "20000"
+	testEnvStreamCount           = "8"
+	testEnvReadinessTimeout      = "30"
+	testEnvPrometheusAuth        = "basic"
+	testEnvPrometheusUsername    = "env_prometheus_user"
+	testEnvPrometheusPassword    = "env_prometheus_password"
+	testEnvPrometheusBearerToken = "env_prometheus_token"
+	testEnvGraphiteAddress       = "env-graphite.example.com:2003"
+	testEnvGraphitePathTemplate  = "env.{origin}.{metric}"
+	testEnvGraphiteFlushSeconds  = "10"
+	testEnvStatsdAddress         = "localhost:8125"
+	testEnvStatsdMode            = "statsd"
+	testEnvStatsdPrefix          = "env_cf"
+	testEnvSourceIDDenyList      = "app-1, app-2"
+	testEnvOriginAllowList       = "gorouter,bbs"
 )
 
 func TestConfigParsing(t *testing.T) {
@@ -89,11 +183,116 @@ func TestConfigParsing(t *testing.T) {
 		t.Errorf("Expected UAA Password of %s, but received %s", testPassword, config.UAAPassword)
 	}
 
+	t.Log(fmt.Sprintf("Checking UAA Grant Type... (expected value: %s)", testGrantType))
+	if config.UAAGrantType != testGrantType {
+		t.Errorf("Expected UAA Grant Type of %s, but received %s", testGrantType, config.UAAGrantType)
+	}
+
+	t.Log(fmt.Sprintf("Checking UAA Client ID... (expected value: %s)", testClientID))
+	if config.UAAClientID != testClientID {
+		t.Errorf("Expected UAA Client ID of %s, but received %s", testClientID, config.UAAClientID)
+	}
+
+	t.Log(fmt.Sprintf("Checking UAA Client Secret... (expected value: %s)", testClientSecret))
+	if config.UAAClientSecret != testClientSecret {
+		t.Errorf("Expected UAA Client Secret of %s, but received %s", testClientSecret, config.UAAClientSecret)
+	}
+
 	t.Log(fmt.Sprintf("Checking Traffic Controller URL... (expected value: %s)", testRLPURL))
 	if config.RLPURL != testRLPURL {
 		t.Errorf("Expected Traffic Controller URL of %s, but received %s", testRLPURL, config.RLPURL)
 	}
 
+	t.Log(fmt.Sprintf("Checking v1 Traffic Controller URL... (expected value: %s)", testTrafficControllerURL))
+	if config.TrafficControllerURL != testTrafficControllerURL {
+		t.Errorf("Expected v1 Traffic Controller URL of %s, but received %s", testTrafficControllerURL, config.TrafficControllerURL)
+	}
+
+	t.Log(fmt.Sprintf("Checking Input Mode... (expected value: %s)", testInputMode))
+	if config.InputMode != testInputMode {
+		t.Errorf("Expected Input Mode of %s, but received %s", testInputMode, config.InputMode)
+	}
+
+	t.Log(fmt.Sprintf("Checking Replay File... (expected value: %s)", testReplayFile))
+	if config.ReplayFile != testReplayFile {
+		t.Errorf("Expected Replay File of %s, but received %s", testReplayFile, config.ReplayFile)
+	}
+
+	t.Log(fmt.Sprintf("Checking Replay Speed... (expected value: %v)", testReplaySpeed))
+	if config.ReplaySpeed != testReplaySpeed {
+		t.Errorf("Expected Replay Speed of %v, but received %v", testReplaySpeed, config.ReplaySpeed)
+	}
+
+	t.Log(fmt.Sprintf("Checking Replay Loop... (expected value: %v)", testReplayLoop))
+	if config.ReplayLoop != testReplayLoop {
+		t.Errorf("Expected Replay Loop of %v, but received %v", testReplayLoop, config.ReplayLoop)
+	}
+
+	t.Log(fmt.Sprintf("Checking Record Directory... (expected value: %s)", testRecordDirectory))
+	if config.RecordDirectory != testRecordDirectory {
+		t.Errorf("Expected Record Directory of %s, but received %s", testRecordDirectory, config.RecordDirectory)
+	}
+
+	t.Log(fmt.Sprintf("Checking Record Format... (expected value: %s)", testRecordFormat))
+	if config.RecordFormat != testRecordFormat {
+		t.Errorf("Expected Record Format of %s, but received %s", testRecordFormat, config.RecordFormat)
+	}
+
+	t.Log(fmt.Sprintf("Checking Record Origin Allow List... (expected value: %s)", testRecordOriginAllowList))
+	if strings.Join(config.RecordOriginAllowList, ",") != testRecordOriginAllowList {
+		t.Errorf("Expected Record Origin Allow List of %s, but received %v", testRecordOriginAllowList, config.RecordOriginAllowList)
+	}
+
+	t.Log(fmt.Sprintf("Checking Record Max File Size MB... (expected value: %v)", testRecordMaxFileSizeMB))
+	if config.RecordMaxFileSizeMB != testRecordMaxFileSizeMB {
+		t.Errorf("Expected Record Max File Size MB of %v, but received %v", testRecordMaxFileSizeMB, config.RecordMaxFileSizeMB)
+	}
+
+	t.Log(fmt.Sprintf("Checking Record Rotate Seconds... (expected value: %v)", testRecordRotateSeconds))
+	if config.RecordRotateSeconds != testRecordRotateSeconds {
+		t.Errorf("Expected Record Rotate Seconds of %v, but received %v", testRecordRotateSeconds, config.RecordRotateSeconds)
+	}
+
+	t.Log(fmt.Sprintf("Checking Record Max Disk MB... (expected value: %v)", testRecordMaxDiskMB))
+	if config.RecordMaxDiskMB != testRecordMaxDiskMB {
+		t.Errorf("Expected Record Max Disk MB of %v, but received %v", testRecordMaxDiskMB, config.RecordMaxDiskMB)
+	}
+
+	t.Log(fmt.Sprintf("Checking Record Duration Seconds... (expected value: %v)", testRecordDuration))
+	if config.RecordDurationSeconds != testRecordDuration {
+		t.Errorf("Expected Record Duration Seconds of %v, but received %v", testRecordDuration, config.RecordDurationSeconds)
+	}
+
+	t.Log(fmt.Sprintf("Checking Generator Deployments... (expected value: %v)", testGeneratorDeployments))
+	if config.GeneratorDeployments != testGeneratorDeployments {
+		t.Errorf("Expected Generator Deployments of %v, but received %v", testGeneratorDeployments, config.GeneratorDeployments)
+	}
+
+	t.Log(fmt.Sprintf("Checking Generator Instances... (expected value: %v)", testGeneratorInstances))
+	if config.GeneratorInstances != testGeneratorInstances {
+		t.Errorf("Expected Generator Instances of %v, but received %v", testGeneratorInstances, config.GeneratorInstances)
+	}
+
+	t.Log(fmt.Sprintf("Checking Generator Metrics... (expected value: %v)", testGeneratorMetrics))
+	if config.GeneratorMetrics != testGeneratorMetrics {
+		t.Errorf("Expected Generator Metrics of %v, but received %v", testGeneratorMetrics, config.GeneratorMetrics)
+	}
+
+	t.Log(fmt.Sprintf("Checking Generator Apps... (expected value: %v)", testGeneratorApps))
+	if config.GeneratorApps != testGeneratorApps {
+		t.Errorf("Expected Generator Apps of %v, but received %v", testGeneratorApps, config.GeneratorApps)
+	}
+
+	t.Log(fmt.Sprintf("Checking Generator Rate... (expected value: %v)", testGeneratorRate))
+	if config.GeneratorRate != testGeneratorRate {
+		t.Errorf("Expected Generator Rate of %v, but received %v", testGeneratorRate, config.GeneratorRate)
+	}
+
+	t.Log(fmt.Sprintf("Checking Generator Origins... (expected value: %s)", testGeneratorOrigins))
+	if strings.Join(config.GeneratorOrigins, ",") != testGeneratorOrigins {
+		t.Errorf("Expected Generator Origins of %s, but received %v", testGeneratorOrigins, config.GeneratorOrigins)
+	}
+
 	t.Log(fmt.Sprintf("Checking Subscription ID... (expected value: %s)", testSubscriptionID))
 	if config.SubscriptionID != testSubscriptionID {
 		t.Errorf("Expected Subscription ID of %s, but received %s", testSubscriptionID, config.SubscriptionID)
@@ -109,6 +308,36 @@ func TestConfigParsing(t *testing.T) {
 		t.Errorf("Expected Insecure SSL Skip Verify of %v, but received %v", testInsecureSSLSkipVerify, config.InsecureSSLSkipVerify)
 	}
 
+	t.Log(fmt.Sprintf("Checking CA Cert Location... (expected value: %s)", testCACertLocation))
+	if config.CACertLocation != testCACertLocation {
+		t.Errorf("Expected CA Cert Location of %s, but received %s", testCACertLocation, config.CACertLocation)
+	}
+
+	t.Log(fmt.Sprintf("Checking Client Cert Location... (expected value: %s)", testClientCertLocation))
+	if config.ClientCertLocation != testClientCertLocation {
+		t.Errorf("Expected Client Cert Location of %s, but received %s", testClientCertLocation, config.ClientCertLocation)
+	}
+
+	t.Log(fmt.Sprintf("Checking Client Key Location... (expected value: %s)", testClientKeyLocation))
+	if config.ClientKeyLocation != testClientKeyLocation {
+		t.Errorf("Expected Client Key Location of %s, but received %s", testClientKeyLocation, config.ClientKeyLocation)
+	}
+
+	t.Log(fmt.Sprintf("Checking Proxy URL... (expected value: %s)", testProxyURL))
+	if config.ProxyURL != testProxyURL {
+		t.Errorf("Expected Proxy URL of %s, but received %s", testProxyURL, config.ProxyURL)
+	}
+
+	t.Log(fmt.Sprintf("Checking Proxy Username... (expected value: %s)", testProxyUsername))
+	if config.ProxyUsername != testProxyUsername {
+		t.Errorf("Expected Proxy Username of %s, but received %s", testProxyUsername, config.ProxyUsername)
+	}
+
+	t.Log(fmt.Sprintf("Checking Proxy Password... (expected value: %s)", testProxyPassword))
+	if config.ProxyPassword != testProxyPassword {
+		t.Errorf("Expected Proxy Password of %s, but received %s", testProxyPassword, config.ProxyPassword)
+	}
+
 	t.Log(fmt.Sprintf("Checking Idle Timeout... (expected value: %v)", testIdleTimeout))
 	if config.IdleTimeoutSeconds != testIdleTimeout {
 		t.Errorf("Expected Idle Timeout of %v, but received %v", testIdleTimeout, config.IdleTimeoutSeconds)
@@ -129,6 +358,106 @@ func TestConfigParsing(t *testing.T) {
 		t.Errorf("Expected Web Server Port of %v, but received %v", testWebServerUseSSL, config.WebServerPort)
 	}
 
+	t.Log(fmt.Sprintf("Checking App Latency Window... (expected value: %v)", testAppLatencyWindow))
+	if config.AppLatencyWindowSeconds != testAppLatencyWindow {
+		t.Errorf("Expected App Latency Window of %v, but received %v", testAppLatencyWindow, config.AppLatencyWindowSeconds)
+	}
+
+	t.Log(fmt.Sprintf("Checking Event Log Size... (expected value: %v)", testEventLogSize))
+	if config.EventLogSize != testEventLogSize {
+		t.Errorf("Expected Event Log Size of %v, but received %v", testEventLogSize, config.EventLogSize)
+	}
+
+	t.Log(fmt.Sprintf("Checking Max Reconnect Attempts... (expected value: %v)", testMaxReconnectAttempts))
+	if config.MaxReconnectAttempts != testMaxReconnectAttempts {
+		t.Errorf("Expected Max Reconnect Attempts of %v, but received %v", testMaxReconnectAttempts, config.MaxReconnectAttempts)
+	}
+
+	t.Log(fmt.Sprintf("Checking Max Reconnect Delay... (expected value: %v)", testMaxReconnectDelay))
+	if config.MaxReconnectDelaySeconds != testMaxReconnectDelay {
+		t.Errorf("Expected Max Reconnect Delay of %v, but received %v", testMaxReconnectDelay, config.MaxReconnectDelaySeconds)
+	}
+
+	t.Log(fmt.Sprintf("Checking Backpressure Policy... (expected value: %v)", testBackpressurePolicy))
+	if config.BackpressurePolicy != testBackpressurePolicy {
+		t.Errorf("Expected Backpressure Policy of %v, but received %v", testBackpressurePolicy, config.BackpressurePolicy)
+	}
+
+	t.Log(fmt.Sprintf("Checking Message Buffer Size... (expected value: %v)", testMessageBufferSize))
+	if config.MessageBufferSize != testMessageBufferSize {
+		t.Errorf("Expected Message Buffer Size of %v, but received %v", testMessageBufferSize, config.MessageBufferSize)
+	}
+
+	t.Log(fmt.Sprintf("Checking Stream Count... (expected value: %v)", testStreamCount))
+	if config.StreamCount != testStreamCount {
+		t.Errorf("Expected Stream Count of %v, but received %v", testStreamCount, config.StreamCount)
+	}
+
+	t.Log(fmt.Sprintf("Checking Readiness Timeout... (expected value: %v)", testReadinessTimeout))
+	if config.ReadinessTimeoutSeconds != testReadinessTimeout {
+		t.Errorf("Expected Readiness Timeout of %v, but received %v", testReadinessTimeout, config.ReadinessTimeoutSeconds)
+	}
+
+	t.Log(fmt.Sprintf("Checking Prometheus Auth... (expected value: %s)", testPrometheusAuth))
+	if config.PrometheusAuth != testPrometheusAuth {
+		t.Errorf("Expected Prometheus Auth of %s, but received %s", testPrometheusAuth, config.PrometheusAuth)
+	}
+
+	t.Log(fmt.Sprintf("Checking Prometheus Username... (expected value: %s)", testPrometheusUsername))
+	if config.PrometheusUsername != testPrometheusUsername {
+		t.Errorf("Expected Prometheus Username of %s, but received %s", testPrometheusUsername, config.PrometheusUsername)
+	}
+
+	t.Log(fmt.Sprintf("Checking Prometheus Password... (expected value: %s)", testPrometheusPassword))
+	if config.PrometheusPassword != testPrometheusPassword {
+		t.Errorf("Expected Prometheus Password of %s, but received %s", testPrometheusPassword, config.PrometheusPassword)
+	}
+
+	t.Log(fmt.Sprintf("Checking Prometheus Bearer Token... (expected value: %s)", testPrometheusBearerToken))
+	if config.PrometheusBearerToken != testPrometheusBearerToken {
+		t.Errorf("Expected Prometheus Bearer Token of %s, but received %s", testPrometheusBearerToken, config.PrometheusBearerToken)
+	}
+
+	t.Log(fmt.Sprintf("Checking Graphite Address... (expected value: %s)", testGraphiteAddress))
+	if config.GraphiteAddress != testGraphiteAddress {
+		t.Errorf("Expected Graphite Address of %s, but received %s", testGraphiteAddress, config.GraphiteAddress)
+	}
+
+	t.Log(fmt.Sprintf("Checking Graphite Path Template... (expected value: %s)", testGraphitePathTemplate))
+	if config.GraphitePathTemplate != testGraphitePathTemplate {
+		t.Errorf("Expected Graphite Path Template of %s, but received %s", testGraphitePathTemplate, config.GraphitePathTemplate)
+	}
+
+	t.Log(fmt.Sprintf("Checking Graphite Flush Seconds... (expected value: %v)", testGraphiteFlushSeconds))
+	if config.GraphiteFlushSeconds != testGraphiteFlushSeconds {
+		t.Errorf("Expected Graphite Flush Seconds of %v, but received %v", testGraphiteFlushSeconds, config.GraphiteFlushSeconds)
+	}
+
+	t.Log(fmt.Sprintf("Checking Statsd Address... (expected value: %s)", testStatsdAddress))
+	if config.StatsdAddress != testStatsdAddress {
+		t.Errorf("Expected Statsd Address of %s, but received %s", testStatsdAddress, config.StatsdAddress)
+	}
+
+	t.Log(fmt.Sprintf("Checking Statsd Mode... (expected value: %s)", testStatsdMode))
+	if config.StatsdMode != testStatsdMode {
+		t.Errorf("Expected Statsd Mode of %s, but received %s", testStatsdMode, config.StatsdMode)
+	}
+
+	t.Log(fmt.Sprintf("Checking Statsd Prefix... (expected value: %s)", testStatsdPrefix))
+	if config.StatsdPrefix != testStatsdPrefix {
+		t.Errorf("Expected Statsd Prefix of %s, but received %s", testStatsdPrefix, config.StatsdPrefix)
+	}
+
+	t.Log(fmt.Sprintf("Checking Source ID Allow List... (expected value: %v)", testSourceIDAllowList))
+	if strings.Join(config.SourceIDAllowList, ",") != testSourceIDAllowList {
+		t.Errorf("Expected Source ID Allow List of %v, but received %v", testSourceIDAllowList, config.SourceIDAllowList)
+	}
+
+	t.Log(fmt.Sprintf("Checking Origin Deny List... (expected value: %v)", testOriginDenyList))
+	if strings.Join(config.OriginDenyList, ",") != testOriginDenyList {
+		t.Errorf("Expected Origin Deny List of %v, but received %v", testOriginDenyList, config.OriginDenyList)
+	}
+
 	err = tearDownEnvironment(t)
 	if err != nil {
 		t.Fatalf("Tear down failed due to: %s", err.Error())
@@ -182,6 +511,105 @@ func TestNoConfigFile(t *testing.T) {
 	}
 }
 
+func TestUnsupportedGrantType(t *testing.T) {
+	t.Log("TestUnsupportedGrantType")
+	err := renameConfigFile(t)
+	if err != nil {
+		t.Fatalf("Setup failed due to: %s", err.Error())
+	}
+
+	err = ioutil.WriteFile(configFile, []byte(`{"UAAGrantType": "implicit"}`), os.ModePerm)
+	if err != nil {
+		tearDownEnvironment(t)
+		t.Fatalf("Setup failed due to: %s", err.Error())
+	}
+
+	logger.CreateLogDirectory(defaultLogDirectory)
+	logger := logger.New(defaultLogDirectory, nozzleLogFile, nozzleLogName, nozzleLogLevel)
+
+	t.Log("Checking loading of unsupported grant type... (expecting error)")
+	_, err = New(configFile, logger)
+
+	if err != nil {
+		if !strings.Contains(err.Error(), "Unsupported UAAGrantType <implicit>") {
+			t.Errorf("Expected error containing %s, but received %s", "Unsupported UAAGrantType <implicit>", err.Error())
+		}
+	} else {
+		t.Errorf("Expected error from loading an unsupported grant type, but loaded correctly")
+	}
+
+	err = tearDownEnvironment(t)
+	if err != nil {
+		t.Fatalf("Tear down failed due to: %s", err.Error())
+	}
+}
+
+func TestUnsupportedInputMode(t *testing.T) {
+	t.Log("TestUnsupportedInputMode")
+	err := renameConfigFile(t)
+	if err != nil {
+		t.Fatalf("Setup failed due to: %s", err.Error())
+	}
+
+	err = ioutil.WriteFile(configFile, []byte(`{"InputMode": "syslog"}`), os.ModePerm)
+	if err != nil {
+		tearDownEnvironment(t)
+		t.Fatalf("Setup failed due to: %s", err.Error())
+	}
+
+	logger.CreateLogDirectory(defaultLogDirectory)
+	logger := logger.New(defaultLogDirectory, nozzleLogFile, nozzleLogName, nozzleLogLevel)
+
+	t.Log("Checking loading of unsupported input mode... (expecting error)")
+	_, err = New(configFile, logger)
+
+	if err != nil {
+		if !strings.Contains(err.Error(), "Unsupported InputMode <syslog>") {
+			t.Errorf("Expected error containing %s, but received %s", "Unsupported InputMode <syslog>", err.Error())
+		}
+	} else {
+		t.Errorf("Expected error from loading an unsupported input mode, but loaded correctly")
+	}
+
+	err = tearDownEnvironment(t)
+	if err != nil {
+		t.Fatalf("Tear down failed due to: %s", err.Error())
+	}
+}
+
+func TestPrometheusBearerWithoutToken(t *testing.T) {
+	t.Log("TestPrometheusBearerWithoutToken")
+	err := renameConfigFile(t)
+	if err != nil {
+		t.Fatalf("Setup failed due to: %s", err.Error())
+	}
+
+	err = ioutil.WriteFile(configFile, []byte(`{"PrometheusAuth": "bearer"}`), os.ModePerm)
+	if err != nil {
+		tearDownEnvironment(t)
+		t.Fatalf("Setup failed due to: %s", err.Error())
+	}
+
+	logger.CreateLogDirectory(defaultLogDirectory)
+	logger := logger.New(defaultLogDirectory, nozzleLogFile, nozzleLogName, nozzleLogLevel)
+
+	t.Log("Checking loading of bearer auth without a token... (expecting error)")
+	_, err = New(configFile, logger)
+
+	if err != nil {
+		if !strings.Contains(err.Error(), "requires a PrometheusBearerToken") {
+			t.Errorf("Expected error containing %s, but received %s", "requires a PrometheusBearerToken", err.Error())
+		}
+	} else {
+		t.Errorf("Expected error from loading bearer auth without a token, but loaded correctly")
+	}
+
+	err = tearDownEnvironment(t)
+	if err != nil {
+		t.Fatalf("Tear down failed due to: %s", err.Error())
+	}
+}
+
 func TestEnvironmentVariables(t *testing.T) {
 	//Setup Environment
 	err := setupGoodEnvironment(t)
@@ -197,14 +625,61 @@ func TestEnvironmentVariables(t *testing.T) {
 	os.Setenv(uaaURLEnv, testEnvUAAURL)
 	os.Setenv(uaaUsernameEnv, testEnvUsername)
 	os.Setenv(uaaPasswordEnv, testEnvPassword)
+	os.Setenv(uaaGrantTypeEnv, testEnvGrantType)
+	os.Setenv(uaaClientIDEnv, testEnvClientID)
+	os.Setenv(uaaClientSecretEnv, testEnvClientSecret)
 	os.Setenv(rlpUrlEnv, testEnvRLPURL)
+	os.Setenv(trafficControllerURLEnv, testEnvTrafficControllerURL)
+	os.Setenv(inputModeEnv, testEnvInputMode)
+	os.Setenv(replayFileEnv, testEnvReplayFile)
+	os.Setenv(replaySpeedEnv, testEnvReplaySpeed)
+	os.Setenv(replayLoopEnv, testEnvReplayLoop)
+	os.Setenv(recordDirectoryEnv, testEnvRecordDirectory)
+	os.Setenv(recordFormatEnv, testEnvRecordFormat)
+	os.Setenv(recordOriginAllowListEnv, testEnvRecordOriginAllowList)
+	os.Setenv(recordMaxFileSizeMBEnv, testEnvRecordMaxFileSizeMB)
+	os.Setenv(recordRotateSecondsEnv, testEnvRecordRotateSeconds)
+	os.Setenv(recordMaxDiskMBEnv, testEnvRecordMaxDiskMB)
+	os.Setenv(recordDurationSecondsEnv, testEnvRecordDuration)
+	os.Setenv(generatorDeploymentsEnv, testEnvGeneratorDeployments)
+	os.Setenv(generatorInstancesEnv, testEnvGeneratorInstances)
+	os.Setenv(generatorMetricsEnv, testEnvGeneratorMetrics)
+	os.Setenv(generatorAppsEnv, testEnvGeneratorApps)
+	os.Setenv(generatorRateEnv, testEnvGeneratorRate)
+	os.Setenv(generatorOriginsEnv, testEnvGeneratorOrigins)
 	os.Setenv(subscriptionIDEnv, testEnvsubscriptionID)
 	os.Setenv(disableAccessControlEnv, testEnvDisableAccessControl)
 	os.Setenv(insecureSSLSkipVerifyEnv, testEnvInsecureSSLSkipVerify)
+	os.Setenv(caCertLocationEnv, testEnvCACertLocation)
+	os.Setenv(clientCertLocationEnv, testEnvClientCertLocation)
+	os.Setenv(clientKeyLocationEnv, testEnvClientKeyLocation)
+	os.Setenv(proxyURLEnv, testEnvProxyURL)
+	os.Setenv(proxyUsernameEnv, testEnvProxyUsername)
+	os.Setenv(proxyPasswordEnv, testEnvProxyPassword)
 	os.Setenv(idleTimeoutSecondsEnv, testEnvIdleTimeout)
 	os.Setenv(metricCacheDurationSecondsEnv, testEnvMetricCacheDuration)
 	os.Setenv(webServerPortEnv, testEnvWebServerPort)
 	os.Setenv(webServerUseSSLENV, testEnvWebServerUseSSL)
+	os.Setenv(appLatencyWindowSecondsEnv, testEnvAppLatencyWindow)
+	os.Setenv(eventLogSizeEnv, testEnvEventLogSize)
+	os.Setenv(maxReconnectAttemptsEnv, testEnvMaxReconnectAttempts)
+	os.Setenv(maxReconnectDelaySecondsEnv, testEnvMaxReconnectDelay)
+	os.Setenv(backpressurePolicyEnv, testEnvBackpressurePolicy)
+	os.Setenv(messageBufferSizeEnv, testEnvMessageBufferSize)
+	os.Setenv(streamCountEnv, testEnvStreamCount)
+	os.Setenv(readinessTimeoutSecondsEnv, testEnvReadinessTimeout)
+	os.Setenv(prometheusAuthEnv, testEnvPrometheusAuth)
+	os.Setenv(prometheusUsernameEnv, testEnvPrometheusUsername)
+	os.Setenv(prometheusPasswordEnv, testEnvPrometheusPassword)
+	os.Setenv(prometheusBearerTokenEnv, testEnvPrometheusBearerToken)
+	os.Setenv(graphiteAddressEnv, testEnvGraphiteAddress)
+	os.Setenv(graphitePathTemplateEnv, testEnvGraphitePathTemplate)
+	os.Setenv(graphiteFlushSecondsEnv, testEnvGraphiteFlushSeconds)
+	os.Setenv(statsdAddressEnv, testEnvStatsdAddress)
+	os.Setenv(statsdModeEnv, testEnvStatsdMode)
+	os.Setenv(statsdPrefixEnv, testEnvStatsdPrefix)
+	os.Setenv(sourceIDDenyListEnv, testEnvSourceIDDenyList)
+	os.Setenv(originAllowListEnv, testEnvOriginAllowList)
 
 	//Create new configuration
 	var config *Configuration
@@ -231,11 +706,127 @@ func TestEnvironmentVariables(t *testing.T) {
 		t.Errorf("Expected UAA Password of %s, but received %s", testEnvPassword, config.UAAPassword)
 	}
 
+	t.Log(fmt.Sprintf("Checking UAA Grant Type... (expected value: %s)", testEnvGrantType))
+	if config.UAAGrantType != testEnvGrantType {
+		t.Errorf("Expected UAA Grant Type of %s, but received %s", testEnvGrantType, config.UAAGrantType)
+	}
+
+	t.Log(fmt.Sprintf("Checking UAA Client ID... (expected value: %s)", testEnvClientID))
+	if config.UAAClientID != testEnvClientID {
+		t.Errorf("Expected UAA Client ID of %s, but received %s", testEnvClientID, config.UAAClientID)
+	}
+
+	t.Log(fmt.Sprintf("Checking UAA Client Secret... (expected value: %s)", testEnvClientSecret))
+	if config.UAAClientSecret != testEnvClientSecret {
+		t.Errorf("Expected UAA Client Secret of %s, but received %s", testEnvClientSecret, config.UAAClientSecret)
+	}
+
 	t.Log(fmt.Sprintf("Checking RLP URL... (expected value: %s)", testEnvRLPURL))
 	if config.RLPURL != testEnvRLPURL {
 		t.Errorf("Expected RLP URL of %s, but received %s", testEnvRLPURL, config.RLPURL)
 	}
 
+	t.Log(fmt.Sprintf("Checking v1 Traffic Controller URL... (expected value: %s)", testEnvTrafficControllerURL))
+	if config.TrafficControllerURL != testEnvTrafficControllerURL {
+		t.Errorf("Expected v1 Traffic Controller URL of %s, but received %s", testEnvTrafficControllerURL, config.TrafficControllerURL)
+	}
+
+	t.Log(fmt.Sprintf("Checking Input Mode... (expected value: %s)", testEnvInputMode))
+	if config.InputMode != testEnvInputMode {
+		t.Errorf("Expected Input Mode of %s, but received %s", testEnvInputMode, config.InputMode)
+	}
+
+	t.Log(fmt.Sprintf("Checking Replay File... (expected value: %s)", testEnvReplayFile))
+	if config.ReplayFile != testEnvReplayFile {
+		t.Errorf("Expected Replay File of %s, but received %s", testEnvReplayFile, config.ReplayFile)
+	}
+
+	t.Log(fmt.Sprintf("Checking Replay Speed... (expected value: %v)", testEnvReplaySpeed))
+	convertedtestEnvReplaySpeed, _ := strconv.ParseFloat(testEnvReplaySpeed, 64)
+	if config.ReplaySpeed != convertedtestEnvReplaySpeed {
+		t.Errorf("Expected Replay Speed of %v, but received %v", testEnvReplaySpeed, config.ReplaySpeed)
+	}
+
+	t.Log(fmt.Sprintf("Checking Replay Loop... (expected value: %v)", testEnvReplayLoop))
+	convertedReplayLoop, _ := strconv.ParseBool(testEnvReplayLoop)
+	if config.ReplayLoop != convertedReplayLoop {
+		t.Errorf("Expected Replay Loop of %v, but received %v", testEnvReplayLoop, config.ReplayLoop)
+	}
+
+	t.Log(fmt.Sprintf("Checking Record Directory... (expected value: %s)", testEnvRecordDirectory))
+	if config.RecordDirectory != testEnvRecordDirectory {
+		t.Errorf("Expected Record Directory of %s, but received %s", testEnvRecordDirectory, config.RecordDirectory)
+	}
+
+	t.Log(fmt.Sprintf("Checking Record Format... (expected value: %s)", testEnvRecordFormat))
+	if config.RecordFormat != testEnvRecordFormat {
+		t.Errorf("Expected Record Format of %s, but received %s", testEnvRecordFormat, config.RecordFormat)
+	}
+
+	t.Log(fmt.Sprintf("Checking Record Origin Allow List... (expected value: %s)", testEnvRecordOriginAllowList))
+	if strings.Join(config.RecordOriginAllowList, ",") != testEnvRecordOriginAllowList {
+		t.Errorf("Expected Record Origin Allow List of %s, but received %v", testEnvRecordOriginAllowList, config.RecordOriginAllowList)
+	}
+
+	t.Log(fmt.Sprintf("Checking Record Max File Size MB... (expected value: %v)", testEnvRecordMaxFileSizeMB))
+	convertedtestEnvRecordMaxFileSizeMB, _ := strconv.Atoi(testEnvRecordMaxFileSizeMB)
+	if config.RecordMaxFileSizeMB != uint32(convertedtestEnvRecordMaxFileSizeMB) {
+		t.Errorf("Expected Record Max File Size MB of %v, but received %v", testEnvRecordMaxFileSizeMB, config.RecordMaxFileSizeMB)
+	}
+
+	t.Log(fmt.Sprintf("Checking Record Rotate Seconds... (expected value: %v)", testEnvRecordRotateSeconds))
+	convertedtestEnvRecordRotateSeconds, _ := strconv.Atoi(testEnvRecordRotateSeconds)
+	if config.RecordRotateSeconds != uint32(convertedtestEnvRecordRotateSeconds) {
+		t.Errorf("Expected Record Rotate Seconds of %v, but received %v", testEnvRecordRotateSeconds, config.RecordRotateSeconds)
+	}
+
+	t.Log(fmt.Sprintf("Checking Record Max Disk MB... (expected value: %v)", testEnvRecordMaxDiskMB))
+	convertedtestEnvRecordMaxDiskMB, _ := strconv.Atoi(testEnvRecordMaxDiskMB)
+	if config.RecordMaxDiskMB != uint32(convertedtestEnvRecordMaxDiskMB) {
+		t.Errorf("Expected Record Max Disk MB of %v, but received %v", testEnvRecordMaxDiskMB, config.RecordMaxDiskMB)
+	}
+
+	t.Log(fmt.Sprintf("Checking Record Duration Seconds... (expected value: %v)", testEnvRecordDuration))
+	convertedtestEnvRecordDuration, _ := strconv.Atoi(testEnvRecordDuration)
+	if config.RecordDurationSeconds != uint32(convertedtestEnvRecordDuration) {
+		t.Errorf("Expected Record Duration Seconds of %v, but received %v", testEnvRecordDuration, config.RecordDurationSeconds)
+	}
+
+	t.Log(fmt.Sprintf("Checking Generator Deployments... (expected value: %v)", testEnvGeneratorDeployments))
+	convertedtestEnvGeneratorDeployments, _ := strconv.Atoi(testEnvGeneratorDeployments)
+	if config.GeneratorDeployments != uint32(convertedtestEnvGeneratorDeployments) {
+		t.Errorf("Expected Generator Deployments of %v, but received %v", testEnvGeneratorDeployments, config.GeneratorDeployments)
+	}
+
+	t.Log(fmt.Sprintf("Checking Generator Instances... (expected value: %v)", testEnvGeneratorInstances))
+	convertedtestEnvGeneratorInstances, _ := strconv.Atoi(testEnvGeneratorInstances)
+	if config.GeneratorInstances != uint32(convertedtestEnvGeneratorInstances) {
+		t.Errorf("Expected Generator Instances of %v, but received %v", testEnvGeneratorInstances, config.GeneratorInstances)
+	}
+
+	t.Log(fmt.Sprintf("Checking Generator Metrics... (expected value: %v)", testEnvGeneratorMetrics))
+	convertedtestEnvGeneratorMetrics, _ := strconv.Atoi(testEnvGeneratorMetrics)
+	if config.GeneratorMetrics != uint32(convertedtestEnvGeneratorMetrics) {
+		t.Errorf("Expected Generator Metrics of %v, but received %v", testEnvGeneratorMetrics, config.GeneratorMetrics)
+	}
+
+	t.Log(fmt.Sprintf("Checking Generator Apps... (expected value: %v)", testEnvGeneratorApps))
+	convertedtestEnvGeneratorApps, _ := strconv.Atoi(testEnvGeneratorApps)
+	if config.GeneratorApps != uint32(convertedtestEnvGeneratorApps) {
+		t.Errorf("Expected Generator Apps of %v, but received %v", testEnvGeneratorApps, config.GeneratorApps)
+	}
+
+	t.Log(fmt.Sprintf("Checking Generator Rate... (expected value: %v)", testEnvGeneratorRate))
+	convertedtestEnvGeneratorRate, _ := strconv.Atoi(testEnvGeneratorRate)
+	if config.GeneratorRate != uint32(convertedtestEnvGeneratorRate) {
+		t.Errorf("Expected Generator Rate of %v, but received %v", testEnvGeneratorRate, config.GeneratorRate)
+	}
+
+	t.Log(fmt.Sprintf("Checking Generator Origins... (expected value: %s)", testEnvGeneratorOrigins))
+	if strings.Join(config.GeneratorOrigins, ",") != testEnvGeneratorOrigins {
+		t.Errorf("Expected Generator Origins of %s, but received %v", testEnvGeneratorOrigins, config.GeneratorOrigins)
+	}
+
 	t.Log(fmt.Sprintf("Checking Subscription ID... (expected value: %s)", testEnvsubscriptionID))
 	if config.SubscriptionID != testEnvsubscriptionID {
 		t.Errorf("Expected Subscription ID of %s, but received %s", testEnvsubscriptionID, config.SubscriptionID)
@@ -253,6 +844,36 @@ func TestEnvironmentVariables(t *testing.T) {
 		t.Errorf("Expected Insecure SSL Skip Verify of %v, but received %v", testEnvInsecureSSLSkipVerify, config.InsecureSSLSkipVerify)
 	}
 
+	t.Log(fmt.Sprintf("Checking CA Cert Location... (expected value: %s)", testEnvCACertLocation))
+	if config.CACertLocation != testEnvCACertLocation {
+		t.Errorf("Expected CA Cert Location of %s, but received %s", testEnvCACertLocation, config.CACertLocation)
+	}
+
+	t.Log(fmt.Sprintf("Checking Client Cert Location... (expected value: %s)", testEnvClientCertLocation))
+	if config.ClientCertLocation != testEnvClientCertLocation {
+		t.Errorf("Expected Client Cert Location of %s, but received %s", testEnvClientCertLocation, config.ClientCertLocation)
+	}
+
+	t.Log(fmt.Sprintf("Checking Client Key Location... (expected value: %s)", testEnvClientKeyLocation))
+	if config.ClientKeyLocation != testEnvClientKeyLocation {
+		t.Errorf("Expected Client Key Location of %s, but received %s", testEnvClientKeyLocation, config.ClientKeyLocation)
+	}
+
+	t.Log(fmt.Sprintf("Checking Proxy URL... (expected value: %s)", testEnvProxyURL))
+	if config.ProxyURL != testEnvProxyURL {
+		t.Errorf("Expected Proxy URL of %s, but received %s", testEnvProxyURL, config.ProxyURL)
+	}
+
+	t.Log(fmt.Sprintf("Checking Proxy Username... (expected value: %s)", testEnvProxyUsername))
+	if config.ProxyUsername != testEnvProxyUsername {
+		t.Errorf("Expected Proxy Username of %s, but received %s", testEnvProxyUsername, config.ProxyUsername)
+	}
+
+	t.Log(fmt.Sprintf("Checking Proxy Password... (expected value: %s)", testEnvProxyPassword))
+	if config.ProxyPassword != testEnvProxyPassword {
+		t.Errorf("Expected Proxy Password of %s, but received %s", testEnvProxyPassword, config.ProxyPassword)
+	}
+
 	t.Log(fmt.Sprintf("Checking Idle Timeout... (expected value: %v)", testIdleTimeout))
 	convertedtestEnvIdleTimeout, _ := strconv.Atoi(testEnvIdleTimeout)
 	if config.IdleTimeoutSeconds != uint32(convertedtestEnvIdleTimeout) {
@@ -277,6 +898,114 @@ func TestEnvironmentVariables(t *testing.T) {
 		t.Errorf("Expected Web Server Port of %v, but received %v", testEnvWebServerUseSSL, config.WebServerPort)
 	}
 
+	t.Log(fmt.Sprintf("Checking App Latency Window... (expected value: %v)", testEnvAppLatencyWindow))
+	convertedtestEnvAppLatencyWindow, _ := strconv.Atoi(testEnvAppLatencyWindow)
+	if config.AppLatencyWindowSeconds != uint32(convertedtestEnvAppLatencyWindow) {
+		t.Errorf("Expected App Latency Window of %v, but received %v", testEnvAppLatencyWindow, config.AppLatencyWindowSeconds)
+	}
+
+	t.Log(fmt.Sprintf("Checking Event Log Size... (expected value: %v)", testEnvEventLogSize))
+	convertedtestEnvEventLogSize, _ := strconv.Atoi(testEnvEventLogSize)
+	if config.EventLogSize != uint32(convertedtestEnvEventLogSize) {
+		t.Errorf("Expected Event Log Size of %v, but received %v", testEnvEventLogSize, config.EventLogSize)
+	}
+
+	t.Log(fmt.Sprintf("Checking Max Reconnect Attempts... (expected value: %v)", testEnvMaxReconnectAttempts))
+	convertedtestEnvMaxReconnectAttempts, _ := strconv.Atoi(testEnvMaxReconnectAttempts)
+	if config.MaxReconnectAttempts != uint32(convertedtestEnvMaxReconnectAttempts) {
+		t.Errorf("Expected Max Reconnect Attempts of %v, but received %v", testEnvMaxReconnectAttempts, config.MaxReconnectAttempts)
+	}
+
+	t.Log(fmt.Sprintf("Checking Max Reconnect Delay... (expected value: %v)", testEnvMaxReconnectDelay))
+	convertedtestEnvMaxReconnectDelay, _ := strconv.Atoi(testEnvMaxReconnectDelay)
+	if config.MaxReconnectDelaySeconds != uint32(convertedtestEnvMaxReconnectDelay) {
+		t.Errorf("Expected Max Reconnect Delay of %v, but received %v", testEnvMaxReconnectDelay, config.MaxReconnectDelaySeconds)
+	}
+
+	t.Log(fmt.Sprintf("Checking Backpressure Policy... (expected value: %v)", testEnvBackpressurePolicy))
+	if config.BackpressurePolicy != testEnvBackpressurePolicy {
+		t.Errorf("Expected Backpressure Policy of %v, but received %v", testEnvBackpressurePolicy, config.BackpressurePolicy)
+	}
+
+	t.Log(fmt.Sprintf("Checking Message Buffer Size... (expected value: %v)", testEnvMessageBufferSize))
+	convertedtestEnvMessageBufferSize, _ := strconv.Atoi(testEnvMessageBufferSize)
+	if config.MessageBufferSize != uint32(convertedtestEnvMessageBufferSize) {
+		t.Errorf("Expected Message Buffer Size of %v, but received %v", testEnvMessageBufferSize, config.MessageBufferSize)
+	}
+
+	t.Log(fmt.Sprintf("Checking Stream Count... (expected value: %v)", testEnvStreamCount))
+	convertedtestEnvStreamCount, _ := strconv.Atoi(testEnvStreamCount)
+	if config.StreamCount != uint32(convertedtestEnvStreamCount) {
+		t.Errorf("Expected Stream Count of %v, but received %v", testEnvStreamCount, config.StreamCount)
+	}
+
+	t.Log(fmt.Sprintf("Checking Readiness Timeout... (expected value: %v)", testEnvReadinessTimeout))
+	convertedtestEnvReadinessTimeout, _ := strconv.Atoi(testEnvReadinessTimeout)
+	if config.ReadinessTimeoutSeconds != uint32(convertedtestEnvReadinessTimeout) {
+		t.Errorf("Expected Readiness Timeout of %v, but received %v", testEnvReadinessTimeout, config.ReadinessTimeoutSeconds)
+	}
+
+	t.Log(fmt.Sprintf("Checking Prometheus Auth... (expected value: %s)", testEnvPrometheusAuth))
+	if config.PrometheusAuth != testEnvPrometheusAuth {
+		t.Errorf("Expected Prometheus Auth of %s, but received %s", testEnvPrometheusAuth, config.PrometheusAuth)
+	}
+
+	t.Log(fmt.Sprintf("Checking Prometheus Username... (expected value: %s)", testEnvPrometheusUsername))
+	if config.PrometheusUsername != testEnvPrometheusUsername {
+		t.Errorf("Expected Prometheus Username of %s, but received %s", testEnvPrometheusUsername, config.PrometheusUsername)
+	}
+
+	t.Log(fmt.Sprintf("Checking Prometheus Password... (expected value: %s)", testEnvPrometheusPassword))
+	if config.PrometheusPassword != testEnvPrometheusPassword {
+		t.Errorf("Expected Prometheus Password of %s, but received %s", testEnvPrometheusPassword, config.PrometheusPassword)
+	}
+
+	t.Log(fmt.Sprintf("Checking Prometheus Bearer Token... (expected value: %s)", testEnvPrometheusBearerToken))
+	if config.PrometheusBearerToken != testEnvPrometheusBearerToken {
+		t.Errorf("Expected Prometheus Bearer Token of %s, but received %s", testEnvPrometheusBearerToken, config.PrometheusBearerToken)
+	}
+
+	t.Log(fmt.Sprintf("Checking Graphite Address... (expected value: %s)", testEnvGraphiteAddress))
+	if config.GraphiteAddress != testEnvGraphiteAddress {
+		t.Errorf("Expected Graphite Address of %s, but received %s", testEnvGraphiteAddress, config.GraphiteAddress)
+	}
+
+	t.Log(fmt.Sprintf("Checking Graphite Path Template... (expected value: %s)", testEnvGraphitePathTemplate))
+	if config.GraphitePathTemplate != testEnvGraphitePathTemplate {
+		t.Errorf("Expected Graphite Path Template of %s, but received %s", testEnvGraphitePathTemplate, config.GraphitePathTemplate)
+	}
+
+	t.Log(fmt.Sprintf("Checking Graphite Flush Seconds... (expected value: %v)", testEnvGraphiteFlushSeconds))
+	convertedtestEnvGraphiteFlushSeconds, _ := strconv.Atoi(testEnvGraphiteFlushSeconds)
+	if config.GraphiteFlushSeconds != uint32(convertedtestEnvGraphiteFlushSeconds) {
+		t.Errorf("Expected Graphite Flush Seconds of %v, but received %v", testEnvGraphiteFlushSeconds, config.GraphiteFlushSeconds)
+	}
+
+	t.Log(fmt.Sprintf("Checking Statsd Address... (expected value: %s)", testEnvStatsdAddress))
+	if config.StatsdAddress != testEnvStatsdAddress {
+		t.Errorf("Expected Statsd Address of %s, but received %s", testEnvStatsdAddress, config.StatsdAddress)
+	}
+
+	t.Log(fmt.Sprintf("Checking Statsd Mode... (expected value: %s)", testEnvStatsdMode))
+	if config.StatsdMode != testEnvStatsdMode {
+		t.Errorf("Expected Statsd Mode of %s, but received %s", testEnvStatsdMode, config.StatsdMode)
+	}
+
+	t.Log(fmt.Sprintf("Checking Statsd Prefix... (expected value: %s)", testEnvStatsdPrefix))
+	if config.StatsdPrefix != testEnvStatsdPrefix {
+		t.Errorf("Expected Statsd Prefix of %s, but received %s", testEnvStatsdPrefix, config.StatsdPrefix)
+	}
+
+	t.Log(fmt.Sprintf("Checking Source ID Deny List... (expected value: %v)", testEnvSourceIDDenyList))
+	if strings.Join(config.SourceIDDenyList, ",") != "app-1,app-2" {
+		t.Errorf("Expected Source ID Deny List of %v, but received %v", testEnvSourceIDDenyList, config.SourceIDDenyList)
+	}
+
+	t.Log(fmt.Sprintf("Checking Origin Allow List... (expected value: %v)", testEnvOriginAllowList))
+	if strings.Join(config.OriginAllowList, ",") != testEnvOriginAllowList {
+		t.Errorf("Expected Origin Allow List of %v, but received %v", testEnvOriginAllowList, config.OriginAllowList)
+	}
+
 	err = tearDownEnvironment(t)
 	if err != nil {
 		t.Fatalf("Tear down failed due to: %s", err.Error())
@@ -333,12 +1062,67 @@ func createGoodConfigFile(t *testing.T) error {
 	t.Log("Creating good config file...")
 
 	message := Configuration{
-		testUAAURL, testUsername,
-		testPassword, testRLPURL, testSubscriptionID,
-		testDisableAccessControl, testInsecureSSLSkipVerify,
-		testIdleTimeout, testMetricCacheDuration,
-		testWebServerPort, testWebServerUseSSL,
-		testWebServerCertLocation, testWebServerKeyLocation}
+		UAAURL:                     testUAAURL,
+		UAAUsername:                testUsername,
+		UAAPassword:                testPassword,
+		UAAGrantType:               testGrantType,
+		UAAClientID:                testClientID,
+		UAAClientSecret:            testClientSecret,
+		RLPURL:                     testRLPURL,
+		TrafficControllerURL:       testTrafficControllerURL,
+		InputMode:                  testInputMode,
+		ReplayFile:                 testReplayFile,
+		ReplaySpeed:                testReplaySpeed,
+		ReplayLoop:                 testReplayLoop,
+		RecordDirectory:            testRecordDirectory,
+		RecordFormat:               testRecordFormat,
+		RecordOriginAllowList:      strings.Split(testRecordOriginAllowList, ","),
+		RecordMaxFileSizeMB:        testRecordMaxFileSizeMB,
+		RecordRotateSeconds:        testRecordRotateSeconds,
+		RecordMaxDiskMB:            testRecordMaxDiskMB,
+		RecordDurationSeconds:      testRecordDuration,
+		GeneratorDeployments:       testGeneratorDeployments,
+		GeneratorInstances:         testGeneratorInstances,
+		GeneratorMetrics:           testGeneratorMetrics,
+		GeneratorApps:              testGeneratorApps,
+		GeneratorRate:              testGeneratorRate,
+		GeneratorOrigins:           strings.Split(testGeneratorOrigins, ","),
+		SubscriptionID:             testSubscriptionID,
+		DisableAccessControl:       testDisableAccessControl,
+		InsecureSSLSkipVerify:      testInsecureSSLSkipVerify,
+		CACertLocation:             testCACertLocation,
+		ClientCertLocation:         testClientCertLocation,
+		ClientKeyLocation:          testClientKeyLocation,
+		ProxyURL:                   testProxyURL,
+		ProxyUsername:              testProxyUsername,
+		ProxyPassword:              testProxyPassword,
+		IdleTimeoutSeconds:         testIdleTimeout,
+		MetricCacheDurationSeconds: testMetricCacheDuration,
+		WebServerPort:              testWebServerPort,
+		WebServerUseSSL:            testWebServerUseSSL,
+		WebServerCertLocation:      testWebServerCertLocation,
+		WebServerKeyLocation:       testWebServerKeyLocation,
+		AppLatencyWindowSeconds:    testAppLatencyWindow,
+		EventLogSize:               testEventLogSize,
+		MaxReconnectAttempts:       testMaxReconnectAttempts,
+		MaxReconnectDelaySeconds:   testMaxReconnectDelay,
+		BackpressurePolicy:         testBackpressurePolicy,
+		MessageBufferSize:          testMessageBufferSize,
+		StreamCount:                testStreamCount,
+		ReadinessTimeoutSeconds:    testReadinessTimeout,
+		PrometheusAuth:             testPrometheusAuth,
+		PrometheusUsername:         testPrometheusUsername,
+		PrometheusPassword:         testPrometheusPassword,
+		PrometheusBearerToken:      testPrometheusBearerToken,
+		GraphiteAddress:            testGraphiteAddress,
+		GraphitePathTemplate:       testGraphitePathTemplate,
+		GraphiteFlushSeconds:       testGraphiteFlushSeconds,
+		StatsdAddress:              testStatsdAddress,
+		StatsdMode:                 testStatsdMode,
+		StatsdPrefix:               testStatsdPrefix,
+		SourceIDAllowList:          strings.Split(testSourceIDAllowList, ","),
+		OriginDenyList:             strings.Split(testOriginDenyList, ","),
+	}
 
 	messageBytes, _ := json.Marshal(message)
 
@@ -0,0 +1,149 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package eventlog
+
+import (
+	"sort"
+	"sync"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultMaxEvents = 1000
+)
+
+//EventLog keeps the most recent loggregator events ordered by timestamp
+type EventLog struct {
+	sync.RWMutex
+	maxEvents int
+	logger    *gosteno.Logger
+	events    []*Event
+	sequence  uint64
+}
+
+//Event represents a single loggregator event such as a BOSH alert or an app crash
+type Event struct {
+	Sequence   uint64 // order the event was added in, unique where timestamps are not
+	Timestamp  int64
+	SourceID   string
+	InstanceID string
+	Title      string
+	Body       string
+	Tags       map[string]string
+}
+
+var instance *EventLog
+var once sync.Once
+
+//GetInstance retrieves the singleton event log
+func GetInstance() *EventLog {
+	return instance
+}
+
+func CreateInstance(logger *gosteno.Logger, maxEvents int) {
+	once.Do(func() {
+		if logger == nil {
+			panic("Event log initialized without logger")
+		}
+		instance = createEventLog(logger, maxEvents)
+	})
+}
+
+//AddEvent stores an event envelope, the oldest event is dropped once the log is full
+func (l *EventLog) AddEvent(e *loggregator_v2.Envelope) {
+	ev := e.GetEvent()
+	if ev == nil {
+		return
+	}
+
+	event := &Event{
+		Timestamp:  e.GetTimestamp(),
+		SourceID:   e.GetSourceId(),
+		InstanceID: e.GetInstanceId(),
+		Title:      ev.GetTitle(),
+		Body:       ev.GetBody(),
+		Tags:       e.GetTags(),
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	l.sequence++
+	event.Sequence = l.sequence
+
+	// events mostly arrive in order so search back from the newest
+	i := len(l.events)
+	for i > 0 && l.events[i-1].Timestamp > event.Timestamp {
+		i--
+	}
+
+	l.events = append(l.events, nil)
+	copy(l.events[i+1:], l.events[i:])
+	l.events[i] = event
+
+	if len(l.events) > l.maxEvents {
+		l.events = l.events[len(l.events)-l.maxEvents:]
+	}
+
+	l.logger.Debugf("Adding Event Title %s from source %s", event.Title, event.SourceID)
+}
+
+//GetEvents returns events with a timestamp after since, oldest first, up to limit events. A limit of 0 returns every event.
+func (l *EventLog) GetEvents(since int64, limit int) []*Event {
+	l.RLock()
+	defer l.RUnlock()
+
+	start := sort.Search(len(l.events), func(i int) bool {
+		return l.events[i].Timestamp > since
+	})
+
+	end := len(l.events)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+
+	events := make([]*Event, end-start)
+	copy(events, l.events[start:end])
+	return events
+}
+
+//GetEventsAfter returns events added after the event with the sequence after, in the order they were added, up to limit events.
+//A limit of 0 returns every event.
+func (l *EventLog) GetEventsAfter(after uint64, limit int) []*Event {
+	l.RLock()
+	defer l.RUnlock()
+
+	var events []*Event
+	for _, event := range l.events {
+		if event.Sequence > after {
+			events = append(events, event)
+		}
+	}
+
+	// late events are stored by timestamp before events added earlier
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Sequence < events[j].Sequence
+	})
+
+	if limit > 0 && limit < len(events) {
+		events = events[:limit]
+	}
+	return events
+}
+
+func createEventLog(logger *gosteno.Logger, maxEvents int) *EventLog {
+	if maxEvents <= 0 {
+		maxEvents = defaultMaxEvents
+	}
+
+	l := &EventLog{
+		maxEvents: maxEvents,
+		logger:    logger,
+	}
+	l.logger.Info("Built Event Log")
+
+	return l
+}
@@ -0,0 +1,179 @@
+package eventlog
+
+import (
+	"testing"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/logger"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultLogDirectory = "../logs"
+	eventLogFile        = "eventlog.log"
+	eventLogName        = "eventlog"
+	eventLogLevel       = "debug"
+)
+
+var testLogger *gosteno.Logger
+
+func GetTestLogger() *gosteno.Logger {
+	if testLogger == nil {
+		logger.CreateLogDirectory(defaultLogDirectory)
+		testLogger = logger.New(defaultLogDirectory, eventLogFile, eventLogName, eventLogLevel)
+	}
+
+	return testLogger
+}
+
+func TestCreateEventLog(t *testing.T) {
+	testCases := []struct {
+		testName  string
+		maxEvents int
+		want      int
+	}{
+		{testName: "Default Size", maxEvents: 0, want: defaultMaxEvents},
+		{testName: "Configured Size", maxEvents: 10, want: 10},
+	}
+
+	for _, tc := range testCases {
+		l := createEventLog(GetTestLogger(), tc.maxEvents)
+
+		if l.maxEvents != tc.want {
+			t.Errorf("Test Case %s returned max events %d expected %d", tc.testName, l.maxEvents, tc.want)
+		}
+	}
+}
+
+func TestAddEventIgnoresOtherEnvelopes(t *testing.T) {
+	l := createEventLog(GetTestLogger(), 10)
+
+	l.AddEvent(&loggregator_v2.Envelope{
+		Timestamp: 1,
+		Message: &loggregator_v2.Envelope_Counter{
+			Counter: &loggregator_v2.Counter{Name: "counter", Total: 1},
+		},
+	})
+
+	if len(l.events) != 0 {
+		t.Errorf("Expected no events, got %d", len(l.events))
+	}
+}
+
+func TestEventsAreTimeOrdered(t *testing.T) {
+	l := createEventLog(GetTestLogger(), 10)
+
+	for _, timestamp := range []int64{10, 30, 20, 5} {
+		l.AddEvent(newEventEnvelope(timestamp))
+	}
+
+	events := l.GetEvents(0, 0)
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 events, got %d", len(events))
+	}
+
+	for i, want := range []int64{5, 10, 20, 30} {
+		if events[i].Timestamp != want {
+			t.Errorf("Expected event %d to have timestamp %d, got %d", i, want, events[i].Timestamp)
+		}
+	}
+
+	if events[0].Title != "title" || events[0].Body != "body" || events[0].SourceID != "source" {
+		t.Errorf("Event not stored correctly %v", events[0])
+	}
+}
+
+func TestEventLogIsBounded(t *testing.T) {
+	l := createEventLog(GetTestLogger(), 3)
+
+	for timestamp := int64(1); timestamp <= 5; timestamp++ {
+		l.AddEvent(newEventEnvelope(timestamp))
+	}
+
+	events := l.GetEvents(0, 0)
+	if len(events) != 3 || events[0].Timestamp != 3 || events[2].Timestamp != 5 {
+		t.Errorf("Expected the 3 newest events, got %v", events)
+	}
+}
+
+func TestGetEventsSinceAndLimit(t *testing.T) {
+	l := createEventLog(GetTestLogger(), 10)
+
+	for timestamp := int64(1); timestamp <= 5; timestamp++ {
+		l.AddEvent(newEventEnvelope(timestamp))
+	}
+
+	testCases := []struct {
+		testName string
+		since    int64
+		limit    int
+		want     []int64
+	}{
+		{testName: "Since", since: 3, limit: 0, want: []int64{4, 5}},
+		{testName: "Limit", since: 0, limit: 2, want: []int64{1, 2}},
+		{testName: "Since And Limit", since: 1, limit: 2, want: []int64{2, 3}},
+		{testName: "Since Newest", since: 5, limit: 0, want: []int64{}},
+	}
+
+	for _, tc := range testCases {
+		events := l.GetEvents(tc.since, tc.limit)
+
+		if len(events) != len(tc.want) {
+			t.Errorf("Test Case %s returned %d events expected %d", tc.testName, len(events), len(tc.want))
+			continue
+		}
+
+		for i, want := range tc.want {
+			if events[i].Timestamp != want {
+				t.Errorf("Test Case %s returned timestamp %d expected %d", tc.testName, events[i].Timestamp, want)
+			}
+		}
+	}
+}
+
+func TestGetEventsAfter(t *testing.T) {
+	l := createEventLog(GetTestLogger(), 10)
+
+	for _, timestamp := range []int64{1, 2, 2, 2, 3} {
+		l.AddEvent(newEventEnvelope(timestamp))
+	}
+
+	page := l.GetEventsAfter(0, 2)
+	if len(page) != 2 || page[0].Timestamp != 1 || page[1].Timestamp != 2 {
+		t.Fatalf("Expected the first 2 events, got %v", page)
+	}
+
+	t.Log("Checking events sharing the last timestamp of a page are not lost...")
+	page = l.GetEventsAfter(page[1].Sequence, 0)
+	if len(page) != 3 || page[0].Timestamp != 2 || page[1].Timestamp != 2 || page[2].Timestamp != 3 {
+		t.Errorf("Expected the remaining 3 events, got %v", page)
+	}
+
+	t.Log("Checking a late event is returned after the events added before it...")
+	l.AddEvent(newEventEnvelope(0))
+	page = l.GetEventsAfter(page[2].Sequence, 0)
+	if len(page) != 1 || page[0].Timestamp != 0 || page[0].Sequence != 6 {
+		t.Errorf("Expected the late event, got %v", page)
+	}
+
+	if page = l.GetEventsAfter(6, 0); len(page) != 0 {
+		t.Errorf("Expected no events after the newest, got %v", page)
+	}
+}
+
+func newEventEnvelope(timestamp int64) *loggregator_v2.Envelope {
+	return &loggregator_v2.Envelope{
+		Timestamp: timestamp,
+		SourceId:  "source",
+		Tags: map[string]string{
+			"origin": "bosh-system-metrics-forwarder",
+		},
+		Message: &loggregator_v2.Envelope_Event{
+			Event: &loggregator_v2.Event{
+				Title: "title",
+				Body:  "body",
+			},
+		},
+	}
+}
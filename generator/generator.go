@@ -0,0 +1,224 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package generator
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	messageBufferSize = 1000
+	tickInterval      = 10 * time.Millisecond
+	reportInterval    = 10 * time.Second
+
+	defaultDeployments = 1
+	defaultInstances   = 2
+	defaultMetrics     = 10
+	defaultApps        = 10
+	defaultRate        = 1000
+
+	goRouterOrigin = "gorouter"
+	httpTimerName  = "http"
+)
+
+const (
+	gaugeMetric = iota
+	counterMetric
+	timerMetric
+)
+
+//metric is a metric an origin emits, names past the end of an origin's catalogue get a numbered suffix
+type metric struct {
+	name string
+	kind int
+	unit string
+}
+
+//series is one metric of one instance of an origin, counters keep their running total
+type series struct {
+	origin     string
+	deployment string
+	job        string
+	index      string
+	ip         string
+	metric     metric
+	total      uint64
+}
+
+//Generator emits synthetic counter, gauge and timer envelopes for the origins the webserver serves at a fixed rate
+type Generator struct {
+	series   []*series
+	apps     []string
+	rate     uint64
+	random   *rand.Rand
+	next     int
+	logger   *gosteno.Logger
+	messages chan *loggregator_v2.Envelope
+}
+
+//New creates a generator for every combination of deployment, origin, instance and metric in the configuration
+func New(config *configuration.Configuration, logger *gosteno.Logger) *Generator {
+	deployments := valueOrDefault(config.GeneratorDeployments, defaultDeployments)
+	instances := valueOrDefault(config.GeneratorInstances, defaultInstances)
+	metrics := valueOrDefault(config.GeneratorMetrics, defaultMetrics)
+
+	origins := config.GeneratorOrigins
+	if len(origins) == 0 {
+		origins = defaultOrigins
+	}
+
+	g := &Generator{
+		rate:     uint64(valueOrDefault(config.GeneratorRate, defaultRate)),
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		logger:   logger,
+		messages: make(chan *loggregator_v2.Envelope, messageBufferSize),
+	}
+
+	for i := 0; i < valueOrDefault(config.GeneratorApps, defaultApps); i++ {
+		g.apps = append(g.apps, fmt.Sprintf("00000000-0000-4000-8000-%012d", i))
+	}
+
+	// origins sharing a job run on the same VMs, so they share addresses
+	jobs := map[string]int{}
+	for d := 0; d < deployments; d++ {
+		for _, origin := range origins {
+			job := jobOf(origin)
+			if _, ok := jobs[job]; !ok {
+				jobs[job] = len(jobs)
+			}
+
+			for i := 0; i < instances; i++ {
+				for m := 0; m < metrics; m++ {
+					g.series = append(g.series, &series{
+						origin:     origin,
+						deployment: fmt.Sprintf("cf-%d", d+1),
+						job:        job,
+						index:      fmt.Sprint(i),
+						ip:         fmt.Sprintf("10.%d.%d.%d", d, jobs[job], i),
+						metric:     metricOf(origin, m),
+					})
+				}
+			}
+		}
+	}
+
+	return g
+}
+
+//Start generates envelopes until ctx is cancelled, falling behind by at most a second when consumers cannot keep up
+func (g *Generator) Start(ctx context.Context) {
+	g.logger.Infof("Generating %d envelopes/sec across %d metric series", g.rate, len(g.series))
+
+	go func() {
+		defer close(g.messages)
+
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+
+		start := time.Now()
+		lastReport, lastSent := start, uint64(0)
+		var sent, skipped uint64
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				due := uint64(now.Sub(start).Seconds() * float64(g.rate))
+				if due-sent > g.rate {
+					skipped += due - sent - g.rate
+					sent = due - g.rate
+				}
+
+				for ; sent < due; sent++ {
+					select {
+					case g.messages <- g.envelope(now):
+					case <-ctx.Done():
+						return
+					}
+				}
+
+				if elapsed := now.Sub(lastReport); elapsed >= reportInterval {
+					g.report(sent-lastSent, skipped, elapsed)
+					lastReport, lastSent, skipped = now, sent, 0
+				}
+			}
+		}
+	}()
+}
+
+//Envelopes returns the generated envelopes
+func (g *Generator) Envelopes() <-chan *loggregator_v2.Envelope {
+	return g.messages
+}
+
+func (g *Generator) report(sent, skipped uint64, elapsed time.Duration) {
+	g.logger.Infof("Generated %.0f envelopes/sec of the target %d/sec", float64(sent)/elapsed.Seconds(), g.rate)
+	if skipped > 0 {
+		g.logger.Warnf("Skipped %d envelopes in the last %s because consumers did not keep up with the target rate", skipped, elapsed)
+	}
+}
+
+//envelope creates the next envelope, cycling through every series in turn
+func (g *Generator) envelope(now time.Time) *loggregator_v2.Envelope {
+	s := g.series[g.next]
+	g.next = (g.next + 1) % len(g.series)
+
+	e := &loggregator_v2.Envelope{
+		Timestamp: now.UnixNano(),
+		SourceId:  s.origin,
+		Tags: map[string]string{
+			"origin":     s.origin,
+			"deployment": s.deployment,
+			"job":        s.job,
+			"index":      s.index,
+			"ip":         s.ip,
+		},
+	}
+
+	switch s.metric.kind {
+	case gaugeMetric:
+		e.Message = &loggregator_v2.Envelope_Gauge{
+			Gauge: &loggregator_v2.Gauge{Metrics: map[string]*loggregator_v2.GaugeValue{
+				s.metric.name: {Unit: s.metric.unit, Value: g.random.Float64() * 100},
+			}},
+		}
+	case counterMetric:
+		delta := uint64(g.random.Intn(10))
+		s.total += delta
+		e.Message = &loggregator_v2.Envelope_Counter{
+			Counter: &loggregator_v2.Counter{Name: s.metric.name, Delta: delta, Total: s.total},
+		}
+	case timerMetric:
+		// gorouter tags each request with the app it was routed to and the response status
+		if s.origin == goRouterOrigin && s.metric.name == httpTimerName && len(g.apps) > 0 {
+			app := g.apps[g.random.Intn(len(g.apps))]
+			e.SourceId = app
+			e.Tags["app_id"] = app
+			e.Tags["status_code"] = statusCodes[g.random.Intn(len(statusCodes))]
+		}
+
+		duration := time.Duration(g.random.Intn(500)+1) * time.Millisecond
+		e.Message = &loggregator_v2.Envelope_Timer{
+			Timer: &loggregator_v2.Timer{Name: s.metric.name, Start: now.Add(-duration).UnixNano(), Stop: now.UnixNano()},
+		}
+	}
+
+	return e
+}
+
+func valueOrDefault(value uint32, defaultValue int) int {
+	if value == 0 {
+		return defaultValue
+	}
+	return int(value)
+}
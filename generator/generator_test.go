@@ -0,0 +1,178 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package generator
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/logger"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+	"github.com/golang/protobuf/jsonpb"
+)
+
+const (
+	defaultLogDirectory = "../logs"
+	generatorLogFile    = "generator.log"
+	generatorLogName    = "generator"
+	generatorLogLevel   = "debug"
+)
+
+var testLogger *gosteno.Logger
+
+func GetTestLogger() *gosteno.Logger {
+	if testLogger == nil {
+		logger.CreateLogDirectory(defaultLogDirectory)
+		testLogger = logger.New(defaultLogDirectory, generatorLogFile, generatorLogName, generatorLogLevel)
+	}
+
+	return testLogger
+}
+
+func TestGeneratorCardinality(t *testing.T) {
+	g := New(&configuration.Configuration{GeneratorDeployments: 2, GeneratorInstances: 3, GeneratorMetrics: 7}, GetTestLogger())
+
+	if expected := 2 * len(defaultOrigins) * 3 * 7; len(g.series) != expected {
+		t.Fatalf("Expected %d metric series, got %d", expected, len(g.series))
+	}
+
+	origins := map[string]bool{}
+	kinds := map[int]int{}
+	resources := map[string]bool{}
+	for range g.series {
+		e := g.envelope(time.Now())
+		origins[e.Tags["origin"]] = true
+		resources[e.Tags["deployment"]+"|"+e.Tags["job"]+"|"+e.Tags["index"]+"|"+e.Tags["ip"]] = true
+
+		switch {
+		case e.GetGauge() != nil:
+			kinds[gaugeMetric]++
+		case e.GetCounter() != nil:
+			kinds[counterMetric]++
+		case e.GetTimer() != nil:
+			kinds[timerMetric]++
+		}
+	}
+
+	if len(origins) != len(defaultOrigins) {
+		t.Errorf("Expected envelopes from all %d origins, got %d", len(defaultOrigins), len(origins))
+	}
+	if kinds[gaugeMetric] == 0 || kinds[counterMetric] == 0 || kinds[timerMetric] == 0 {
+		t.Errorf("Expected gauges, counters and timers, got %v", kinds)
+	}
+
+	// origins on the same job share a VM, so there are fewer resources than origin instances
+	if len(resources) >= 2*len(defaultOrigins)*3 {
+		t.Errorf("Expected origins to share job instances, got %d resources", len(resources))
+	}
+}
+
+func TestGeneratorMetricNames(t *testing.T) {
+	catalogue := catalogues[goRouterOrigin]
+
+	if m := metricOf(goRouterOrigin, 0); m.name != httpTimerName || m.kind != timerMetric {
+		t.Errorf("Expected the first gorouter metric to be the http timer, got %v", m)
+	}
+	if m := metricOf(goRouterOrigin, len(catalogue)+1); m.name != catalogue[1].name+".1" || m.kind != catalogue[1].kind {
+		t.Errorf("Expected metrics past the catalogue to be numbered, got %v", m)
+	}
+	if m := metricOf("sender", 0); m.name != commonMetrics[0].name {
+		t.Errorf("Expected origins without a catalogue to emit the common metrics, got %v", m)
+	}
+}
+
+func TestGeneratorHTTPTimers(t *testing.T) {
+	g := New(&configuration.Configuration{GeneratorOrigins: []string{goRouterOrigin}, GeneratorMetrics: 1, GeneratorApps: 3}, GetTestLogger())
+
+	e := g.envelope(time.Now())
+	tm := e.GetTimer()
+	if tm == nil || tm.GetName() != httpTimerName || tm.GetStop() <= tm.GetStart() {
+		t.Fatalf("Expected a gorouter http timer, got %v", e)
+	}
+	if e.Tags["app_id"] == "" || e.GetSourceId() != e.Tags["app_id"] || e.Tags["status_code"] == "" {
+		t.Errorf("Expected http timers tagged with the app and status code, got %v", e.Tags)
+	}
+}
+
+func TestGeneratorCounters(t *testing.T) {
+	g := New(&configuration.Configuration{GeneratorOrigins: []string{"DopplerServer"}, GeneratorInstances: 1, GeneratorMetrics: 1}, GetTestLogger())
+
+	var last uint64
+	for i := 0; i < 10; i++ {
+		c := g.envelope(time.Now()).GetCounter()
+		if c.GetTotal() != last+c.GetDelta() {
+			t.Fatalf("Expected counter total %d to grow by its delta %d from %d", c.GetTotal(), c.GetDelta(), last)
+		}
+		last = c.GetTotal()
+	}
+}
+
+func TestGeneratorRate(t *testing.T) {
+	g := New(&configuration.Configuration{GeneratorRate: 1000}, GetTestLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	g.Start(ctx)
+
+	count := 0
+	timeout := time.After(500 * time.Millisecond)
+	for reading := true; reading; {
+		select {
+		case <-g.Envelopes():
+			count++
+		case <-timeout:
+			reading = false
+		}
+	}
+
+	cancel()
+	for range g.Envelopes() {
+	}
+
+	if count < 300 || count > 700 {
+		t.Errorf("Expected about 500 envelopes in 500ms at 1000/sec, got %d", count)
+	}
+}
+
+func TestGeneratorHandler(t *testing.T) {
+	g := New(&configuration.Configuration{GeneratorRate: 1000}, GetTestLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g.Start(ctx)
+
+	server := httptest.NewServer(g.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v2/read")
+	if err != nil {
+		t.Fatalf("Error reading generated envelopes: %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected a server-sent event stream, got %s", resp.Header.Get("Content-Type"))
+	}
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "data: ") {
+		t.Fatalf("Expected a data line, got %q (%v)", line, err)
+	}
+
+	var batch loggregator_v2.EnvelopeBatch
+	if err := jsonpb.UnmarshalString(strings.TrimPrefix(line, "data: "), &batch); err != nil {
+		t.Fatalf("Error parsing envelope batch: %s", err.Error())
+	}
+	if len(batch.GetBatch()) == 0 || batch.GetBatch()[0].GetTags()["origin"] == "" {
+		t.Errorf("Expected generated envelopes in the batch, got %v", batch.GetBatch())
+	}
+	if !strings.Contains(line, `"source_id"`) {
+		t.Errorf("Expected the RLP gateway field names, got %s", line)
+	}
+}
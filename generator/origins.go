@@ -0,0 +1,110 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package generator
+
+import "fmt"
+
+//defaultOrigins are the origins the webserver serves, log_rate is left out because the nozzle counts it itself
+var defaultOrigins = []string{
+	"MetronAgent", "syslog_drain_binder", "tps_watcher", "tps_listener", "stager", "ssh-proxy", "sender",
+	"route_emitter", "rep", "receptor", "nsync_listener", "nsync_bulker", "garden-linux", "file_server",
+	"fetcher", "converger", "cc_uploader", "bbs", "auctioneer", "etcd", "DopplerServer", "cc",
+	"LoggregatorTrafficController", "gorouter", "locket",
+}
+
+//jobs are the bosh jobs that run each origin, origins missing here are their own job
+var jobs = map[string]string{
+	"MetronAgent":                  "diego_cell",
+	"rep":                          "diego_cell",
+	"garden-linux":                 "diego_cell",
+	"route_emitter":                "diego_cell",
+	"bbs":                          "diego_api",
+	"locket":                       "diego_api",
+	"auctioneer":                   "scheduler",
+	"ssh-proxy":                    "scheduler",
+	"tps_watcher":                  "scheduler",
+	"nsync_bulker":                 "scheduler",
+	"converger":                    "scheduler",
+	"syslog_drain_binder":          "scheduler",
+	"cc":                           "api",
+	"cc_uploader":                  "api",
+	"file_server":                  "api",
+	"nsync_listener":               "api",
+	"stager":                       "api",
+	"tps_listener":                 "api",
+	"receptor":                     "api",
+	"DopplerServer":                "doppler",
+	"LoggregatorTrafficController": "log_api",
+	"gorouter":                     "router",
+	"etcd":                         "etcd",
+}
+
+//catalogues are the metrics each origin emits first, origins missing here emit the common component metrics
+var catalogues = map[string][]metric{
+	"gorouter": {
+		{name: "http", kind: timerMetric},
+		{name: "total_requests", kind: counterMetric},
+		{name: "latency", kind: gaugeMetric, unit: "ms"},
+		{name: "total_routes", kind: gaugeMetric, unit: "count"},
+		{name: "responses.5xx", kind: counterMetric},
+	},
+	"rep": {
+		{name: "CapacityRemainingMemory", kind: gaugeMetric, unit: "MiB"},
+		{name: "CapacityTotalMemory", kind: gaugeMetric, unit: "MiB"},
+		{name: "ContainerCount", kind: gaugeMetric, unit: "count"},
+		{name: "RepBulkSyncDuration", kind: gaugeMetric, unit: "nanos"},
+		{name: "ContainerCreationDuration", kind: timerMetric},
+	},
+	"bbs": {
+		{name: "LRPsRunning", kind: gaugeMetric, unit: "count"},
+		{name: "LRPsDesired", kind: gaugeMetric, unit: "count"},
+		{name: "ConvergenceLRPDuration", kind: gaugeMetric, unit: "nanos"},
+		{name: "RequestCount", kind: counterMetric},
+		{name: "RequestLatency", kind: timerMetric},
+	},
+	"DopplerServer": {
+		{name: "ingress", kind: counterMetric},
+		{name: "dropped", kind: counterMetric},
+		{name: "subscriptions", kind: gaugeMetric, unit: "count"},
+		{name: "egress", kind: counterMetric},
+	},
+	"cc": {
+		{name: "requests.outstanding", kind: gaugeMetric, unit: "count"},
+		{name: "requests.completed", kind: counterMetric},
+		{name: "http_status.5XX", kind: counterMetric},
+		{name: "total_users", kind: gaugeMetric, unit: "count"},
+		{name: "request", kind: timerMetric},
+	},
+}
+
+var commonMetrics = []metric{
+	{name: "memoryStats.numBytesAllocated", kind: gaugeMetric, unit: "bytes"},
+	{name: "numGoRoutines", kind: gaugeMetric, unit: "count"},
+	{name: "requests", kind: counterMetric},
+	{name: "request", kind: timerMetric},
+}
+
+//statusCodes are weighted towards successful responses
+var statusCodes = []string{"200", "200", "200", "200", "200", "200", "200", "201", "404", "500"}
+
+func jobOf(origin string) string {
+	if job, ok := jobs[origin]; ok {
+		return job
+	}
+	return origin
+}
+
+//metricOf returns the nth metric of an origin, repeating its catalogue with numbered names to reach the configured cardinality
+func metricOf(origin string, n int) metric {
+	catalogue, ok := catalogues[origin]
+	if !ok {
+		catalogue = commonMetrics
+	}
+
+	m := catalogue[n%len(catalogue)]
+	if n >= len(catalogue) {
+		m.name = fmt.Sprintf("%s.%d", m.name, n/len(catalogue))
+	}
+	return m
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package generator
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/golang/protobuf/jsonpb"
+)
+
+const maxBatchSize = 100
+
+//Handler serves the generated envelopes the way the RLP gateway serves /v2/read, connections share the envelopes like nozzle streams share a subscription
+func (g *Generator) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		g.logger.Infof("Streaming envelopes to %s", r.RemoteAddr)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		m := &jsonpb.Marshaler{OrigName: true}
+		for {
+			batch, ok := g.readBatch(r.Context())
+			if !ok {
+				g.logger.Infof("Stopped streaming envelopes to %s", r.RemoteAddr)
+				return
+			}
+
+			if err := writeBatch(w, m, batch); err != nil {
+				g.logger.Warnf("Error streaming envelopes to %s: %s", r.RemoteAddr, err.Error())
+				return
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+//readBatch waits for an envelope then takes whatever else is ready, up to maxBatchSize
+func (g *Generator) readBatch(ctx context.Context) ([]*loggregator_v2.Envelope, bool) {
+	var batch []*loggregator_v2.Envelope
+
+	select {
+	case e, ok := <-g.messages:
+		if !ok {
+			return nil, false
+		}
+		batch = append(batch, e)
+	case <-ctx.Done():
+		return nil, false
+	}
+
+	for len(batch) < maxBatchSize {
+		select {
+		case e, ok := <-g.messages:
+			if !ok {
+				return batch, true
+			}
+			batch = append(batch, e)
+		default:
+			return batch, true
+		}
+	}
+	return batch, true
+}
+
+func writeBatch(w io.Writer, m *jsonpb.Marshaler, batch []*loggregator_v2.Envelope) error {
+	if _, err := io.WriteString(w, "data: "); err != nil {
+		return err
+	}
+	if err := m.Marshal(w, &loggregator_v2.EnvelopeBatch{Batch: batch}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n\n")
+	return err
+}
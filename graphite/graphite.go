@@ -0,0 +1,202 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package graphite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/results"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/ttlcache"
+
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultFlushInterval = 60 * time.Second
+	dialTimeout          = 5 * time.Second
+	writeTimeout         = 10 * time.Second
+	closedCheckTimeout   = time.Millisecond
+	maxBatchLines        = 500
+)
+
+//Exporter sends the newest value of every cached series to a Graphite plaintext listener every flush interval
+type Exporter struct {
+	sync.Mutex
+	address  string
+	template pathTemplate
+	interval time.Duration
+	logger   *gosteno.Logger
+	conn     net.Conn
+}
+
+//New creates an exporter for the GraphiteAddress, it connects on the first flush
+func New(config *configuration.Configuration, logger *gosteno.Logger) (*Exporter, error) {
+	template, err := parseTemplate(config.GraphitePathTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(config.GraphiteFlushSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+
+	return &Exporter{
+		address:  config.GraphiteAddress,
+		template: template,
+		interval: interval,
+		logger:   logger,
+	}, nil
+}
+
+//Start flushes the cache every interval until ctx is cancelled
+func (e *Exporter) Start(ctx context.Context) {
+	e.logger.Infof("Sending metrics to graphite %s every %s", e.address, e.interval)
+	go func() {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := e.Flush(now); err != nil {
+					e.logger.Warnf("Error sending metrics to graphite %s: %s", e.address, err.Error())
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+//Flush sends the newest value of every series in the cache, stamped with now
+func (e *Exporter) Flush(now time.Time) error {
+	return e.flush(ttlcache.GetInstance().GetOrigins(), now)
+}
+
+//Close sends the cache a last time so envelopes drained at shutdown are not lost, then closes the connection
+func (e *Exporter) Close() error {
+	err := e.Flush(time.Now())
+
+	e.Lock()
+	defer e.Unlock()
+	e.closeConn()
+	return err
+}
+
+func (e *Exporter) flush(origins map[string]map[string]*results.Resource, now time.Time) error {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.conn != nil && isClosed(e.conn) {
+		e.logger.Info("Graphite closed the connection, reconnecting")
+		e.closeConn()
+	}
+
+	var batch bytes.Buffer
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	lines, sent := 0, 0
+
+	for origin, resources := range origins {
+		for _, resource := range resources {
+			l := resource.Labels()
+			fields := map[string]string{
+				fieldOrigin:     origin,
+				fieldDeployment: l.Deployment,
+				fieldJob:        l.Job,
+				fieldIndex:      l.Index,
+				fieldIP:         l.IP,
+				fieldSourceID:   l.SourceID,
+			}
+
+			for _, s := range resource.Latest() {
+				// graphite can not store NaN or infinite values
+				if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+					continue
+				}
+
+				fields[fieldMetric] = s.Name
+				batch.WriteString(e.template.render(fields) + " " + strconv.FormatFloat(s.Value, 'f', -1, 64) + " " + timestamp + "\n")
+				lines++
+
+				if lines == maxBatchLines {
+					if err := e.send(batch.Bytes()); err != nil {
+						return err
+					}
+					sent += lines
+					lines = 0
+					batch.Reset()
+				}
+			}
+		}
+	}
+
+	if lines > 0 {
+		if err := e.send(batch.Bytes()); err != nil {
+			return err
+		}
+		sent += lines
+	}
+
+	e.logger.Debugf("Sent %d metrics to graphite %s", sent, e.address)
+	return nil
+}
+
+//send writes a batch, reconnecting once if the connection was lost since the last write
+func (e *Exporter) send(batch []byte) error {
+	err := e.write(batch)
+	if err == nil {
+		return nil
+	}
+
+	e.logger.Warnf("Error writing to graphite %s, reconnecting: %s", e.address, err.Error())
+	e.closeConn()
+	if err = e.write(batch); err != nil {
+		e.closeConn()
+	}
+	return err
+}
+
+// private utility funcs, methods using them are expected to have the mutex locked
+func (e *Exporter) write(batch []byte) error {
+	if e.conn == nil {
+		conn, err := net.DialTimeout("tcp", e.address, dialTimeout)
+		if err != nil {
+			return fmt.Errorf("Unable to connect: %s", err.Error())
+		}
+		e.logger.Infof("Connected to graphite %s", e.address)
+		e.conn = conn
+	}
+
+	e.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := e.conn.Write(batch)
+	return err
+}
+
+func (e *Exporter) closeConn() {
+	if e.conn != nil {
+		e.conn.Close()
+		e.conn = nil
+	}
+}
+
+//isClosed reads from the connection, carbon never writes to it so an error other than a timeout means it is gone
+func isClosed(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(closedCheckTimeout))
+	_, err := conn.Read(make([]byte, 1))
+	conn.SetReadDeadline(time.Time{})
+
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return false
+	}
+	return err != nil
+}
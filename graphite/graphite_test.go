@@ -0,0 +1,281 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package graphite
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/logger"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/results"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/ttlcache"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultConfigLocation = "../config/bluemedora-firehose-nozzle.json"
+
+	defaultLogDirectory = "../logs"
+	graphiteLogFile     = "graphite.log"
+	graphiteLogName     = "graphite"
+	graphiteLogLevel    = "debug"
+)
+
+var testLogger *gosteno.Logger
+
+func GetTestLogger() *gosteno.Logger {
+	if testLogger == nil {
+		logger.CreateLogDirectory(defaultLogDirectory)
+		testLogger = logger.New(defaultLogDirectory, graphiteLogFile, graphiteLogName, graphiteLogLevel)
+
+		//Built the way main builds the cache, so metrics expire after MetricCacheDurationSeconds
+		c, err := configuration.New(defaultConfigLocation, testLogger)
+		if err != nil {
+			panic("Error while loading configuration: " + err.Error())
+		}
+		ttlcache.CreateInstance(testLogger, time.Duration(c.MetricCacheDurationSeconds)*time.Second)
+	}
+
+	return testLogger
+}
+
+func TestParseTemplate(t *testing.T) {
+	testCases := []struct {
+		template string
+		err      string
+	}{
+		{template: ""},
+		{template: "cf.{origin}.{metric}"},
+		{template: "cf.{origin}", err: "must contain {metric}"},
+		{template: "cf.{host}.{metric}", err: "Unsupported field {host}"},
+		{template: "cf.{origin.{metric}", err: "Unsupported field {origin.{metric}"},
+		{template: "cf.{metric", err: "Unclosed field"},
+	}
+
+	for _, tc := range testCases {
+		_, err := parseTemplate(tc.template)
+		if tc.err == "" && err != nil {
+			t.Errorf("Expected template <%s> to parse, but received %s", tc.template, err.Error())
+		} else if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("Expected template <%s> to fail with %s, but received %v", tc.template, tc.err, err)
+		}
+	}
+}
+
+func TestRenderPath(t *testing.T) {
+	template, _ := parseTemplate("")
+
+	path := template.render(map[string]string{
+		fieldOrigin:     "gorouter",
+		fieldDeployment: "cf",
+		fieldJob:        "router",
+		fieldIndex:      "0",
+		fieldMetric:     "memoryStats.numBytesAllocated",
+	})
+	if path != "gorouter.cf.router.0.memoryStats.numBytesAllocated" {
+		t.Errorf("Expected the empty source_id segment to be dropped, but received %s", path)
+	}
+
+	template, _ = parseTemplate("cf.{origin}.{ip}.{metric}")
+	path = template.render(map[string]string{
+		fieldOrigin: "ssh proxy",
+		fieldIP:     "10.0.0.1",
+		fieldMetric: "requests/sec",
+	})
+	if path != "cf.ssh_proxy.10_0_0_1.requests_sec" {
+		t.Errorf("Expected field values to be sanitized, but received %s", path)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	listener := createListener(t)
+	defer listener.Close()
+	e := createExporter(t, listener.Addr().String())
+	defer e.Close()
+
+	now := time.Unix(1500000000, 0)
+	if err := e.flush(createTestOrigins(1), now); err != nil {
+		t.Fatalf("Error flushing to graphite: %s", err.Error())
+	}
+
+	conn := accept(t, listener)
+	defer conn.Close()
+	lines := readLines(t, conn, 3)
+	sort.Strings(lines)
+
+	expected := []string{
+		"gorouter.cf.router.0.memoryStats.numBytesAllocated.0 1024 1500000000",
+		"gorouter.cf.router.0.total_requests.0 500 1500000000",
+		"log_rate.app-guid.logs.out 12 1500000000",
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("Expected %s, but received %s", expected[i], lines[i])
+		}
+	}
+}
+
+func TestFlushFromCache(t *testing.T) {
+	listener := createListener(t)
+	defer listener.Close()
+	e := createExporter(t, listener.Addr().String())
+	defer e.Close()
+
+	g := newGauge("memoryStats.numBytesAllocated", 1024)
+	g.Tags = map[string]string{"origin": "gorouter", "deployment": "cf", "job": "router", "index": "0", "ip": "10.0.0.1"}
+	ttlcache.GetInstance().UpdateResources([]*loggregator_v2.Envelope{g})
+
+	now := time.Unix(1500000000, 0)
+	if err := e.Flush(now); err != nil {
+		t.Fatalf("Error flushing to graphite: %s", err.Error())
+	}
+
+	conn := accept(t, listener)
+	defer conn.Close()
+	expected := "gorouter.cf.router.0.memoryStats.numBytesAllocated 1024 1500000000"
+	if lines := readLines(t, conn, 1); len(lines) != 1 || lines[0] != expected {
+		t.Errorf("Expected the cached gauge %s, but received %v", expected, lines)
+	}
+}
+
+func TestFlushBatches(t *testing.T) {
+	listener := createListener(t)
+	defer listener.Close()
+	e := createExporter(t, listener.Addr().String())
+	defer e.Close()
+
+	series := 3 * maxBatchLines
+	if err := e.flush(createTestOrigins(maxBatchLines), time.Now()); err != nil {
+		t.Fatalf("Error flushing to graphite: %s", err.Error())
+	}
+
+	conn := accept(t, listener)
+	defer conn.Close()
+	if lines := readLines(t, conn, series); len(lines) != series {
+		t.Errorf("Expected %d lines sent in batches, but received %d", series, len(lines))
+	}
+}
+
+func TestFlushReconnects(t *testing.T) {
+	listener := createListener(t)
+	defer listener.Close()
+	e := createExporter(t, listener.Addr().String())
+	defer e.Close()
+
+	if err := e.flush(createTestOrigins(1), time.Now()); err != nil {
+		t.Fatalf("Error flushing to graphite: %s", err.Error())
+	}
+	first := accept(t, listener)
+	readLines(t, first, 3)
+	first.Close()
+
+	t.Log("Checking the exporter reconnects after graphite closes the connection...")
+	if err := e.flush(createTestOrigins(1), time.Now()); err != nil {
+		t.Fatalf("Error flushing to graphite after reconnecting: %s", err.Error())
+	}
+	second := accept(t, listener)
+	defer second.Close()
+	if lines := readLines(t, second, 3); len(lines) != 3 {
+		t.Errorf("Expected 3 lines after reconnecting, but received %d", len(lines))
+	}
+}
+
+func TestFlushWithoutListener(t *testing.T) {
+	listener := createListener(t)
+	address := listener.Addr().String()
+	listener.Close()
+
+	e := createExporter(t, address)
+	if err := e.flush(createTestOrigins(1), time.Now()); err == nil || !strings.Contains(err.Error(), "Unable to connect") {
+		t.Errorf("Expected an error connecting to a closed port, but received %v", err)
+	}
+}
+
+func createExporter(t *testing.T, address string) *Exporter {
+	e, err := New(&configuration.Configuration{GraphiteAddress: address}, GetTestLogger())
+	if err != nil {
+		t.Fatalf("Error creating graphite exporter: %s", err.Error())
+	}
+	return e
+}
+
+func createListener(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error creating listener: %s", err.Error())
+	}
+	return listener
+}
+
+func accept(t *testing.T, listener net.Listener) net.Conn {
+	listener.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Error accepting graphite connection: %s", err.Error())
+	}
+	return conn
+}
+
+func readLines(t *testing.T, conn net.Conn, count int) []string {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	var lines []string
+	for len(lines) < count {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Errorf("Error reading line %d of %d: %s", len(lines)+1, count, err.Error())
+			break
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	return lines
+}
+
+//createTestOrigins creates gorouter resources with a gauge and a counter per metric and a log rate resource with a counter per metric
+func createTestOrigins(metrics int) map[string]map[string]*results.Resource {
+	router := results.NewResource("cf", "router", "0", "10.0.0.1")
+	logRate := results.NewSourceResource("app-guid")
+
+	for i := 0; i < metrics; i++ {
+		router.AddMetric(newGauge(fmt.Sprintf("memoryStats.numBytesAllocated.%d", i), 1024), GetTestLogger(), time.Minute)
+		router.AddMetric(newCounter(fmt.Sprintf("total_requests.%d", i), 500), GetTestLogger(), time.Minute)
+		name := "logs.out"
+		if i > 0 {
+			name = fmt.Sprintf("logs.out.%d", i)
+		}
+		logRate.AddMetric(newCounter(name, 12), GetTestLogger(), time.Minute)
+	}
+
+	return map[string]map[string]*results.Resource{
+		"gorouter": {"router-0": router},
+		"log_rate": {"app-guid": logRate},
+	}
+}
+
+func newGauge(name string, value float64) *loggregator_v2.Envelope {
+	return &loggregator_v2.Envelope{
+		Timestamp: time.Now().UnixNano(),
+		Message: &loggregator_v2.Envelope_Gauge{
+			Gauge: &loggregator_v2.Gauge{Metrics: map[string]*loggregator_v2.GaugeValue{name: {Value: value}}},
+		},
+	}
+}
+
+func newCounter(name string, total uint64) *loggregator_v2.Envelope {
+	return &loggregator_v2.Envelope{
+		Timestamp: time.Now().UnixNano(),
+		Message: &loggregator_v2.Envelope_Counter{
+			Counter: &loggregator_v2.Counter{Name: name, Total: total},
+		},
+	}
+}
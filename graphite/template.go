@@ -0,0 +1,108 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package graphite
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const defaultPathTemplate = "{origin}.{deployment}.{job}.{index}.{source_id}.{metric}"
+
+//Fields a path template can use
+const (
+	fieldOrigin     = "origin"
+	fieldDeployment = "deployment"
+	fieldJob        = "job"
+	fieldIndex      = "index"
+	fieldIP         = "ip"
+	fieldSourceID   = "source_id"
+	fieldMetric     = "metric"
+)
+
+var templateFields = map[string]bool{
+	fieldOrigin:     true,
+	fieldDeployment: true,
+	fieldJob:        true,
+	fieldIndex:      true,
+	fieldIP:         true,
+	fieldSourceID:   true,
+	fieldMetric:     true,
+}
+
+//templatePart is either literal text or a field to fill in
+type templatePart struct {
+	literal string
+	field   string
+}
+
+//pathTemplate is a GraphitePathTemplate parsed once so rendering a path does not scan the template
+type pathTemplate []templatePart
+
+func parseTemplate(template string) (pathTemplate, error) {
+	if template == "" {
+		template = defaultPathTemplate
+	}
+
+	var parts pathTemplate
+	hasMetric := false
+	for rest := template; rest != ""; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			parts = append(parts, templatePart{literal: rest})
+			break
+		}
+		if open > 0 {
+			parts = append(parts, templatePart{literal: rest[:open]})
+		}
+
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("Unclosed field in GraphitePathTemplate <%s>", template)
+		}
+		field := rest[open+1 : open+end]
+		if !templateFields[field] {
+			return nil, fmt.Errorf("Unsupported field {%s} in GraphitePathTemplate <%s>", field, template)
+		}
+
+		hasMetric = hasMetric || field == fieldMetric
+		parts = append(parts, templatePart{field: field})
+		rest = rest[open+end+1:]
+	}
+
+	if !hasMetric {
+		return nil, fmt.Errorf("GraphitePathTemplate <%s> must contain {%s}", template, fieldMetric)
+	}
+	return parts, nil
+}
+
+//render fills in the fields, values are sanitized and empty path segments are dropped so unset fields leave no gaps
+func (t pathTemplate) render(fields map[string]string) string {
+	var b bytes.Buffer
+	for _, p := range t {
+		if p.field == "" {
+			b.WriteString(p.literal)
+		} else {
+			// dots in a metric name are kept as graphite hierarchy, in other fields they would split the path
+			b.WriteString(sanitize(fields[p.field], p.field == fieldMetric))
+		}
+	}
+
+	segments := strings.FieldsFunc(b.String(), func(r rune) bool { return r == '.' })
+	return strings.Join(segments, ".")
+}
+
+//sanitize replaces characters that would break the plaintext protocol or the path with underscores
+func sanitize(value string, keepDots bool) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == ':':
+			return r
+		case r == '.' && keepDots:
+			return r
+		}
+		return '_'
+	}, value)
+}
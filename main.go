@@ -4,13 +4,30 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/appcache"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/applatency"
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/eventlog"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/generator"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/graphite"
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/logger"
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/nozzle"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/replay"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/stats"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/statsd"
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/ttlcache"
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/webserver"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
 )
 
 const (
@@ -26,6 +43,9 @@ const (
 
 	cacheLogFile = "bm_cache.log"
 	cacheLogName = "bm_cache"
+
+	shutdownTimeout = 10 * time.Second
+	cacheBatchSize  = 1000
 )
 
 var (
@@ -37,28 +57,212 @@ func main() {
 	logger.CreateLogDirectory(defaultLogDirectory)
 	l := logger.New(defaultLogDirectory, nozzleLogFile, nozzleLogName, *logLevel)
 
-	cacheLogger := logger.New(defaultLogDirectory, cacheLogFile, cacheLogName, *logLevel)
-	ttlcache.CreateInstance(cacheLogger)
-
 	c, err := configuration.New(defaultConfigLocation, l)
 	if err != nil {
 		l.Fatalf("Error parsing config file: %s", err.Error())
 	}
 
+	cacheLogger := logger.New(defaultLogDirectory, cacheLogFile, cacheLogName, *logLevel)
+	ttlcache.CreateInstance(cacheLogger, time.Duration(c.MetricCacheDurationSeconds)*time.Second)
+	applatency.CreateInstance(cacheLogger, time.Duration(c.AppLatencyWindowSeconds)*time.Second)
+	eventlog.CreateInstance(cacheLogger, int(c.EventLogSize))
+	appcache.CreateInstance(cacheLogger, time.Duration(c.MetricCacheDurationSeconds)*time.Second)
+	stats.CreateInstance(cacheLogger)
+
 	wsl := logger.New(defaultLogDirectory, webserverLogFile, webserverLogName, *logLevel)
 	ws := webserver.New(c, wsl)
 	wsErrs := ws.Start()
 
-	n := *nozzle.New(c, l)
-	n.Start()
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	source, err := newEnvelopeSource(c, l)
+	if err != nil {
+		l.Fatalf("Error creating envelope source: %s", err.Error())
+	}
+	if status, ok := source.(webserver.NozzleStatusProvider); ok {
+		ws.SetNozzleStatusProvider(status)
+	}
+	source.Start(ctx)
+	messages := source.Envelopes()
+
+	registerStats(source)
+	stats.GetInstance().Start(ctx)
+
+	var sinks []io.Closer
+	if c.GraphiteAddress != "" {
+		exporter, err := graphite.New(c, l)
+		if err != nil {
+			l.Fatalf("Error creating graphite exporter: %s", err.Error())
+		}
+		exporter.Start(ctx)
+		sinks = append(sinks, exporter)
+	}
+
+	var forwarder *statsd.Exporter
+	if c.StatsdAddress != "" {
+		forwarder, err = statsd.New(c, l)
+		if err != nil {
+			l.Fatalf("Error creating statsd exporter: %s", err.Error())
+		}
+		sinks = append(sinks, forwarder)
+	}
 
 	cache := ttlcache.GetInstance()
-	for {
+	latencyCache := applatency.GetInstance()
+	events := eventlog.GetInstance()
+	apps := appcache.GetInstance()
+	nozzleStats := stats.GetInstance()
+
+	batch := make([]*loggregator_v2.Envelope, 0, cacheBatchSize)
+	metrics := make([]*loggregator_v2.Envelope, 0, cacheBatchSize)
+
+	var drainTimeout <-chan time.Time
+	for draining := true; draining; {
 		select {
-		case m := <-n.Messages:
-			cache.UpdateResource(m)
-		case err := <-wsErrs:
-			l.Fatalf("Error while running webserver: %s", err.Error())
+		case m, ok := <-messages:
+			if !ok && signals != nil {
+				// a replay that has finished leaves its envelopes in the cache until the nozzle is stopped
+				l.Info("Envelope source finished, serving cached data until stopped")
+				messages = nil
+				break
+			}
+			if !ok {
+				draining = false
+				break
+			}
+
+			// envelopes already buffered are applied together so the cache lock is taken once per batch
+			batch = readBatch(append(batch[:0], m), messages)
+			nozzleStats.AddEnvelopes(batch)
+			if forwarder != nil {
+				forwarder.Forward(batch)
+			}
+			metrics = metrics[:0]
+			for _, m := range batch {
+				if m.GetEvent() != nil {
+					events.AddEvent(m)
+					continue
+				}
+				// container metrics belong to an app instance rather than the rep VM that emitted them
+				if apps.UpdateContainerMetrics(m) {
+					continue
+				}
+				metrics = append(metrics, m)
+			}
+
+			cache.UpdateResources(metrics)
+			for _, m := range metrics {
+				latencyCache.UpdateTimer(m)
+			}
+		case sig := <-signals:
+			// the source closes its envelopes once the stream has stopped and everything read is sent
+			l.Infof("Received %s, draining nozzle messages", sig)
+			cancel()
+			signals = nil
+			if messages == nil {
+				draining = false
+				break
+			}
+			drainTimeout = time.After(shutdownTimeout)
+		case <-drainTimeout:
+			l.Warnf("Timed out after %s draining nozzle messages", shutdownTimeout)
+			draining = false
+		case err, ok := <-wsErrs:
+			if ok {
+				l.Fatalf("Error while running webserver: %s", err.Error())
+			}
+			wsErrs = nil
 		}
 	}
+
+	shutdown(ws, sinks, l)
+}
+
+//readBatch adds the envelopes waiting in messages to batch, up to cacheBatchSize, without blocking
+func readBatch(batch []*loggregator_v2.Envelope, messages <-chan *loggregator_v2.Envelope) []*loggregator_v2.Envelope {
+	for len(batch) < cacheBatchSize {
+		select {
+		case m, ok := <-messages:
+			if !ok {
+				// the closed channel is seen again by the main loop
+				return batch
+			}
+			batch = append(batch, m)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+//registerStats reports the cache, and the nozzle's buffer, connections and token when reading from a foundation
+func registerStats(source nozzle.EnvelopeSource) {
+	s := stats.GetInstance()
+	s.RegisterGauges(func() map[string]float64 {
+		c := ttlcache.GetInstance().Stats()
+		return map[string]float64{
+			"cache_resources": float64(c.Resources),
+			"cache_series":    float64(c.Series),
+			"cache_samples":   float64(c.Samples),
+			"cache_sweep_ms":  float64(c.SweepDuration) / float64(time.Millisecond),
+		}
+	})
+
+	status, ok := source.(webserver.NozzleStatusProvider)
+	if !ok {
+		return
+	}
+
+	s.RegisterGauges(func() map[string]float64 {
+		return map[string]float64{"message_backlog": float64(status.Backpressure().Backlog)}
+	})
+	s.RegisterCounters(func() map[string]uint64 {
+		token := status.TokenStatus()
+		return map[string]uint64{
+			"envelopes_dropped":      status.Backpressure().Dropped,
+			"reconnects":             status.ConnectionStatus().Reconnects,
+			"token_refreshes":        token.Refreshes,
+			"token_refresh_failures": token.RefreshFailures,
+		}
+	})
+}
+
+//newEnvelopeSource creates the source for the configured InputMode
+func newEnvelopeSource(c *configuration.Configuration, l *gosteno.Logger) (nozzle.EnvelopeSource, error) {
+	switch c.InputMode {
+	case configuration.InputModeReplay:
+		r, err := replay.New(c, l)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	case configuration.InputModeGenerator:
+		return generator.New(c, l), nil
+	}
+
+	n, err := nozzle.New(c, l)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+//shutdown lets in-flight requests finish and closes the sinks before the process exits
+func shutdown(ws *webserver.WebServer, sinks []io.Closer, l *gosteno.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := ws.Shutdown(ctx); err != nil {
+		l.Warnf("Error shutting down webserver: %s", err.Error())
+	}
+
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			l.Warnf("Error closing sink: %s", err.Error())
+		}
+	}
+
+	l.Info("Blue Medora Firehose Nozzle stopped")
 }
@@ -0,0 +1,85 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+)
+
+const (
+	defaultMessageBufferSize = 10000
+	dropReportInterval       = 60 * time.Second
+)
+
+//BackpressureStatus reports how far the Messages consumer is behind the stream
+type BackpressureStatus struct {
+	Policy     string
+	BufferSize int
+	Backlog    int
+	Dropped    uint64
+}
+
+//Backpressure reports the backlog of Messages and the envelopes dropped because it was full
+func (n *Nozzle) Backpressure() BackpressureStatus {
+	return BackpressureStatus{
+		Policy:     n.policy,
+		BufferSize: cap(n.Messages),
+		Backlog:    len(n.Messages),
+		Dropped:    atomic.LoadUint64(&n.dropped),
+	}
+}
+
+//send hands an envelope to the Messages consumer following the configured backpressure policy
+func (n *Nozzle) send(e *loggregator_v2.Envelope) {
+	switch n.policy {
+	case configuration.BackpressureDropNewest:
+		select {
+		case n.Messages <- e:
+		default:
+			atomic.AddUint64(&n.dropped, 1)
+		}
+	case configuration.BackpressureDropOldest:
+		for {
+			select {
+			case n.Messages <- e:
+				return
+			default:
+			}
+
+			// make room, the consumer may have emptied a slot in the meantime
+			select {
+			case <-n.Messages:
+				atomic.AddUint64(&n.dropped, 1)
+			default:
+			}
+		}
+	default:
+		n.Messages <- e
+	}
+}
+
+func (n *Nozzle) reportDrops(ctx context.Context) {
+	ticker := time.NewTicker(dropReportInterval)
+	defer ticker.Stop()
+
+	var reported uint64
+	for {
+		select {
+		case <-ticker.C:
+			dropped := atomic.LoadUint64(&n.dropped)
+			if dropped > reported {
+				n.logger.Warnf("Dropped %d envelopes in the last %s because the message buffer of %d was full, %d dropped in total", dropped-reported, dropReportInterval, cap(n.Messages), dropped)
+				reported = dropped
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
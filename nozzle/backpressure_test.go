@@ -0,0 +1,147 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+
+	"code.cloudfoundry.org/go-loggregator"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+)
+
+func TestDropNewest(t *testing.T) {
+	n := createBackpressureNozzle(configuration.BackpressureDropNewest, 2)
+
+	for i := int64(1); i <= 5; i++ {
+		n.send(&loggregator_v2.Envelope{Timestamp: i})
+	}
+
+	status := n.Backpressure()
+	if status.Dropped != 3 || status.Backlog != 2 || status.BufferSize != 2 {
+		t.Errorf("Unexpected backpressure status %v", status)
+	}
+
+	if e := <-n.Messages; e.GetTimestamp() != 1 {
+		t.Errorf("Expected oldest envelope to be kept, got timestamp %d", e.GetTimestamp())
+	}
+}
+
+func TestDropOldest(t *testing.T) {
+	n := createBackpressureNozzle(configuration.BackpressureDropOldest, 2)
+
+	for i := int64(1); i <= 5; i++ {
+		n.send(&loggregator_v2.Envelope{Timestamp: i})
+	}
+
+	status := n.Backpressure()
+	if status.Dropped != 3 || status.Backlog != 2 {
+		t.Errorf("Unexpected backpressure status %v", status)
+	}
+
+	if e := <-n.Messages; e.GetTimestamp() != 4 {
+		t.Errorf("Expected newest envelopes to be kept, got timestamp %d", e.GetTimestamp())
+	}
+}
+
+func TestBlock(t *testing.T) {
+	n := createBackpressureNozzle(configuration.BackpressureBlock, 1)
+	n.send(&loggregator_v2.Envelope{Timestamp: 1})
+
+	sent := make(chan struct{})
+	go func() {
+		n.send(&loggregator_v2.Envelope{Timestamp: 2})
+		close(sent)
+	}()
+
+	select {
+	case <-sent:
+		t.Fatal("Expected send to block while the buffer is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-n.Messages
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("Expected send to complete once the buffer had room")
+	}
+
+	if status := n.Backpressure(); status.Dropped != 0 {
+		t.Errorf("Expected no drops when blocking, got %d", status.Dropped)
+	}
+}
+
+func TestNewBackpressureDefaults(t *testing.T) {
+	n := createNozzle(t, &configuration.Configuration{DisableAccessControl: true})
+
+	status := n.Backpressure()
+	if status.Policy != configuration.BackpressureBlock || status.BufferSize != defaultMessageBufferSize {
+		t.Errorf("Unexpected default backpressure status %v", status)
+	}
+}
+
+func TestEnvelopesReadAreRecorded(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+
+	n := createNozzle(t, &configuration.Configuration{
+		DisableAccessControl: true,
+		BackpressurePolicy:   configuration.BackpressureDropNewest,
+		MessageBufferSize:    1,
+		OriginAllowList:      []string{"rep"},
+		RecordDirectory:      dir,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batch := []*loggregator_v2.Envelope{
+		{Timestamp: 1, SourceId: "rep", Tags: map[string]string{"origin": "rep"}},
+		{Timestamp: 2, SourceId: "rep", Tags: map[string]string{"origin": "rep"}},
+		{Timestamp: 3, SourceId: "bbs", Tags: map[string]string{"origin": "bbs"}},
+		{Timestamp: 4, SourceId: "app-guid", Tags: map[string]string{"origin": "rep"}, Message: &loggregator_v2.Envelope_Log{Log: &loggregator_v2.Log{Payload: []byte("log")}}},
+	}
+	n.consume(ctx, newStream(0, batchStreamer{batch: batch, cancel: cancel}, nil))
+	n.sendLogCounts()
+	n.recorder.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if len(files) != 1 {
+		t.Fatalf("Expected a capture file, got %v", files)
+	}
+
+	// the dropped, filtered and log envelopes are recorded, the log rate counter the nozzle sends is not
+	data, _ := ioutil.ReadFile(files[0])
+	if lines := strings.Count(string(data), "\n"); lines != len(batch) {
+		t.Errorf("Expected every envelope read to be recorded, got %d", lines)
+	}
+}
+
+//batchStreamer returns a single batch and then cancels the stream
+type batchStreamer struct {
+	batch  []*loggregator_v2.Envelope
+	cancel context.CancelFunc
+}
+
+func (s batchStreamer) Stream(ctx context.Context, req *loggregator_v2.EgressBatchRequest) loggregator.EnvelopeStream {
+	return func() []*loggregator_v2.Envelope {
+		s.cancel()
+		return s.batch
+	}
+}
+
+func createBackpressureNozzle(policy string, size int) *Nozzle {
+	return &Nozzle{
+		logger:   createLogger(),
+		policy:   policy,
+		Messages: make(chan *loggregator_v2.Envelope, size),
+	}
+}
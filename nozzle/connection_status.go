@@ -0,0 +1,124 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+//Connection states reported by ConnectionStatus
+const (
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateReconnecting = "reconnecting"
+)
+
+const (
+	baseReconnectDelay       = 500 * time.Millisecond
+	defaultMaxReconnectDelay = 60 * time.Second
+
+	// a stream dropped sooner than this, such as a slow consumer kicked by the gateway, counts as a failed attempt
+	stableConnectionTime = 30 * time.Second
+)
+
+//ConnectionStatus is a snapshot of the nozzle's connection to the RLP gateway
+type ConnectionStatus struct {
+	State               string
+	ConsecutiveFailures uint32
+	Reconnects          uint64
+	LastError           string `json:",omitempty"`
+	LastConnected       time.Time
+	LastFailure         time.Time
+}
+
+//connectionTracker records connection attempts and computes the backoff between them
+type connectionTracker struct {
+	sync.Mutex
+	status   ConnectionStatus
+	maxDelay time.Duration
+	// failures before the current connection, restored if it does not stay up
+	unconfirmed uint32
+}
+
+func newConnectionTracker(maxDelay time.Duration) *connectionTracker {
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxReconnectDelay
+	}
+
+	return &connectionTracker{
+		status:   ConnectionStatus{State: StateConnecting},
+		maxDelay: maxDelay,
+	}
+}
+
+//attempt marks the start of a connection attempt, a stream that was connected has been dropped
+func (t *connectionTracker) attempt() {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.status.State != StateConnected {
+		return
+	}
+
+	t.status.State = StateReconnecting
+	t.status.Reconnects++
+
+	if up := time.Since(t.status.LastConnected); up < stableConnectionTime {
+		t.status.ConsecutiveFailures = t.unconfirmed + 1
+		t.status.LastError = fmt.Sprintf("stream dropped after %s", up)
+		t.status.LastFailure = time.Now()
+	}
+	t.unconfirmed = 0
+}
+
+func (t *connectionTracker) connected() {
+	t.Lock()
+	defer t.Unlock()
+
+	t.status.State = StateConnected
+	t.unconfirmed = t.status.ConsecutiveFailures
+	t.status.ConsecutiveFailures = 0
+	t.status.LastConnected = time.Now()
+}
+
+//failed records a failed attempt and returns the number of consecutive failures
+func (t *connectionTracker) failed(err error) uint32 {
+	t.Lock()
+	defer t.Unlock()
+
+	t.status.State = StateReconnecting
+	t.status.ConsecutiveFailures++
+	t.status.LastError = err.Error()
+	t.status.LastFailure = time.Now()
+	return t.status.ConsecutiveFailures
+}
+
+//backoff returns how long to wait before the next attempt, exponential with jitter
+func (t *connectionTracker) backoff() time.Duration {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.status.ConsecutiveFailures == 0 {
+		return 0
+	}
+
+	delay := t.maxDelay
+	if shift := t.status.ConsecutiveFailures - 1; shift < 32 {
+		if d := baseReconnectDelay << shift; d > 0 && d < t.maxDelay {
+			delay = d
+		}
+	}
+
+	// wait between half and the full delay so nozzles do not reconnect in lockstep
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (t *connectionTracker) snapshot() ConnectionStatus {
+	t.Lock()
+	defer t.Unlock()
+	return t.status
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnectionTrackerStates(t *testing.T) {
+	tracker := newConnectionTracker(time.Minute)
+
+	if status := tracker.snapshot(); status.State != StateConnecting {
+		t.Errorf("Expected initial state %s, got %s", StateConnecting, status.State)
+	}
+
+	tracker.attempt()
+	tracker.failed(errors.New("connection refused"))
+	tracker.attempt()
+	failures := tracker.failed(errors.New("connection refused"))
+
+	status := tracker.snapshot()
+	if failures != 2 || status.ConsecutiveFailures != 2 || status.State != StateReconnecting || status.LastError != "connection refused" {
+		t.Errorf("Unexpected status after failures %v", status)
+	}
+
+	tracker.attempt()
+	tracker.connected()
+
+	status = tracker.snapshot()
+	if status.State != StateConnected || status.ConsecutiveFailures != 0 || status.Reconnects != 0 {
+		t.Errorf("Unexpected status after connecting %v", status)
+	}
+
+	//A new attempt while connected means the stream was dropped
+	tracker.attempt()
+
+	status = tracker.snapshot()
+	if status.State != StateReconnecting || status.Reconnects != 1 {
+		t.Errorf("Unexpected status after dropped stream %v", status)
+	}
+}
+
+func TestConnectionTrackerDroppedStreams(t *testing.T) {
+	tracker := newConnectionTracker(time.Minute)
+
+	tracker.attempt()
+	tracker.failed(errors.New("connection refused"))
+	tracker.attempt()
+	tracker.connected()
+
+	//A stream dropped right after connecting is another failure, so the next attempt backs off
+	tracker.attempt()
+
+	status := tracker.snapshot()
+	if status.ConsecutiveFailures != 2 || status.LastError == "connection refused" {
+		t.Errorf("Unexpected status after a stream dropped right away %v", status)
+	}
+	if delay := tracker.backoff(); delay < baseReconnectDelay {
+		t.Errorf("Expected a backoff of at least %s after a dropped stream, got %s", baseReconnectDelay, delay)
+	}
+
+	//A stream that stayed up resets the failures
+	tracker.connected()
+	tracker.status.LastConnected = time.Now().Add(-stableConnectionTime)
+	tracker.attempt()
+
+	if status := tracker.snapshot(); status.ConsecutiveFailures != 0 {
+		t.Errorf("Expected no failures after a stable stream dropped, got %v", status)
+	}
+	if delay := tracker.backoff(); delay != 0 {
+		t.Errorf("Expected no backoff after a stable stream dropped, got %s", delay)
+	}
+}
+
+func TestConnectionTrackerBackoff(t *testing.T) {
+	maxDelay := 10 * time.Second
+	tracker := newConnectionTracker(maxDelay)
+
+	if delay := tracker.backoff(); delay != 0 {
+		t.Errorf("Expected no backoff before a failure, got %s", delay)
+	}
+
+	for i := uint(0); i < 40; i++ {
+		tracker.failed(errors.New("failure"))
+
+		want := maxDelay
+		if i < 5 {
+			want = baseReconnectDelay << i
+		}
+
+		delay := tracker.backoff()
+		if delay < want/2 || delay > want {
+			t.Errorf("Backoff after %d failures was %s, expected between %s and %s", i+1, delay, want/2, want)
+		}
+	}
+}
+
+func TestDefaultMaxReconnectDelay(t *testing.T) {
+	if tracker := newConnectionTracker(0); tracker.maxDelay != defaultMaxReconnectDelay {
+		t.Errorf("Expected max delay %s, got %s", defaultMaxReconnectDelay, tracker.maxDelay)
+	}
+}
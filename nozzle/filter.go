@@ -0,0 +1,98 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+)
+
+//envelopeFilter applies the source id and origin lists the RLP gateway can not select on, a deny list wins over an allow list
+type envelopeFilter struct {
+	sourceIDAllow map[string]bool
+	sourceIDDeny  map[string]bool
+	originAllow   map[string]bool
+	originDeny    map[string]bool
+}
+
+func newEnvelopeFilter(config *configuration.Configuration) *envelopeFilter {
+	return &envelopeFilter{
+		sourceIDAllow: toSet(config.SourceIDAllowList),
+		sourceIDDeny:  toSet(config.SourceIDDenyList),
+		originAllow:   toSet(config.OriginAllowList),
+		originDeny:    toSet(config.OriginDenyList),
+	}
+}
+
+//allows returns false for envelopes excluded by the configured lists
+func (f *envelopeFilter) allows(e *loggregator_v2.Envelope) bool {
+	sourceID, origin := e.GetSourceId(), e.GetTags()["origin"]
+
+	if f.sourceIDDeny[sourceID] || f.originDeny[origin] {
+		return false
+	}
+
+	if len(f.sourceIDAllow) > 0 && !f.sourceIDAllow[sourceID] {
+		return false
+	}
+
+	return len(f.originAllow) == 0 || f.originAllow[origin]
+}
+
+//selectors subscribes to every envelope type, limited to the allowed source ids when there are any
+func selectors(sourceIDs []string) []*loggregator_v2.Selector {
+	if len(sourceIDs) == 0 {
+		return typeSelectors("")
+	}
+
+	var s []*loggregator_v2.Selector
+	for _, id := range sourceIDs {
+		s = append(s, typeSelectors(id)...)
+	}
+	return s
+}
+
+func typeSelectors(sourceID string) []*loggregator_v2.Selector {
+	return []*loggregator_v2.Selector{
+		{
+			SourceId: sourceID,
+			Message: &loggregator_v2.Selector_Counter{
+				Counter: &loggregator_v2.CounterSelector{},
+			},
+		},
+		{
+			SourceId: sourceID,
+			Message: &loggregator_v2.Selector_Gauge{
+				Gauge: &loggregator_v2.GaugeSelector{},
+			},
+		},
+		{
+			SourceId: sourceID,
+			Message: &loggregator_v2.Selector_Timer{
+				Timer: &loggregator_v2.TimerSelector{},
+			},
+		},
+		{
+			SourceId: sourceID,
+			Message: &loggregator_v2.Selector_Event{
+				Event: &loggregator_v2.EventSelector{},
+			},
+		},
+		{
+			SourceId: sourceID,
+			Message: &loggregator_v2.Selector_Log{
+				Log: &loggregator_v2.LogSelector{},
+			},
+		},
+	}
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+)
+
+func TestEnvelopeFilter(t *testing.T) {
+	f := newEnvelopeFilter(&configuration.Configuration{
+		SourceIDAllowList: []string{"cc", "gorouter"},
+		SourceIDDenyList:  []string{"gorouter"},
+		OriginAllowList:   []string{"cc", "gorouter", "rep"},
+		OriginDenyList:    []string{"rep"},
+	})
+
+	cases := []struct {
+		sourceID string
+		origin   string
+		allowed  bool
+	}{
+		{"cc", "cc", true},
+		{"cc", "rep", false},
+		{"cc", "bbs", false},
+		{"gorouter", "gorouter", false},
+		{"bbs", "cc", false},
+	}
+
+	for _, c := range cases {
+		e := &loggregator_v2.Envelope{SourceId: c.sourceID, Tags: map[string]string{"origin": c.origin}}
+		if f.allows(e) != c.allowed {
+			t.Errorf("Expected source id %s origin %s allowed to be %v", c.sourceID, c.origin, c.allowed)
+		}
+	}
+
+	if !newEnvelopeFilter(&configuration.Configuration{}).allows(&loggregator_v2.Envelope{SourceId: "any"}) {
+		t.Error("Expected an empty filter to allow every envelope")
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	if s := selectors(nil); len(s) != 5 || s[0].GetSourceId() != "" {
+		t.Errorf("Expected one selector per envelope type without a source id, got %v", s)
+	}
+
+	s := selectors([]string{"cc", "gorouter"})
+	if len(s) != 10 {
+		t.Fatalf("Expected one selector per envelope type and source id, got %d", len(s))
+	}
+
+	if s[0].GetSourceId() != "cc" || s[9].GetSourceId() != "gorouter" {
+		t.Errorf("Unexpected source ids %s and %s", s[0].GetSourceId(), s[9].GetSourceId())
+	}
+}
+
+func TestNozzleFiltersEnvelopes(t *testing.T) {
+	query := make(chan []string, 1)
+	rlp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query <- r.URL.Query()["source_id"]
+
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, `data: {"batch":[`+
+			`{"source_id":"cc","tags":{"origin":"rep"},"gauge":{"metrics":{"a":{"value":1}}}},`+
+			`{"source_id":"cc","tags":{"origin":"cc"},"gauge":{"metrics":{"b":{"value":1}}}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer rlp.Close()
+
+	n := createNozzle(t, &configuration.Configuration{
+		RLPURL:               rlp.URL,
+		DisableAccessControl: true,
+		SourceIDAllowList:    []string{"gorouter", "cc"},
+		OriginDenyList:       []string{"rep"},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n.Start(ctx)
+
+	select {
+	case e := <-n.Messages:
+		if _, ok := e.GetGauge().GetMetrics()["b"]; !ok {
+			t.Errorf("Expected envelope from the allowed origin, got %v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for envelope")
+	}
+
+	sourceIDs := <-query
+	sort.Strings(sourceIDs)
+	if strings.Join(sourceIDs, ",") != "cc,gorouter" {
+		t.Errorf("Expected source ids to be selected on the RLP gateway, got %v", sourceIDs)
+	}
+
+	if status := n.Streams()[0]; status.Filtered != 1 {
+		t.Errorf("Expected 1 filtered envelope, got %d", status.Filtered)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+
+	"code.cloudfoundry.org/go-loggregator"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	firehoseHandshakeTimeout = 30 * time.Second
+	firehoseBufferSize       = 1000
+	firehoseBatchSize        = 100
+)
+
+//firehoseClient reads the v1 dropsonde firehose from the Traffic Controller websocket
+type firehoseClient struct {
+	url         string
+	dialer      *websocket.Dialer
+	httpClient  *nozzleHTTPClient
+	idleTimeout time.Duration
+}
+
+func newFirehoseClient(config *configuration.Configuration, hc *nozzleHTTPClient) *firehoseClient {
+	return &firehoseClient{
+		url: strings.TrimSuffix(config.TrafficControllerURL, "/") + "/firehose/" + url.PathEscape(config.SubscriptionID),
+		dialer: &websocket.Dialer{
+			Proxy:            hc.transport.Proxy,
+			TLSClientConfig:  hc.transport.TLSClientConfig,
+			HandshakeTimeout: firehoseHandshakeTimeout,
+		},
+		httpClient:  hc,
+		idleTimeout: time.Duration(config.IdleTimeoutSeconds) * time.Second,
+	}
+}
+
+//Stream reads the firehose until ctx is cancelled, the Traffic Controller has no selectors so req is not used
+func (c *firehoseClient) Stream(ctx context.Context, req *loggregator_v2.EgressBatchRequest) loggregator.EnvelopeStream {
+	envelopes := make(chan *loggregator_v2.Envelope, firehoseBufferSize)
+	go c.read(ctx, envelopes)
+
+	return func() []*loggregator_v2.Envelope {
+		select {
+		case e := <-envelopes:
+			batch := []*loggregator_v2.Envelope{e}
+			for len(batch) < firehoseBatchSize {
+				select {
+				case e := <-envelopes:
+					batch = append(batch, e)
+				default:
+					return batch
+				}
+			}
+			return batch
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (c *firehoseClient) read(ctx context.Context, envelopes chan<- *loggregator_v2.Envelope) {
+	for ctx.Err() == nil {
+		conn, err := c.dial(ctx)
+		if err != nil {
+			continue
+		}
+		c.readConnection(ctx, conn, envelopes)
+	}
+}
+
+//dial connects with the same backoff and connection tracking as the RLP gateway streams
+func (c *firehoseClient) dial(ctx context.Context) (*websocket.Conn, error) {
+	hc := c.httpClient
+	hc.connection.attempt()
+	if err := hc.waitForReconnect(ctx); err != nil {
+		return nil, err
+	}
+
+	conn, err := c.connect(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			hc.connectionFailed(err)
+		}
+		return nil, err
+	}
+
+	hc.logger.Infof("Stream %d connected to %s", hc.stream, hc.endpoint)
+	hc.connection.connected()
+	return conn, nil
+}
+
+func (c *firehoseClient) connect(ctx context.Context) (*websocket.Conn, error) {
+	tokens := c.httpClient.tokens
+	if tokens == nil {
+		conn, _, err := c.handshake(ctx, http.Header{})
+		return conn, err
+	}
+
+	token, err := tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, status, err := c.handshake(ctx, http.Header{"Authorization": []string{token}})
+	if status != http.StatusUnauthorized && status != http.StatusForbidden {
+		return conn, err
+	}
+
+	c.httpClient.logger.Warnf("%s rejected UAA token with status code %d", c.httpClient.endpoint, status)
+	token, err = tokens.Invalidate(token)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, _, err = c.handshake(ctx, http.Header{"Authorization": []string{token}})
+	return conn, err
+}
+
+//handshake returns the status code of a rejected upgrade so an expired token can be refreshed
+func (c *firehoseClient) handshake(ctx context.Context, header http.Header) (*websocket.Conn, int, error) {
+	conn, resp, err := c.dialer.DialContext(ctx, c.url, header)
+	if err == nil {
+		return conn, http.StatusSwitchingProtocols, nil
+	}
+
+	if resp == nil {
+		return nil, 0, fmt.Errorf("Failure to make websocket connection: %s", err.Error())
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	resp.Body.Close()
+	return nil, resp.StatusCode, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, body)
+}
+
+func (c *firehoseClient) readConnection(ctx context.Context, conn *websocket.Conn, envelopes chan<- *loggregator_v2.Envelope) {
+	// closing the connection unblocks ReadMessage once ctx is cancelled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+
+	hc := c.httpClient
+	for {
+		if c.idleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+		}
+
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() == nil {
+				hc.logger.Warnf("Stream %d lost %s connection: %s", hc.stream, hc.endpoint, err.Error())
+			}
+			return
+		}
+
+		var v1 events.Envelope
+		if err := proto.Unmarshal(data, &v1); err != nil {
+			hc.logger.Warnf("Stream %d received invalid dropsonde envelope: %s", hc.stream, err.Error())
+			continue
+		}
+
+		e := convertEnvelope(&v1)
+		if e == nil {
+			continue
+		}
+
+		select {
+		case envelopes <- e:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+//convertEnvelope converts value metrics and counter events to the envelopes the RLP gateway sends, other events are skipped
+func convertEnvelope(v1 *events.Envelope) *loggregator_v2.Envelope {
+	tags := map[string]string{}
+	for k, v := range v1.GetTags() {
+		tags[k] = v
+	}
+	tags["origin"] = v1.GetOrigin()
+	tags["deployment"] = v1.GetDeployment()
+	tags["job"] = v1.GetJob()
+	tags["index"] = v1.GetIndex()
+	tags["ip"] = v1.GetIp()
+
+	sourceID := tags["source_id"]
+	if sourceID == "" {
+		sourceID = v1.GetOrigin()
+	}
+
+	e := &loggregator_v2.Envelope{
+		Timestamp: v1.GetTimestamp(),
+		SourceId:  sourceID,
+		Tags:      tags,
+	}
+
+	switch v1.GetEventType() {
+	case events.Envelope_ValueMetric:
+		m := v1.GetValueMetric()
+		e.Message = &loggregator_v2.Envelope_Gauge{
+			Gauge: &loggregator_v2.Gauge{
+				Metrics: map[string]*loggregator_v2.GaugeValue{
+					m.GetName(): {Unit: m.GetUnit(), Value: m.GetValue()},
+				},
+			},
+		}
+	case events.Envelope_CounterEvent:
+		c := v1.GetCounterEvent()
+		e.Message = &loggregator_v2.Envelope_Counter{
+			Counter: &loggregator_v2.Counter{Name: c.GetName(), Delta: c.GetDelta(), Total: c.GetTotal()},
+		}
+	default:
+		return nil
+	}
+	return e
+}
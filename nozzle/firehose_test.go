@@ -0,0 +1,147 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gorilla/websocket"
+)
+
+func TestFirehoseInput(t *testing.T) {
+	var rejected int32
+	mux := http.NewServeMux()
+	mux.Handle("/oauth/token", &fakeUAA{clientID: "nozzle", clientSecret: "secret"})
+	mux.HandleFunc("/firehose/bluemedora-nozzle", func(w http.ResponseWriter, r *http.Request) {
+		// the first handshake is rejected as if the token had been revoked
+		if r.Header.Get("Authorization") != "bearer client-token" || atomic.AddInt32(&rejected, 1) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Error upgrading firehose connection: %s", err.Error())
+			return
+		}
+		defer conn.Close()
+
+		for _, e := range []*events.Envelope{
+			createV1Envelope(events.Envelope_LogMessage),
+			createV1Envelope(events.Envelope_ValueMetric),
+			createV1Envelope(events.Envelope_CounterEvent),
+		} {
+			data, err := proto.Marshal(e)
+			if err != nil {
+				t.Errorf("Error marshalling envelope: %s", err.Error())
+				return
+			}
+			conn.WriteMessage(websocket.BinaryMessage, data)
+		}
+		<-r.Context().Done()
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	n := createNozzle(t, &configuration.Configuration{
+		UAAURL:               server.URL,
+		UAAGrantType:         configuration.GrantTypeClientCredentials,
+		UAAClientID:          "nozzle",
+		UAAClientSecret:      "secret",
+		InputMode:            configuration.InputModeFirehose,
+		TrafficControllerURL: "ws" + server.URL[len("http"):],
+		SubscriptionID:       "bluemedora-nozzle",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n.Start(ctx)
+
+	gauge := readEnvelope(t, n)
+	if v := gauge.GetGauge().GetMetrics()["memoryStats.numBytesAllocated"]; v.GetValue() != 1024 || v.GetUnit() != "bytes" {
+		t.Errorf("Expected value metric to be converted to a gauge, got %v", gauge)
+	}
+
+	if gauge.GetSourceId() != "bbs" || gauge.Tags["origin"] != "bbs" || gauge.Tags["job"] != "diego_database" || gauge.Tags["index"] != "0" || gauge.Tags["ip"] != "10.0.16.5" {
+		t.Errorf("Expected dropsonde fields to be converted to tags, got %v", gauge.Tags)
+	}
+
+	counter := readEnvelope(t, n)
+	if c := counter.GetCounter(); c.GetName() != "requests" || c.GetDelta() != 2 || c.GetTotal() != 10 {
+		t.Errorf("Expected counter event to be converted to a counter, got %v", counter)
+	}
+
+	if status := n.ConnectionStatus(); status.State != StateConnected {
+		t.Errorf("Expected nozzle to be connected, got %s", status.State)
+	}
+}
+
+func TestConvertEnvelopeSourceIDTag(t *testing.T) {
+	v1 := createV1Envelope(events.Envelope_ValueMetric)
+	v1.Tags = map[string]string{"source_id": "app-guid"}
+
+	e := convertEnvelope(v1)
+	if e.GetSourceId() != "app-guid" || e.Tags["origin"] != "bbs" {
+		t.Errorf("Expected source id tag to be used as the source id, got %v", e)
+	}
+
+	if v1.Tags["origin"] != "" {
+		t.Error("Expected dropsonde tags not to be modified")
+	}
+}
+
+func createV1Envelope(eventType events.Envelope_EventType) *events.Envelope {
+	e := &events.Envelope{
+		Origin:     proto.String("bbs"),
+		EventType:  eventType.Enum(),
+		Timestamp:  proto.Int64(time.Now().UnixNano()),
+		Deployment: proto.String("cf"),
+		Job:        proto.String("diego_database"),
+		Index:      proto.String("0"),
+		Ip:         proto.String("10.0.16.5"),
+	}
+
+	switch eventType {
+	case events.Envelope_ValueMetric:
+		e.ValueMetric = &events.ValueMetric{
+			Name:  proto.String("memoryStats.numBytesAllocated"),
+			Value: proto.Float64(1024),
+			Unit:  proto.String("bytes"),
+		}
+	case events.Envelope_CounterEvent:
+		e.CounterEvent = &events.CounterEvent{
+			Name:  proto.String("requests"),
+			Delta: proto.Uint64(2),
+			Total: proto.Uint64(10),
+		}
+	case events.Envelope_LogMessage:
+		e.LogMessage = &events.LogMessage{
+			Message:     []byte("hello"),
+			MessageType: events.LogMessage_OUT.Enum(),
+			Timestamp:   proto.Int64(time.Now().UnixNano()),
+		}
+	}
+	return e
+}
+
+func readEnvelope(t *testing.T, n *Nozzle) *loggregator_v2.Envelope {
+	select {
+	case e := <-n.Messages:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for envelope")
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+)
+
+const (
+	//LogRateOrigin is the origin of the counters built from log envelopes
+	LogRateOrigin = "log_rate"
+
+	logRateFlushInterval = 10 * time.Second
+	logSourceIdleTimeout = 10 * time.Minute
+)
+
+//LogCounter counts log envelopes per source id and stream without keeping the log lines
+type LogCounter struct {
+	sync.Mutex
+	sources map[string]*logSource
+}
+
+type logSource struct {
+	totals   map[loggregator_v2.Log_Type]uint64
+	flushed  map[loggregator_v2.Log_Type]uint64
+	lastSeen time.Time
+}
+
+func NewLogCounter() *LogCounter {
+	return &LogCounter{
+		sources: make(map[string]*logSource),
+	}
+}
+
+//Count records a log envelope, it returns false for any other envelope type
+func (c *LogCounter) Count(e *loggregator_v2.Envelope) bool {
+	l := e.GetLog()
+	if l == nil {
+		return false
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	s, ok := c.sources[e.GetSourceId()]
+	if !ok {
+		s = &logSource{
+			totals:  make(map[loggregator_v2.Log_Type]uint64),
+			flushed: make(map[loggregator_v2.Log_Type]uint64),
+		}
+		c.sources[e.GetSourceId()] = s
+	}
+
+	s.totals[l.GetType()]++
+	s.lastSeen = time.Now()
+	return true
+}
+
+//Flush converts the log totals into counter envelopes, one per source id and stream
+func (c *LogCounter) Flush() []*loggregator_v2.Envelope {
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+	var envelopes []*loggregator_v2.Envelope
+	for sourceID, s := range c.sources {
+		if now.Sub(s.lastSeen) > logSourceIdleTimeout {
+			delete(c.sources, sourceID)
+			continue
+		}
+
+		for stream, total := range s.totals {
+			envelopes = append(envelopes, &loggregator_v2.Envelope{
+				Timestamp: now.UnixNano(),
+				SourceId:  sourceID,
+				Tags: map[string]string{
+					"origin": LogRateOrigin,
+				},
+				Message: &loggregator_v2.Envelope_Counter{
+					Counter: &loggregator_v2.Counter{
+						Name:  "logs." + strings.ToLower(stream.String()),
+						Delta: total - s.flushed[stream],
+						Total: total,
+					},
+				},
+			})
+			s.flushed[stream] = total
+		}
+	}
+
+	return envelopes
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"testing"
+	"time"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+)
+
+func TestLogCounterIgnoresOtherEnvelopes(t *testing.T) {
+	c := NewLogCounter()
+
+	if c.Count(&loggregator_v2.Envelope{
+		SourceId: "app",
+		Message: &loggregator_v2.Envelope_Counter{
+			Counter: &loggregator_v2.Counter{Name: "counter", Total: 1},
+		},
+	}) {
+		t.Error("Counted a counter envelope as a log")
+	}
+
+	if envelopes := c.Flush(); len(envelopes) != 0 {
+		t.Errorf("Expected no counters, got %d", len(envelopes))
+	}
+}
+
+func TestLogCounterFlush(t *testing.T) {
+	c := NewLogCounter()
+
+	for i := 0; i < 3; i++ {
+		c.Count(newLogEnvelope("app", loggregator_v2.Log_OUT))
+	}
+	c.Count(newLogEnvelope("app", loggregator_v2.Log_ERR))
+	c.Count(newLogEnvelope("other-app", loggregator_v2.Log_OUT))
+
+	counters := flushToMap(c)
+	if len(counters) != 3 {
+		t.Fatalf("Expected 3 counters, got %d", len(counters))
+	}
+
+	if out := counters["app logs.out"]; out.GetTotal() != 3 || out.GetDelta() != 3 {
+		t.Errorf("Expected app stdout total and delta of 3, got %v", out)
+	}
+
+	if err := counters["app logs.err"]; err.GetTotal() != 1 {
+		t.Errorf("Expected app stderr total of 1, got %v", err)
+	}
+
+	if out := counters["other-app logs.out"]; out.GetTotal() != 1 {
+		t.Errorf("Expected other-app stdout total of 1, got %v", out)
+	}
+
+	//Totals keep growing while deltas reset after each flush
+	c.Count(newLogEnvelope("app", loggregator_v2.Log_OUT))
+	counters = flushToMap(c)
+
+	if out := counters["app logs.out"]; out.GetTotal() != 4 || out.GetDelta() != 1 {
+		t.Errorf("Expected app stdout total of 4 and delta of 1, got %v", out)
+	}
+
+	if err := counters["app logs.err"]; err.GetTotal() != 1 || err.GetDelta() != 0 {
+		t.Errorf("Expected app stderr total of 1 and delta of 0, got %v", err)
+	}
+}
+
+func TestLogCounterDropsIdleSources(t *testing.T) {
+	c := NewLogCounter()
+	c.Count(newLogEnvelope("app", loggregator_v2.Log_OUT))
+	c.sources["app"].lastSeen = time.Now().Add(-2 * logSourceIdleTimeout)
+
+	if envelopes := c.Flush(); len(envelopes) != 0 {
+		t.Errorf("Expected idle source to be dropped, got %d counters", len(envelopes))
+	}
+
+	if len(c.sources) != 0 {
+		t.Error("Idle source was not removed")
+	}
+}
+
+func flushToMap(c *LogCounter) map[string]*loggregator_v2.Counter {
+	counters := make(map[string]*loggregator_v2.Counter)
+	for _, e := range c.Flush() {
+		if e.Tags["origin"] != LogRateOrigin {
+			continue
+		}
+		counters[e.GetSourceId()+" "+e.GetCounter().GetName()] = e.GetCounter()
+	}
+	return counters
+}
+
+func newLogEnvelope(sourceID string, logType loggregator_v2.Log_Type) *loggregator_v2.Envelope {
+	return &loggregator_v2.Envelope{
+		SourceId: sourceID,
+		Message: &loggregator_v2.Envelope_Log{
+			Log: &loggregator_v2.Log{
+				Payload: []byte("log line"),
+				Type:    logType,
+			},
+		},
+	}
+}
@@ -5,77 +5,193 @@ package nozzle
 
 import (
 	"context"
-	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/replay"
 
-	"code.cloudfoundry.org/go-loggregator"
 	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
-	"github.com/cloudfoundry-incubator/uaago"
 	"github.com/cloudfoundry/gosteno"
 )
 
 type Nozzle struct {
-	client   *loggregator.RLPGatewayClient
-	config   *configuration.Configuration
-	logger   *gosteno.Logger
-	Messages chan *loggregator_v2.Envelope
+	dropped    uint64 // accessed atomically, first to stay 64-bit aligned
+	streams    []*stream
+	httpClient *nozzleHTTPClient
+	config     *configuration.Configuration
+	logger     *gosteno.Logger
+	logCounter *LogCounter
+	filter     *envelopeFilter
+	recorder   *replay.Recorder
+	policy     string
+	Messages   chan *loggregator_v2.Envelope
 }
 
-func New(config *configuration.Configuration, logger *gosteno.Logger) *Nozzle {
+func New(config *configuration.Configuration, logger *gosteno.Logger) (*Nozzle, error) {
 	l := log.New(NewRLPLogger(logger),  /*prefix=*/"", log.LstdFlags)
 
-	c := loggregator.NewRLPGatewayClient(
-		config.RLPURL,
-		loggregator.WithRLPGatewayClientLogger(l),
-		loggregator.WithRLPGatewayHTTPClient(newNozzleHTTPClient(config, logger)),
-	)
+	// streams share the transport and UAA token but track their own connection
+	hc, err := newNozzleHTTPClient(config, logger)
+	if err != nil {
+		return nil, err
+	}
 
-	return &Nozzle{
-		client:   c,
-		config:   config,
-		logger:   logger,
-		Messages: make(chan *loggregator_v2.Envelope, 10000), //max 10k unprocessed envelopes. This is a reasonable limit we should be able to keep up with.
+	streamCount := int(config.StreamCount)
+	if streamCount <= 0 {
+		streamCount = 1
+	}
+
+	streams := make([]*stream, streamCount)
+	for i := range streams {
+		sc := hc.forStream(i)
+		if config.InputMode == configuration.InputModeFirehose {
+			streams[i] = newStream(i, newFirehoseClient(config, sc), sc)
+			continue
+		}
+		streams[i] = newStream(i, newRLPGatewayClient(config.RLPURL, l, sc), sc)
 	}
+
+	bufferSize := int(config.MessageBufferSize)
+	if bufferSize <= 0 {
+		bufferSize = defaultMessageBufferSize
+	}
+
+	policy := config.BackpressurePolicy
+	if policy == "" {
+		policy = configuration.BackpressureBlock
+	}
+
+	// recording is optional, support asks for a capture of the envelopes the foundation delivers
+	var recorder *replay.Recorder
+	if config.RecordDirectory != "" {
+		recorder, err = replay.NewRecorder(config, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Nozzle{
+		streams:    streams,
+		httpClient: hc,
+		config:     config,
+		logger:     logger,
+		logCounter: NewLogCounter(),
+		filter:     newEnvelopeFilter(config),
+		recorder:   recorder,
+		policy:     policy,
+		Messages:   make(chan *loggregator_v2.Envelope, bufferSize),
+	}, nil
 }
 
-// Start starts consuming events from firehose
-func (n *Nozzle) Start() {
-	n.logger.Info("Starting Blue Medora Firehose Nozzle")
+// Start starts consuming events from firehose until ctx is cancelled, Messages is closed once everything read has been sent
+func (n *Nozzle) Start(ctx context.Context) {
+	n.logger.Infof("Starting Blue Medora Firehose Nozzle with %d streams", len(n.streams))
+
+	var wg sync.WaitGroup
+	wg.Add(len(n.streams) + 1)
+	for _, s := range n.streams {
+		go func(s *stream) {
+			defer wg.Done()
+			n.consume(ctx, s)
+		}(s)
+	}
+
+	go func() {
+		defer wg.Done()
+		n.flushLogCounts(ctx)
+	}()
+
+	go n.reportDrops(ctx)
+	go n.sampleStreams(ctx)
+
+	if n.httpClient.tokens != nil {
+		n.httpClient.tokens.startRefreshing(ctx)
+	}
 
 	go func() {
-		es := n.envelopeStream()
-		for {
-			for _, e := range es() {
-				n.Messages <- e
+		wg.Wait()
+		if n.recorder != nil {
+			if err := n.recorder.Close(); err != nil {
+				n.logger.Warnf("Error closing envelope recording: %s", err.Error())
 			}
 		}
+		n.logger.Info("Stopped Blue Medora Firehose Nozzle")
+		close(n.Messages)
 	}()
 }
 
-func (n *Nozzle) envelopeStream() loggregator.EnvelopeStream {
-	ctx := context.Background()
-	return n.client.Stream(
-		ctx,
-		&loggregator_v2.EgressBatchRequest{
-			ShardId: n.config.SubscriptionID,
-			Selectors: []*loggregator_v2.Selector{
-				{
-					Message: &loggregator_v2.Selector_Counter{
-						Counter: &loggregator_v2.CounterSelector{},
-					},
-				},
-				{
-					Message: &loggregator_v2.Selector_Gauge{
-						Gauge: &loggregator_v2.GaugeSelector{},
-					},
-				},
-			},
-		},
-	)
+func (n *Nozzle) consume(ctx context.Context, s *stream) {
+	es := s.client.Stream(ctx, n.egressRequest())
+	for ctx.Err() == nil {
+		batch := s.read(es)
+		// a capture holds everything the foundation delivered, before it is filtered or counted
+		if n.recorder != nil {
+			for _, e := range batch {
+				n.recorder.Record(e)
+			}
+		}
+
+		for _, e := range batch {
+			if !n.filter.allows(e) {
+				s.countFiltered()
+				continue
+			}
+			// log lines are only counted, the counts reach the cache through flushLogCounts
+			if n.logCounter.Count(e) {
+				continue
+			}
+			n.send(e)
+		}
+	}
+}
+
+//TokenStatus reports the UAA token, it is not required when access control is disabled
+func (n *Nozzle) TokenStatus() TokenStatus {
+	if n.httpClient.tokens == nil {
+		return TokenStatus{Valid: true}
+	}
+	return n.httpClient.tokens.status()
+}
+
+//ConnectionStatus reports the combined state of the RLP gateway or Traffic Controller streams
+func (n *Nozzle) ConnectionStatus() ConnectionStatus {
+	return combineConnections(n.Streams())
+}
+
+func (n *Nozzle) flushLogCounts(ctx context.Context) {
+	ticker := time.NewTicker(logRateFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.sendLogCounts()
+		case <-ctx.Done():
+			// counts since the last tick would otherwise be lost on shutdown
+			n.sendLogCounts()
+			return
+		}
+	}
+}
+
+func (n *Nozzle) sendLogCounts() {
+	for _, e := range n.logCounter.Flush() {
+		n.send(e)
+	}
+}
+
+//egressRequest subscribes every stream to the same shard so the RLP gateway splits envelopes between them
+func (n *Nozzle) egressRequest() *loggregator_v2.EgressBatchRequest {
+	return &loggregator_v2.EgressBatchRequest{
+		ShardId:   n.config.SubscriptionID,
+		Selectors: selectors(n.config.SourceIDAllowList),
+	}
 }
 
 type RLPLogger struct {
@@ -93,63 +209,168 @@ func (l RLPLogger) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+const uaaRequestTimeout = 30 * time.Second
+
 type nozzleHTTPClient struct {
-	client *http.Client
-	config *configuration.Configuration
-	logger *gosteno.Logger
-	token  string
+	client     *http.Client
+	transport  *http.Transport
+	endpoint   string
+	config     *configuration.Configuration
+	logger     *gosteno.Logger
+	uaa        *uaaClient
+	tokens     *tokenManager
+	connection *connectionTracker
+	stream     int
 }
 
-func newNozzleHTTPClient(config *configuration.Configuration, logger *gosteno.Logger) *nozzleHTTPClient {
-	c := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: config.InsecureSSLSkipVerify,
-			},
-		},
+func newNozzleHTTPClient(config *configuration.Configuration, logger *gosteno.Logger) (*nozzleHTTPClient, error) {
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
 	}
 
-	return &nozzleHTTPClient{
-		client: c,
-		config: config,
-		logger: logger,
+	proxy, err := newProxyFunc(config)
+	if err != nil {
+		return nil, err
 	}
-}
 
-func (c *nozzleHTTPClient) fetchToken() string {
-	c.logger.Debug("Fetching UAA authenticaiton token")
+	// the RLP gateway or Traffic Controller and UAA share the transport so both use the same CA, client certificate and proxy
+	transport := &http.Transport{
+		Proxy:           proxy,
+		TLSClientConfig: tlsConfig,
+	}
 
-	authClient, uaaErr := uaago.NewClient(c.config.UAAURL)
-	if uaaErr != nil {
-		c.logger.Fatalf("Error creating UAA client: %s", uaaErr.Error())
+	endpoint := "RLP gateway"
+	if config.InputMode == configuration.InputModeFirehose {
+		endpoint = "Traffic Controller"
 	}
 
-	t, err := authClient.GetAuthToken(c.config.UAAUsername, c.config.UAAPassword, c.config.InsecureSSLSkipVerify)
+	hc := &nozzleHTTPClient{
+		client:     &http.Client{Transport: transport},
+		transport:  transport,
+		endpoint:   endpoint,
+		config:     config,
+		logger:     logger,
+		connection: newConnectionTracker(time.Duration(config.MaxReconnectDelaySeconds) * time.Second),
+	}
+
+	if !config.DisableAccessControl {
+		hc.uaa = newUAAClient(config, &http.Client{Transport: transport, Timeout: uaaRequestTimeout})
+		hc.tokens = newTokenManager(hc.fetchToken, logger)
+	}
+	return hc, nil
+}
+
+//forStream returns a client sharing the transport and UAA token that tracks its own stream connection
+func (c *nozzleHTTPClient) forStream(id int) *nozzleHTTPClient {
+	sc := *c
+	sc.stream = id
+	sc.connection = newConnectionTracker(time.Duration(c.config.MaxReconnectDelaySeconds) * time.Second)
+	return &sc
+}
+
+func (c *nozzleHTTPClient) fetchToken() (string, error) {
+	c.logger.Debugf("Fetching UAA authenticaiton token with %s grant", c.uaa.grantType)
+
+	t, err := c.uaa.GetAuthToken()
 	if err != nil {
-		c.logger.Fatalf("Failed to get oauth token: %s.", err.Error())
+		return "", fmt.Errorf("Failed to get oauth token: %s", err.Error())
 	}
 
 	c.logger.Debugf("Successfully fetched UAA authentication token <%s>", t)
-	return t
+	return t, nil
 }
 
+// Do is called by the RLP client for every stream connection attempt, failed attempts are retried with backoff
 func (c *nozzleHTTPClient) Do(req *http.Request) (*http.Response, error) {
-	if !c.config.DisableAccessControl && c.token == "" {
-		c.token = c.fetchToken()
+	// the attempt is recorded first so a stream that was dropped right after connecting backs off
+	c.connection.attempt()
+	if err := c.waitForReconnect(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		err = fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, body)
+	}
+
+	if err != nil {
+		c.connectionFailed(err)
+		return nil, err
+	}
+
+	c.logger.Infof("Stream %d connected to %s", c.stream, c.endpoint)
+	c.connection.connected()
+	return resp, nil
+}
+
+func (c *nozzleHTTPClient) do(req *http.Request) (*http.Response, error) {
+	if c.tokens == nil {
+		return c.send(req)
+	}
+
+	token, err := c.tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", token)
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
+		c.logger.Warnf("%s rejected UAA token with status code %d", c.endpoint, resp.StatusCode)
+
+		token, err = c.tokens.Invalidate(token)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", token)
+		return c.send(req)
 	}
 
-	req.Header.Set("Authorization", c.token)
+	return resp, nil
+}
+
+func (c *nozzleHTTPClient) send(req *http.Request) (*http.Response, error) {
 	resp, err := c.client.Do(req)
 	if err != nil {
-		c.logger.Fatalf("Failure to make http request. %s", err.Error())
+		return nil, fmt.Errorf("Failure to make http request: %s", err.Error())
 	}
+	return resp, nil
+}
+
+func (c *nozzleHTTPClient) connectionFailed(err error) {
+	failures := c.connection.failed(err)
+	c.logger.Warnf("Stream %d %s connection attempt %d failed: %s", c.stream, c.endpoint, failures, err.Error())
 
-	if !c.config.DisableAccessControl && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
-		time.Sleep(10 * time.Millisecond) // todo figure out why
-		c.token = c.fetchToken()
-		req.Header.Set("Authorization", c.token)
-		resp, err = c.client.Do(req)
+	if c.config.MaxReconnectAttempts > 0 && failures >= c.config.MaxReconnectAttempts {
+		c.logger.Fatalf("Giving up after %d failed %s connection attempts on stream %d: %s", failures, c.endpoint, c.stream, err.Error())
 	}
+}
 
-	return resp, err
+func (c *nozzleHTTPClient) waitForReconnect(ctx context.Context) error {
+	delay := c.connection.backoff()
+	if delay == 0 {
+		return nil
+	}
+
+	status := c.connection.snapshot()
+	c.logger.Infof("Reconnecting stream %d to %s in %s after %d failed attempts", c.stream, c.endpoint, delay, status.ConsecutiveFailures)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,189 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+
+	"github.com/cloudfoundry/gosteno"
+)
+
+func TestHTTPClientRecordsFailedConnections(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	c := createNozzleHTTPClient(t, &configuration.Configuration{
+		DisableAccessControl:     true,
+		MaxReconnectDelaySeconds: 1,
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Do(newStreamRequest(t, server.URL)); err == nil {
+			t.Error("Expected bad gateway response to fail")
+		}
+	}
+
+	if status := c.connection.snapshot(); status.ConsecutiveFailures != 2 || status.State != StateReconnecting {
+		t.Errorf("Unexpected status after failures %v", status)
+	}
+
+	resp, err := c.Do(newStreamRequest(t, server.URL))
+	if err != nil {
+		t.Fatalf("Expected connection to succeed, got %s", err.Error())
+	}
+	resp.Body.Close()
+
+	if status := c.connection.snapshot(); status.ConsecutiveFailures != 0 || status.State != StateConnected {
+		t.Errorf("Unexpected status after connecting %v", status)
+	}
+}
+
+func TestHTTPClientBacksOffWhenStreamsDrop(t *testing.T) {
+	//The gateway accepts each stream and closes it straight away
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	c := createNozzleHTTPClient(t, &configuration.Configuration{
+		DisableAccessControl:     true,
+		MaxReconnectDelaySeconds: 1,
+	})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		resp, err := c.Do(newStreamRequest(t, server.URL))
+		if err != nil {
+			t.Fatalf("Expected connection to succeed, got %s", err.Error())
+		}
+		resp.Body.Close()
+	}
+
+	// the second and third attempts wait at least half of 500ms and 1s
+	if elapsed, want := time.Since(start), baseReconnectDelay/2+baseReconnectDelay; elapsed < want {
+		t.Errorf("Expected dropped streams to back off for at least %s, but reconnected in %s", want, elapsed)
+	}
+	if status := c.connection.snapshot(); status.Reconnects != 2 {
+		t.Errorf("Expected 2 reconnects, got %v", status)
+	}
+}
+
+func TestHTTPClientReturnsTransportErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	c := createNozzleHTTPClient(t, &configuration.Configuration{DisableAccessControl: true})
+
+	if _, err := c.Do(newStreamRequest(t, url)); err == nil {
+		t.Error("Expected request to a closed server to fail")
+	}
+
+	if status := c.connection.snapshot(); status.ConsecutiveFailures != 1 || status.LastError == "" {
+		t.Errorf("Unexpected status after transport error %v", status)
+	}
+}
+
+func TestNozzleDrainsOnCancel(t *testing.T) {
+	rlp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, `data: {"batch":[{"source_id":"app","log":{"payload":"aGk=","type":"OUT"}},{"source_id":"app","gauge":{"metrics":{"cpu":{"value":1}}}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer rlp.Close()
+
+	n := createNozzle(t, &configuration.Configuration{
+		RLPURL:               rlp.URL,
+		DisableAccessControl: true,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.Start(ctx)
+
+	select {
+	case e := <-n.Messages:
+		if e.GetGauge() == nil {
+			t.Fatalf("Expected gauge envelope, got %v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for envelope")
+	}
+
+	cancel()
+
+	var counters int
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e, ok := <-n.Messages:
+			if !ok {
+				if counters != 1 {
+					t.Errorf("Expected log counts to be flushed on shutdown, got %d counters", counters)
+				}
+				return
+			}
+			if e.GetCounter().GetName() == "logs.out" {
+				counters++
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for Messages to close")
+		}
+	}
+}
+
+func newStreamRequest(t *testing.T, url string) *http.Request {
+	req, err := http.NewRequest(http.MethodGet, url+"/v2/read", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %s", err.Error())
+	}
+	return req
+}
+
+func createNozzle(t *testing.T, config *configuration.Configuration) *Nozzle {
+	n, err := New(config, createLogger())
+	if err != nil {
+		t.Fatalf("Error creating nozzle: %s", err.Error())
+	}
+	return n
+}
+
+func createNozzleHTTPClient(t *testing.T, config *configuration.Configuration) *nozzleHTTPClient {
+	c, err := newNozzleHTTPClient(config, createLogger())
+	if err != nil {
+		t.Fatalf("Error creating http client: %s", err.Error())
+	}
+	return c
+}
+
+func createLogger() *gosteno.Logger {
+	config := &gosteno.Config{
+		Sinks:     make([]gosteno.Sink, 1),
+		Level:     gosteno.LOG_INFO,
+		Codec:     gosteno.NewJsonCodec(),
+		EnableLOC: true,
+	}
+
+	config.Sinks[0] = gosteno.NewIOSink(os.Stdout)
+
+	gosteno.Init(config)
+	return gosteno.NewLogger("nozzle")
+}
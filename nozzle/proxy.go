@@ -0,0 +1,35 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+)
+
+//newProxyFunc routes UAA and RLP gateway requests through the configured proxy, or HTTP_PROXY, HTTPS_PROXY and NO_PROXY when there is none
+func newProxyFunc(config *configuration.Configuration) (func(*http.Request) (*url.URL, error), error) {
+	if config.ProxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(config.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse ProxyURL: %s", err.Error())
+	}
+
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+		return nil, fmt.Errorf("Unsupported ProxyURL scheme <%s>, expected http or https", proxyURL.Scheme)
+	}
+
+	// the transport sends the credentials in the URL as Proxy-Authorization, including on CONNECT
+	if config.ProxyUsername != "" {
+		proxyURL.User = url.UserPassword(config.ProxyUsername, config.ProxyPassword)
+	}
+
+	return http.ProxyURL(proxyURL), nil
+}
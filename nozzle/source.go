@@ -0,0 +1,21 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+)
+
+//EnvelopeSource produces the envelopes written to the caches, Envelopes is closed once the source has stopped after ctx is cancelled
+type EnvelopeSource interface {
+	Start(ctx context.Context)
+	Envelopes() <-chan *loggregator_v2.Envelope
+}
+
+//Envelopes returns the envelopes read from the RLP gateway or Traffic Controller
+func (n *Nozzle) Envelopes() <-chan *loggregator_v2.Envelope {
+	return n.Messages
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/go-loggregator"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+)
+
+const streamStatsInterval = 10 * time.Second
+
+//StreamStatus reports the connection and throughput of a single stream
+type StreamStatus struct {
+	ID                 int
+	Connection         ConnectionStatus
+	Envelopes          uint64
+	EnvelopesPerSecond float64
+	Filtered           uint64
+}
+
+//envelopeStreamer opens a stream of envelope batches that reconnects until ctx is cancelled
+type envelopeStreamer interface {
+	Stream(ctx context.Context, req *loggregator_v2.EgressBatchRequest) loggregator.EnvelopeStream
+}
+
+//stream is one of the RLP gateway or Traffic Controller connections sharing the nozzle's subscription
+type stream struct {
+	envelopes  uint64 // accessed atomically, first to stay 64-bit aligned
+	filtered   uint64 // accessed atomically
+	last       int64  // unix nanoseconds of the last envelope read, accessed atomically
+	id         int
+	client     envelopeStreamer
+	httpClient *nozzleHTTPClient
+
+	sync.Mutex
+	sampled uint64
+	rate    float64
+}
+
+func newStream(id int, client envelopeStreamer, hc *nozzleHTTPClient) *stream {
+	return &stream{
+		id:         id,
+		client:     client,
+		httpClient: hc,
+	}
+}
+
+func newRLPGatewayClient(url string, l *log.Logger, hc *nozzleHTTPClient) *loggregator.RLPGatewayClient {
+	return loggregator.NewRLPGatewayClient(
+		url,
+		loggregator.WithRLPGatewayClientLogger(l),
+		loggregator.WithRLPGatewayHTTPClient(hc),
+	)
+}
+
+//read returns the next batch of envelopes, or nil once ctx is cancelled
+func (s *stream) read(es loggregator.EnvelopeStream) []*loggregator_v2.Envelope {
+	batch := es()
+	atomic.AddUint64(&s.envelopes, uint64(len(batch)))
+	if len(batch) > 0 {
+		atomic.StoreInt64(&s.last, time.Now().UnixNano())
+	}
+	return batch
+}
+
+//filtered counts an envelope excluded by the source id and origin lists
+func (s *stream) countFiltered() {
+	atomic.AddUint64(&s.filtered, 1)
+}
+
+//sample updates the envelope rate over the time since the previous sample
+func (s *stream) sample(interval time.Duration) {
+	envelopes := atomic.LoadUint64(&s.envelopes)
+
+	s.Lock()
+	defer s.Unlock()
+	s.rate = float64(envelopes-s.sampled) / interval.Seconds()
+	s.sampled = envelopes
+}
+
+func (s *stream) status() StreamStatus {
+	s.Lock()
+	defer s.Unlock()
+
+	return StreamStatus{
+		ID:                 s.id,
+		Connection:         s.httpClient.connection.snapshot(),
+		Envelopes:          atomic.LoadUint64(&s.envelopes),
+		EnvelopesPerSecond: s.rate,
+		Filtered:           atomic.LoadUint64(&s.filtered),
+	}
+}
+
+//LastEnvelope is when any stream last read an envelope, zero if none have been.
+//The log rate counters the nozzle sends itself are not read from a stream, so they do not count.
+func (n *Nozzle) LastEnvelope() time.Time {
+	var last int64
+	for _, s := range n.streams {
+		if l := atomic.LoadInt64(&s.last); l > last {
+			last = l
+		}
+	}
+
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
+//Streams reports the connection and throughput of every stream
+func (n *Nozzle) Streams() []StreamStatus {
+	statuses := make([]StreamStatus, len(n.streams))
+	for i, s := range n.streams {
+		statuses[i] = s.status()
+	}
+	return statuses
+}
+
+func (n *Nozzle) sampleStreams(ctx context.Context) {
+	ticker := time.NewTicker(streamStatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, s := range n.streams {
+				s.sample(streamStatsInterval)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+//combineConnections summarises the stream connections, the nozzle is only connected once every stream is
+func combineConnections(statuses []StreamStatus) ConnectionStatus {
+	combined := ConnectionStatus{State: StateConnected}
+	if len(statuses) == 0 {
+		combined.State = StateConnecting
+	}
+
+	for _, s := range statuses {
+		c := s.Connection
+		switch {
+		case c.State == StateReconnecting:
+			combined.State = StateReconnecting
+		case c.State == StateConnecting && combined.State != StateReconnecting:
+			combined.State = StateConnecting
+		}
+
+		if c.ConsecutiveFailures > combined.ConsecutiveFailures {
+			combined.ConsecutiveFailures = c.ConsecutiveFailures
+		}
+		combined.Reconnects += c.Reconnects
+
+		if c.LastConnected.After(combined.LastConnected) {
+			combined.LastConnected = c.LastConnected
+		}
+		if c.LastFailure.After(combined.LastFailure) {
+			combined.LastFailure = c.LastFailure
+			combined.LastError = c.LastError
+		}
+	}
+	return combined
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+)
+
+func TestParallelStreams(t *testing.T) {
+	var connections int32
+	rlp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&connections, 1)
+		if r.URL.Query().Get("shard_id") != "nozzle" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, `data: {"batch":[{"source_id":"rep","gauge":{"metrics":{"capacity":{"value":1}}}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer rlp.Close()
+
+	n := createNozzle(t, &configuration.Configuration{
+		RLPURL:               rlp.URL,
+		SubscriptionID:       "nozzle",
+		DisableAccessControl: true,
+		StreamCount:          3,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n.Start(ctx)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-n.Messages:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for envelope %d", i)
+		}
+	}
+
+	if connections != 3 {
+		t.Errorf("Expected 3 stream connections, got %d", connections)
+	}
+
+	statuses := n.Streams()
+	if len(statuses) != 3 {
+		t.Fatalf("Expected 3 stream statuses, got %d", len(statuses))
+	}
+
+	for i, s := range statuses {
+		if s.ID != i || s.Envelopes != 1 || s.Connection.State != StateConnected {
+			t.Errorf("Unexpected status for stream %d %v", i, s)
+		}
+	}
+
+	if status := n.ConnectionStatus(); status.State != StateConnected {
+		t.Errorf("Expected nozzle to be connected, got %s", status.State)
+	}
+
+	if last := n.LastEnvelope(); time.Since(last) > 5*time.Second {
+		t.Errorf("Expected an envelope to have been read recently, got %s", last)
+	}
+}
+
+func TestLastEnvelopeIgnoresLogRates(t *testing.T) {
+	n := createNozzle(t, &configuration.Configuration{
+		RLPURL:               "http://127.0.0.1:0",
+		SubscriptionID:       "nozzle",
+		DisableAccessControl: true,
+	})
+
+	n.logCounter.Count(&loggregator_v2.Envelope{
+		SourceId: "app-guid",
+		Message:  &loggregator_v2.Envelope_Log{Log: &loggregator_v2.Log{Payload: []byte("log")}},
+	})
+	n.sendLogCounts()
+
+	if len(n.Messages) == 0 {
+		t.Fatal("Expected the log rate counters to be sent")
+	}
+	if last := n.LastEnvelope(); !last.IsZero() {
+		t.Errorf("Expected no envelope read from a stream, got %s", last)
+	}
+}
+
+func TestStreamSample(t *testing.T) {
+	s := &stream{}
+	atomic.AddUint64(&s.envelopes, 100)
+	s.sample(10 * time.Second)
+
+	atomic.AddUint64(&s.envelopes, 50)
+	s.sample(10 * time.Second)
+
+	if s.rate != 5 {
+		t.Errorf("Expected 5 envelopes per second, got %v", s.rate)
+	}
+}
+
+func TestCombineConnections(t *testing.T) {
+	now := time.Now()
+	statuses := []StreamStatus{
+		{Connection: ConnectionStatus{State: StateConnected, Reconnects: 2, LastConnected: now}},
+		{Connection: ConnectionStatus{State: StateReconnecting, ConsecutiveFailures: 3, Reconnects: 1, LastError: "refused", LastFailure: now}},
+		{Connection: ConnectionStatus{State: StateConnecting, ConsecutiveFailures: 1, LastError: "timeout", LastFailure: now.Add(-time.Minute)}},
+	}
+
+	combined := combineConnections(statuses)
+	if combined.State != StateReconnecting || combined.ConsecutiveFailures != 3 || combined.Reconnects != 3 || combined.LastError != "refused" || !combined.LastConnected.Equal(now) {
+		t.Errorf("Unexpected combined status %v", combined)
+	}
+
+	combined = combineConnections(statuses[:1])
+	if combined.State != StateConnected {
+		t.Errorf("Expected connected when every stream is connected, got %s", combined.State)
+	}
+
+	combined = combineConnections(append(statuses[:1:1], statuses[2]))
+	if combined.State != StateConnecting {
+		t.Errorf("Expected connecting while a stream has not connected, got %s", combined.State)
+	}
+}
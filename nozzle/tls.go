@@ -0,0 +1,47 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+)
+
+//newTLSConfig trusts the configured CA bundle on top of the system roots and presents the client certificate if there is one
+func newTLSConfig(config *configuration.Configuration) (*tls.Config, error) {
+	c := &tls.Config{
+		InsecureSkipVerify: config.InsecureSSLSkipVerify,
+	}
+
+	if config.CACertLocation != "" {
+		caPEM, err := ioutil.ReadFile(config.CACertLocation)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read CA certificate %s: %s", config.CACertLocation, err.Error())
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("No PEM certificates found in CA certificate %s", config.CACertLocation)
+		}
+		c.RootCAs = pool
+	}
+
+	if config.ClientCertLocation != "" || config.ClientKeyLocation != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertLocation, config.ClientKeyLocation)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load client certificate %s and key %s: %s", config.ClientCertLocation, config.ClientKeyLocation, err.Error())
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	return c, nil
+}
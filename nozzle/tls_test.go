@@ -0,0 +1,168 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+)
+
+func TestTLSConfigCABundle(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	c := createNozzleHTTPClient(t, &configuration.Configuration{DisableAccessControl: true})
+	if _, err := c.Do(newStreamRequest(t, server.URL)); err == nil {
+		t.Error("Expected a server signed by an unknown CA to fail verification")
+	}
+
+	caPath := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	c = createNozzleHTTPClient(t, &configuration.Configuration{DisableAccessControl: true, CACertLocation: caPath})
+
+	resp, err := c.Do(newStreamRequest(t, server.URL))
+	if err != nil {
+		t.Fatalf("Expected server signed by the CA bundle to be trusted, got %s", err.Error())
+	}
+	resp.Body.Close()
+}
+
+func TestTLSConfigClientCertificate(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+
+	certPath, keyPath, clientCert := writeClientCertificate(t, dir)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	//UAA and the RLP gateway both require the client certificate
+	mux := http.NewServeMux()
+	mux.Handle("/oauth/token", &fakeUAA{clientID: "nozzle", clientSecret: "secret"})
+	mux.HandleFunc("/v2/read", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "bearer client-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	server := httptest.NewUnstartedServer(mux)
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	caPath := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	config := &configuration.Configuration{
+		UAAURL:          server.URL,
+		UAAGrantType:    configuration.GrantTypeClientCredentials,
+		UAAClientID:     "nozzle",
+		UAAClientSecret: "secret",
+		CACertLocation:  caPath,
+	}
+
+	if _, err := createNozzleHTTPClient(t, config).Do(newStreamRequest(t, server.URL)); err == nil {
+		t.Error("Expected connection without a client certificate to fail")
+	}
+
+	config.ClientCertLocation = certPath
+	config.ClientKeyLocation = keyPath
+
+	resp, err := createNozzleHTTPClient(t, config).Do(newStreamRequest(t, server.URL))
+	if err != nil {
+		t.Fatalf("Expected connection with a client certificate to succeed, got %s", err.Error())
+	}
+	resp.Body.Close()
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+
+	notPEM := filepath.Join(dir, "not.pem")
+	ioutil.WriteFile(notPEM, []byte("not a certificate"), 0600)
+
+	configs := []configuration.Configuration{
+		{CACertLocation: filepath.Join(dir, "missing.pem")},
+		{CACertLocation: notPEM},
+		{ClientCertLocation: notPEM, ClientKeyLocation: notPEM},
+		{ClientCertLocation: filepath.Join(dir, "missing.pem")},
+	}
+
+	for _, config := range configs {
+		if _, err := newTLSConfig(&config); err == nil {
+			t.Errorf("Expected error creating TLS config from %v", config)
+		}
+	}
+}
+
+func writeClientCertificate(t *testing.T, dir string) (certPath, keyPath string, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating client key: %s", err.Error())
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "bluemedora-firehose-nozzle"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating client certificate: %s", err.Error())
+	}
+
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error parsing client certificate: %s", err.Error())
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Error marshalling client key: %s", err.Error())
+	}
+
+	return writePEM(t, dir, "client.pem", "CERTIFICATE", der), writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER), cert
+}
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("Error writing %s: %s", name, err.Error())
+	}
+	return path
+}
+
+func createTempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "nozzle")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err.Error())
+	}
+	return dir
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	tokenRefreshMargin    = 60 * time.Second
+	tokenRetryInterval    = 5 * time.Second
+	tokenNoExpiryInterval = 60 * time.Second
+)
+
+//TokenStatus reports the UAA token used to read from the RLP gateway or Traffic Controller
+type TokenStatus struct {
+	Required        bool
+	Valid           bool
+	Expiry          time.Time `json:",omitempty"`
+	Refreshes       uint64
+	RefreshFailures uint64
+	LastError       string `json:",omitempty"`
+}
+
+//tokenManager caches the UAA token and refreshes it before the token expires
+type tokenManager struct {
+	sync.Mutex
+	fetch     func() (string, error)
+	logger    *gosteno.Logger
+	token     string
+	fetchedAt time.Time
+	expiry    time.Time
+	lastErr   error
+	inflight  *tokenRefresh
+	refreshes uint64
+	failures  uint64
+}
+
+//tokenRefresh is a single fetch shared by every caller waiting on a new token
+type tokenRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+func newTokenManager(fetch func() (string, error), logger *gosteno.Logger) *tokenManager {
+	return &tokenManager{
+		fetch:  fetch,
+		logger: logger,
+	}
+}
+
+//Token returns the cached token, fetching a new one if there is none or it has expired
+func (m *tokenManager) Token() (string, error) {
+	m.Lock()
+	if m.token != "" && (m.expiry.IsZero() || time.Now().Before(m.expiry)) {
+		t := m.token
+		m.Unlock()
+		return t, nil
+	}
+	m.Unlock()
+
+	return m.refresh("no valid token")
+}
+
+//Invalidate discards a token the server rejected and fetches a new one, unless another caller already replaced it
+func (m *tokenManager) Invalidate(token string) (string, error) {
+	m.Lock()
+	if m.token != token && m.token != "" {
+		t := m.token
+		m.Unlock()
+		return t, nil
+	}
+	m.token = ""
+	m.Unlock()
+
+	return m.refresh("token rejected by server")
+}
+
+func (m *tokenManager) refresh(reason string) (string, error) {
+	m.Lock()
+	if r := m.inflight; r != nil {
+		m.Unlock()
+		<-r.done
+		return r.token, r.err
+	}
+
+	r := &tokenRefresh{done: make(chan struct{})}
+	m.inflight = r
+	m.Unlock()
+
+	m.logger.Debugf("Refreshing UAA token: %s", reason)
+	r.token, r.err = m.fetch()
+
+	m.Lock()
+	m.inflight = nil
+	m.lastErr = r.err
+	if r.err != nil {
+		m.failures++
+	} else {
+		m.refreshes++
+		m.token = r.token
+		m.fetchedAt = time.Now()
+		expiry, err := tokenExpiry(r.token)
+		if err != nil {
+			m.logger.Warnf("Unable to read UAA token expiry, token will only be refreshed once rejected: %s", err.Error())
+		} else if !expiry.After(m.fetchedAt) {
+			m.logger.Warnf("UAA token expired at %s, %s before it was fetched, check the clocks of the nozzle and UAA are in sync", expiry.UTC().Format(time.RFC3339), m.fetchedAt.Sub(expiry))
+		}
+		m.expiry = expiry
+	}
+	m.Unlock()
+	close(r.done)
+
+	if r.err != nil {
+		m.logger.Errorf("Failed to refresh UAA token (%s): %s", reason, r.err.Error())
+	}
+	return r.token, r.err
+}
+
+func (m *tokenManager) status() TokenStatus {
+	m.Lock()
+	defer m.Unlock()
+
+	s := TokenStatus{
+		Required:        true,
+		Valid:           m.token != "" && (m.expiry.IsZero() || time.Now().Before(m.expiry)),
+		Expiry:          m.expiry,
+		Refreshes:       m.refreshes,
+		RefreshFailures: m.failures,
+	}
+	if m.lastErr != nil {
+		s.LastError = m.lastErr.Error()
+	}
+	return s
+}
+
+//nextRefresh returns how long until the token should be refreshed
+func (m *tokenManager) nextRefresh() time.Duration {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.lastErr != nil {
+		return tokenRetryInterval
+	}
+
+	if m.token == "" || m.expiry.IsZero() {
+		return tokenNoExpiryInterval
+	}
+
+	margin := tokenRefreshMargin
+	if lifetime := m.expiry.Sub(m.fetchedAt); lifetime < 2*margin {
+		margin = lifetime / 2
+	}
+
+	// a token that is already expired, as when the clocks of the nozzle and UAA disagree, is retried rather than fetched in a loop
+	if d := time.Until(m.expiry.Add(-margin)); d > tokenRetryInterval {
+		return d
+	}
+	return tokenRetryInterval
+}
+
+//startRefreshing refreshes the token in the background before it expires until ctx is cancelled
+func (m *tokenManager) startRefreshing(ctx context.Context) {
+	go func() {
+		for {
+			timer := time.NewTimer(m.nextRefresh())
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+
+			m.Lock()
+			hasToken := m.token != "" || m.lastErr != nil
+			m.Unlock()
+
+			// the first token is fetched by the first request
+			if hasToken {
+				m.refresh("token about to expire")
+			}
+		}
+	}()
+}
+
+//tokenExpiry reads the exp claim of a JWT bearer token
+func tokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(token, "bearer "), "Bearer ")), ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to decode token claims: %s", err.Error())
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse token claims: %s", err.Error())
+	}
+
+	if claims.Exp == 0 {
+		return time.Time{}, fmt.Errorf("token has no exp claim")
+	}
+	return time.Unix(claims.Exp, 0), nil
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenExpiry(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	parsed, err := tokenExpiry(createJWT(expiry))
+	if err != nil {
+		t.Fatalf("Error parsing token expiry: %s", err.Error())
+	}
+
+	if !parsed.Equal(expiry) {
+		t.Errorf("Expected expiry %s, got %s", expiry, parsed)
+	}
+
+	for _, token := range []string{"bearer not-a-jwt", "bearer a.!!!.c", "bearer a." + base64.RawURLEncoding.EncodeToString([]byte(`{}`)) + ".c"} {
+		if _, err := tokenExpiry(token); err == nil {
+			t.Errorf("Expected error parsing %s", token)
+		}
+	}
+}
+
+func TestTokenManagerCachesToken(t *testing.T) {
+	var fetches int32
+	m := newTokenManager(func() (string, error) {
+		atomic.AddInt32(&fetches, 1)
+		return createJWT(time.Now().Add(time.Hour)), nil
+	}, createLogger())
+
+	first, err := m.Token()
+	if err != nil {
+		t.Fatalf("Error getting token: %s", err.Error())
+	}
+
+	second, _ := m.Token()
+	if first != second || fetches != 1 {
+		t.Errorf("Expected cached token to be reused, got %d fetches", fetches)
+	}
+
+	//Rejecting an old token must not discard the current one
+	m.Invalidate("bearer old")
+	if fetches != 1 {
+		t.Errorf("Expected stale rejection to be ignored, got %d fetches", fetches)
+	}
+
+	m.Invalidate(first)
+	if fetches != 2 {
+		t.Errorf("Expected rejected token to be refreshed, got %d fetches", fetches)
+	}
+}
+
+func TestTokenManagerRefreshesExpiredToken(t *testing.T) {
+	var fetches int32
+	m := newTokenManager(func() (string, error) {
+		atomic.AddInt32(&fetches, 1)
+		return createJWT(time.Now().Add(-time.Second)), nil
+	}, createLogger())
+
+	m.Token()
+	m.Token()
+
+	if fetches != 2 {
+		t.Errorf("Expected expired token to be refreshed, got %d fetches", fetches)
+	}
+}
+
+func TestTokenManagerStatus(t *testing.T) {
+	fail := true
+	m := newTokenManager(func() (string, error) {
+		if fail {
+			return "", errors.New("uaa unavailable")
+		}
+		return createJWT(time.Now().Add(time.Hour)), nil
+	}, createLogger())
+
+	m.Token()
+	if s := m.status(); s.Valid || s.RefreshFailures != 1 || s.LastError != "uaa unavailable" {
+		t.Errorf("Expected a failed refresh to leave no valid token, got %+v", s)
+	}
+
+	fail = false
+	m.Token()
+	if s := m.status(); !s.Valid || s.Refreshes != 1 || s.LastError != "" || s.Expiry.Before(time.Now()) {
+		t.Errorf("Expected a valid token after a refresh, got %+v", s)
+	}
+}
+
+func TestTokenManagerSharesRefresh(t *testing.T) {
+	var fetches int32
+	release := make(chan struct{})
+	m := newTokenManager(func() (string, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return createJWT(time.Now().Add(time.Hour)), nil
+	}, createLogger())
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], _ = m.Token()
+		}(i)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if fetches != 1 {
+		t.Errorf("Expected concurrent callers to share one refresh, got %d fetches", fetches)
+	}
+
+	for _, token := range tokens {
+		if token != tokens[0] {
+			t.Errorf("Expected every caller to get the same token")
+		}
+	}
+}
+
+func TestTokenManagerNextRefresh(t *testing.T) {
+	fail := false
+	expiry := time.Now().Add(time.Hour)
+	m := newTokenManager(func() (string, error) {
+		if fail {
+			return "", errors.New("uaa unavailable")
+		}
+		return createJWT(expiry), nil
+	}, createLogger())
+
+	if d := m.nextRefresh(); d != tokenNoExpiryInterval {
+		t.Errorf("Expected %s without a token, got %s", tokenNoExpiryInterval, d)
+	}
+
+	m.Token()
+	if d := m.nextRefresh(); d > time.Hour-tokenRefreshMargin || d < time.Hour-tokenRefreshMargin-time.Second {
+		t.Errorf("Expected refresh %s before expiry, got %s", tokenRefreshMargin, d)
+	}
+
+	fail = true
+	if _, err := m.refresh("test"); err == nil {
+		t.Error("Expected failed refresh to return an error")
+	}
+
+	if d := m.nextRefresh(); d != tokenRetryInterval {
+		t.Errorf("Expected retry after %s, got %s", tokenRetryInterval, d)
+	}
+
+	//Short lived tokens are refreshed halfway through their lifetime
+	fail = false
+	expiry = time.Now().Add(time.Minute)
+	m.refresh("test")
+	if d := m.nextRefresh(); d > 30*time.Second || d < 29*time.Second {
+		t.Errorf("Expected refresh halfway through a short lived token, got %s", d)
+	}
+}
+
+func TestTokenManagerNextRefreshExpiredToken(t *testing.T) {
+	//A token that expired before it was fetched, as when the clocks of the nozzle and UAA disagree
+	m := newTokenManager(func() (string, error) {
+		return createJWT(time.Now().Add(-time.Hour)), nil
+	}, createLogger())
+
+	m.Token()
+	if d := m.nextRefresh(); d != tokenRetryInterval {
+		t.Errorf("Expected an expired token to be retried after %s, got %s", tokenRetryInterval, d)
+	}
+}
+
+func createJWT(expiry time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d,"client_id":"cf"}`, expiry.Unix())))
+	return fmt.Sprintf("bearer %s.%s.signature", header, claims)
+}
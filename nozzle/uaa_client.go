@@ -0,0 +1,101 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+)
+
+//uaaClient fetches oauth tokens from UAA with the grant type chosen in the configuration
+type uaaClient struct {
+	client       *http.Client
+	tokenURL     string
+	grantType    string
+	clientID     string
+	clientSecret string
+	username     string
+	password     string
+}
+
+type uaaTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+func newUAAClient(config *configuration.Configuration, client *http.Client) *uaaClient {
+	c := &uaaClient{
+		client:       client,
+		tokenURL:     strings.TrimRight(config.UAAURL, "/") + "/oauth/token",
+		grantType:    config.UAAGrantType,
+		clientID:     config.UAAClientID,
+		clientSecret: config.UAAClientSecret,
+		username:     config.UAAUsername,
+		password:     config.UAAPassword,
+	}
+
+	// the nozzle has always authenticated as a UAA client named by UAAUsername
+	if c.grantType == "" {
+		c.grantType = configuration.GrantTypeClientCredentials
+	}
+	if c.grantType == configuration.GrantTypeClientCredentials && c.clientID == "" {
+		c.clientID = config.UAAUsername
+		c.clientSecret = config.UAAPassword
+	}
+
+	return c
+}
+
+//GetAuthToken returns an Authorization header value such as "bearer <token>"
+func (c *uaaClient) GetAuthToken() (string, error) {
+	form := url.Values{
+		"grant_type":    {c.grantType},
+		"response_type": {"token"},
+	}
+	if c.grantType == configuration.GrantTypePassword {
+		form.Set("username", c.username)
+		form.Set("password", c.password)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("Error creating UAA token request: %s", err.Error())
+	}
+	req.SetBasicAuth(c.clientID, c.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Error requesting %s token from UAA: %s", c.grantType, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("UAA rejected %s token request for client %s with status code %d: %s", c.grantType, c.clientID, resp.StatusCode, body)
+	}
+
+	var token uaaTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("Error parsing UAA token response: %s", err.Error())
+	}
+
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("UAA token response did not contain an access token")
+	}
+
+	tokenType := token.TokenType
+	if tokenType == "" {
+		tokenType = "bearer"
+	}
+	return tokenType + " " + token.AccessToken, nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package nozzle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+)
+
+//fakeUAA issues tokens for a single client and user the same way UAA does
+type fakeUAA struct {
+	clientID     string
+	clientSecret string
+	username     string
+	password     string
+	requests     int32
+}
+
+func (u *fakeUAA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&u.requests, 1)
+	if r.Method != http.MethodPost || r.URL.Path != "/oauth/token" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != u.clientID || secret != u.clientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"unauthorized","error_description":"Bad credentials"}`))
+		return
+	}
+
+	token := ""
+	switch r.PostFormValue("grant_type") {
+	case configuration.GrantTypeClientCredentials:
+		token = "client-token"
+	case configuration.GrantTypePassword:
+		if r.PostFormValue("username") != u.username || r.PostFormValue("password") != u.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"unauthorized","error_description":"Bad credentials"}`))
+			return
+		}
+		token = "user-token"
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"access_token":"` + token + `","token_type":"bearer","expires_in":599}`))
+}
+
+func TestUAAClientGrantTypes(t *testing.T) {
+	uaa := &fakeUAA{
+		clientID:     "nozzle",
+		clientSecret: "secret",
+		username:     "admin",
+		password:     "admin-password",
+	}
+	server := httptest.NewServer(uaa)
+	defer server.Close()
+
+	cases := []struct {
+		name     string
+		config   configuration.Configuration
+		expected string
+	}{
+		{
+			name:     "default grant uses the username as client",
+			config:   configuration.Configuration{UAAUsername: "nozzle", UAAPassword: "secret"},
+			expected: "bearer client-token",
+		},
+		{
+			name:     "client credentials grant",
+			config:   configuration.Configuration{UAAGrantType: configuration.GrantTypeClientCredentials, UAAClientID: "nozzle", UAAClientSecret: "secret"},
+			expected: "bearer client-token",
+		},
+		{
+			name: "password grant with separate client",
+			config: configuration.Configuration{
+				UAAGrantType:    configuration.GrantTypePassword,
+				UAAClientID:     "nozzle",
+				UAAClientSecret: "secret",
+				UAAUsername:     "admin",
+				UAAPassword:     "admin-password",
+			},
+			expected: "bearer user-token",
+		},
+	}
+
+	for _, c := range cases {
+		c.config.UAAURL = server.URL
+		token, err := newUAAClient(&c.config, http.DefaultClient).GetAuthToken()
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", c.name, err.Error())
+			continue
+		}
+
+		if token != c.expected {
+			t.Errorf("%s: expected token %s, got %s", c.name, c.expected, token)
+		}
+	}
+}
+
+func TestUAAClientRejectedCredentials(t *testing.T) {
+	server := httptest.NewServer(&fakeUAA{clientID: "nozzle", clientSecret: "secret", username: "admin", password: "admin-password"})
+	defer server.Close()
+
+	configs := []configuration.Configuration{
+		{UAAURL: server.URL, UAAGrantType: configuration.GrantTypeClientCredentials, UAAClientID: "nozzle", UAAClientSecret: "wrong"},
+		{UAAURL: server.URL, UAAGrantType: configuration.GrantTypePassword, UAAClientID: "nozzle", UAAClientSecret: "secret", UAAUsername: "admin", UAAPassword: "wrong"},
+	}
+
+	for _, config := range configs {
+		if _, err := newUAAClient(&config, http.DefaultClient).GetAuthToken(); err == nil {
+			t.Errorf("Expected %s grant with bad credentials to fail", config.UAAGrantType)
+		}
+	}
+}
+
+func TestHTTPClientAuthenticatesWithUAA(t *testing.T) {
+	uaa := &fakeUAA{clientID: "cf", username: "admin", password: "admin-password"}
+	uaaServer := httptest.NewServer(uaa)
+	defer uaaServer.Close()
+
+	var authorization string
+	rlp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer rlp.Close()
+
+	c := createNozzleHTTPClient(t, &configuration.Configuration{
+		UAAURL:       uaaServer.URL,
+		UAAGrantType: configuration.GrantTypePassword,
+		UAAClientID:  "cf",
+		UAAUsername:  "admin",
+		UAAPassword:  "admin-password",
+	})
+
+	resp, err := c.Do(newStreamRequest(t, rlp.URL))
+	if err != nil {
+		t.Fatalf("Expected connection to succeed, got %s", err.Error())
+	}
+	resp.Body.Close()
+
+	if authorization != "bearer user-token" {
+		t.Errorf("Expected RLP request to be authorized with the UAA token, got %s", authorization)
+	}
+
+	if requests := atomic.LoadInt32(&uaa.requests); requests != 1 {
+		t.Errorf("Expected a single UAA request, got %d", requests)
+	}
+}
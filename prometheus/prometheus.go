@@ -0,0 +1,179 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package prometheus
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/results"
+)
+
+//Content types of the Prometheus text format and OpenMetrics
+const (
+	TextContentType        = "text/plain; version=0.0.4; charset=utf-8"
+	OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+const counterSuffix = "_total"
+
+//family is the samples of one metric name, counters are named without their _total suffix
+type family struct {
+	name    string
+	counter bool
+	samples []sample
+	labels  map[string]bool
+}
+
+type sample struct {
+	labels string
+	value  float64
+}
+
+//WantsOpenMetrics reports whether the scrape asked for OpenMetrics rather than the text format
+func WantsOpenMetrics(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+}
+
+//Write renders the latest value and counter samples of every resource, the origin and the resource labels become labels
+func Write(w io.Writer, origins map[string]map[string]*results.Resource, openMetrics bool) error {
+	b := bufio.NewWriter(w)
+	for _, f := range collect(origins) {
+		writeFamily(b, f, openMetrics)
+	}
+
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+	return b.Flush()
+}
+
+//collect groups samples into families sorted by name, a sample whose name is already a family of the other type is dropped
+func collect(origins map[string]map[string]*results.Resource) []*family {
+	families := make(map[string]*family)
+
+	for _, origin := range sortedKeys(origins) {
+		resources := origins[origin]
+		keys := make([]string, 0, len(resources))
+		for key := range resources {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			resource := resources[key]
+			labels := formatLabels(origin, resource.Labels())
+
+			for _, s := range resource.Latest() {
+				name := sanitizeName(s.Name)
+				if name == "" {
+					continue
+				}
+				if s.Counter {
+					name = strings.TrimSuffix(name, counterSuffix)
+				}
+
+				f, ok := families[name]
+				if !ok {
+					f = &family{name: name, counter: s.Counter, labels: make(map[string]bool)}
+					families[name] = f
+				}
+				// deployments are truncated at the first dash, so two resources can share labels
+				if f.counter != s.Counter || f.labels[labels] {
+					continue
+				}
+				f.labels[labels] = true
+				f.samples = append(f.samples, sample{labels: labels, value: s.Value})
+			}
+		}
+	}
+
+	sorted := make([]*family, 0, len(families))
+	for _, f := range families {
+		sorted = append(sorted, f)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+	return sorted
+}
+
+func writeFamily(b *bufio.Writer, f *family, openMetrics bool) {
+	metricType, sampleName := "gauge", f.name
+	if f.counter {
+		metricType, sampleName = "counter", f.name+counterSuffix
+	}
+
+	// the text format types counters by their sample name, OpenMetrics by their family name
+	typeName := sampleName
+	if openMetrics {
+		typeName = f.name
+	}
+
+	b.WriteString("# TYPE " + typeName + " " + metricType + "\n")
+	for _, s := range f.samples {
+		b.WriteString(sampleName + "{" + s.labels + "} " + formatValue(s.value) + "\n")
+	}
+}
+
+func formatLabels(origin string, l results.Labels) string {
+	pairs := []string{label("origin", origin)}
+	if l.SourceID != "" {
+		pairs = append(pairs, label("source_id", l.SourceID))
+	}
+	if l.Deployment != "" || l.Job != "" {
+		pairs = append(pairs,
+			label("deployment", l.Deployment),
+			label("job", l.Job),
+			label("index", l.Index),
+			label("ip", l.IP),
+		)
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func label(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `"`
+}
+
+//sanitizeName replaces every character Prometheus does not allow in a metric name with an underscore, a name can not start with a digit
+func sanitizeName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			b[i] = '_'
+		}
+	}
+
+	if len(b) > 0 && b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(origins map[string]map[string]*results.Resource) []string {
+	keys := make([]string, 0, len(origins))
+	for key := range origins {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package prometheus
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/logger"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/results"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/ttlcache"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultConfigLocation = "../config/bluemedora-firehose-nozzle.json"
+
+	defaultLogDirectory = "../logs"
+	prometheusLogFile   = "prometheus.log"
+	prometheusLogName   = "prometheus"
+	prometheusLogLevel  = "debug"
+)
+
+var testLogger *gosteno.Logger
+
+func GetTestLogger() *gosteno.Logger {
+	if testLogger == nil {
+		logger.CreateLogDirectory(defaultLogDirectory)
+		testLogger = logger.New(defaultLogDirectory, prometheusLogFile, prometheusLogName, prometheusLogLevel)
+	}
+
+	return testLogger
+}
+
+func TestWriteText(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, createTestOrigins(), false); err != nil {
+		t.Fatalf("Error writing metrics: %s", err.Error())
+	}
+
+	expected := `# TYPE logs_out_total counter
+logs_out_total{origin="log_rate",source_id="app-guid"} 12
+# TYPE memoryStats_numBytesAllocated gauge
+memoryStats_numBytesAllocated{origin="gorouter",deployment="cf",job="router",index="0",ip="10.0.0.1"} 1024
+memoryStats_numBytesAllocated{origin="gorouter",deployment="cf",job="router",index="1",ip="10.0.0.2"} 2048
+# TYPE total_requests_total counter
+total_requests_total{origin="gorouter",deployment="cf",job="router",index="0",ip="10.0.0.1"} 500
+`
+	if b.String() != expected {
+		t.Errorf("Expected text format:\n%s\nbut received:\n%s", expected, b.String())
+	}
+}
+
+func TestWriteFromCache(t *testing.T) {
+	c, err := configuration.New(defaultConfigLocation, GetTestLogger())
+	if err != nil {
+		t.Fatalf("Error while loading configuration: %s", err.Error())
+	}
+
+	//Built the way main builds the cache, so metrics expire after MetricCacheDurationSeconds
+	ttlcache.CreateInstance(GetTestLogger(), time.Duration(c.MetricCacheDurationSeconds)*time.Second)
+	cache := ttlcache.GetInstance()
+
+	g := newGauge("memoryStats.numBytesAllocated", 1024)
+	g.Tags = map[string]string{"origin": "gorouter", "deployment": "cf", "job": "router", "index": "0", "ip": "10.0.0.1"}
+	cache.UpdateResources([]*loggregator_v2.Envelope{g})
+
+	var b bytes.Buffer
+	if err := Write(&b, cache.GetOrigins(), false); err != nil {
+		t.Fatalf("Error writing metrics: %s", err.Error())
+	}
+
+	expected := `memoryStats_numBytesAllocated{origin="gorouter",deployment="cf",job="router",index="0",ip="10.0.0.1"} 1024`
+	if !strings.Contains(b.String(), expected) {
+		t.Errorf("Expected the cached gauge %s, but received:\n%s", expected, b.String())
+	}
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	var b bytes.Buffer
+	if err := Write(&b, createTestOrigins(), true); err != nil {
+		t.Fatalf("Error writing metrics: %s", err.Error())
+	}
+
+	output := b.String()
+	if !strings.Contains(output, "# TYPE total_requests counter\ntotal_requests_total{") {
+		t.Errorf("Expected OpenMetrics counters typed by their family name, but received:\n%s", output)
+	}
+	if !strings.HasSuffix(output, "# EOF\n") {
+		t.Errorf("Expected OpenMetrics to end with # EOF, but received:\n%s", output)
+	}
+}
+
+func TestWriteDropsConflictingTypes(t *testing.T) {
+	r := results.NewResource("cf", "router", "0", "10.0.0.1")
+	r.AddMetric(newGauge("requests", 1), GetTestLogger(), time.Minute)
+	r.AddMetric(newCounter("requests", 2), GetTestLogger(), time.Minute)
+
+	var b bytes.Buffer
+	Write(&b, map[string]map[string]*results.Resource{"gorouter": {"key": r}}, false)
+
+	if strings.Contains(b.String(), "counter") {
+		t.Errorf("Expected a counter named like a gauge to be dropped, but received:\n%s", b.String())
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	testCases := map[string]string{
+		"numCPUS":                       "numCPUS",
+		"memoryStats.lastGCPauseTimeNS": "memoryStats_lastGCPauseTimeNS",
+		"ingress-dropped":               "ingress_dropped",
+		"2xx_responses":                 "_2xx_responses",
+		"latency:p99":                   "latency_p99",
+	}
+
+	for name, want := range testCases {
+		if got := sanitizeName(name); got != want {
+			t.Errorf("Expected %s to be sanitized to %s, but received %s", name, want, got)
+		}
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	if got := label("job", "a\"b\\c\nd"); got != `job="a\"b\\c\nd"` {
+		t.Errorf("Expected label value to be escaped, but received %s", got)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	testCases := map[float64]string{
+		1.5:          "1.5",
+		1e21:         "1e+21",
+		math.Inf(1):  "+Inf",
+		math.Inf(-1): "-Inf",
+		math.NaN():   "NaN",
+	}
+
+	for value, want := range testCases {
+		if got := formatValue(value); got != want {
+			t.Errorf("Expected %v to be formatted as %s, but received %s", value, want, got)
+		}
+	}
+}
+
+func TestWantsOpenMetrics(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/metrics", nil)
+	if WantsOpenMetrics(r) {
+		t.Error("Expected the text format without an Accept header")
+	}
+
+	r.Header.Set("Accept", "application/openmetrics-text; version=1.0.0,text/plain;version=0.0.4;q=0.5")
+	if !WantsOpenMetrics(r) {
+		t.Error("Expected OpenMetrics when Prometheus asks for it")
+	}
+}
+
+func createTestOrigins() map[string]map[string]*results.Resource {
+	router0 := results.NewResource("cf", "router", "0", "10.0.0.1")
+	router0.AddMetric(newGauge("memoryStats.numBytesAllocated", 512), GetTestLogger(), time.Minute)
+	router0.AddMetric(newGauge("memoryStats.numBytesAllocated", 1024), GetTestLogger(), time.Minute)
+	router0.AddMetric(newCounter("total_requests", 500), GetTestLogger(), time.Minute)
+
+	router1 := results.NewResource("cf", "router", "1", "10.0.0.2")
+	router1.AddMetric(newGauge("memoryStats.numBytesAllocated", 2048), GetTestLogger(), time.Minute)
+
+	logRate := results.NewSourceResource("app-guid")
+	logRate.AddMetric(newCounter("logs.out", 12), GetTestLogger(), time.Minute)
+
+	return map[string]map[string]*results.Resource{
+		"gorouter": {"router-0": router0, "router-1": router1},
+		"log_rate": {"app-guid": logRate},
+	}
+}
+
+func newGauge(name string, value float64) *loggregator_v2.Envelope {
+	return &loggregator_v2.Envelope{
+		Timestamp: time.Now().UnixNano(),
+		Message: &loggregator_v2.Envelope_Gauge{
+			Gauge: &loggregator_v2.Gauge{Metrics: map[string]*loggregator_v2.GaugeValue{name: {Value: value}}},
+		},
+	}
+}
+
+func newCounter(name string, total uint64) *loggregator_v2.Envelope {
+	return &loggregator_v2.Envelope{
+		Timestamp: time.Now().UnixNano(),
+		Message: &loggregator_v2.Envelope_Counter{
+			Counter: &loggregator_v2.Counter{Name: name, Total: total},
+		},
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	maxJSONLineSize = 1024 * 1024
+	maxEnvelopeSize = 4 * 1024 * 1024
+)
+
+//formatOf picks the format from the file extension, .jsonl and .json files are JSON lines and anything else is length-delimited protobuf
+func formatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".json":
+		return configuration.RecordFormatJSONL
+	default:
+		return configuration.RecordFormatProtobuf
+	}
+}
+
+//envelopeReader returns the next recorded envelope, or io.EOF at the end of the file
+type envelopeReader interface {
+	Next() (*loggregator_v2.Envelope, error)
+}
+
+func newEnvelopeReader(r io.Reader, format string) envelopeReader {
+	if format == configuration.RecordFormatJSONL {
+		s := bufio.NewScanner(r)
+		s.Buffer(make([]byte, 64*1024), maxJSONLineSize)
+		return &jsonlReader{scanner: s, unmarshaler: &jsonpb.Unmarshaler{AllowUnknownFields: true}}
+	}
+	return &protobufReader{reader: bufio.NewReader(r)}
+}
+
+//jsonlReader reads one JSON encoded envelope per line, blank lines are skipped
+type jsonlReader struct {
+	scanner     *bufio.Scanner
+	unmarshaler *jsonpb.Unmarshaler
+	line        int
+}
+
+func (r *jsonlReader) Next() (*loggregator_v2.Envelope, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var e loggregator_v2.Envelope
+		if err := r.unmarshaler.Unmarshal(strings.NewReader(line), &e); err != nil {
+			return nil, fmt.Errorf("Invalid envelope on line %d: %s", r.line, err.Error())
+		}
+		return &e, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+//protobufReader reads envelopes each prefixed with their varint encoded length
+type protobufReader struct {
+	reader *bufio.Reader
+}
+
+func (r *protobufReader) Next() (*loggregator_v2.Envelope, error) {
+	size, err := binary.ReadUvarint(r.reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if size > maxEnvelopeSize {
+		return nil, fmt.Errorf("Envelope size %d exceeds the maximum of %d bytes, the file may not be length-delimited protobuf", size, maxEnvelopeSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.reader, data); err != nil {
+		return nil, fmt.Errorf("Truncated envelope: %s", err.Error())
+	}
+
+	var e loggregator_v2.Envelope
+	if err := proto.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("Invalid envelope: %s", err.Error())
+	}
+	return &e, nil
+}
+
+//encodeEnvelope serializes an envelope the way newEnvelopeReader reads it back
+func encodeEnvelope(e *loggregator_v2.Envelope, format string) ([]byte, error) {
+	if format == configuration.RecordFormatJSONL {
+		line, err := (&jsonpb.Marshaler{}).MarshalToString(e)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(line + "\n"), nil
+	}
+
+	data, err := proto.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	size := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(size, uint64(len(data)))
+	return append(size[:n], data...), nil
+}
+
+//extensionOf is the capture file extension for a format, formatOf maps it back
+func extensionOf(format string) string {
+	if format == configuration.RecordFormatJSONL {
+		return ".jsonl"
+	}
+	return ".pb"
+}
@@ -0,0 +1,298 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package replay
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	capturePrefix        = "envelopes-"
+	captureTimeFormat    = "20060102T150405.000000000Z"
+	defaultMaxFileSizeMB = 100
+	defaultMaxDiskMB     = 1024
+	recorderBufferSize   = 64 * 1024
+	recorderQueueSize    = 10000
+	bytesPerMB           = 1024 * 1024
+)
+
+//capture is a finished capture file counted against the disk budget
+type capture struct {
+	path string
+	size int64
+}
+
+//received is an envelope waiting to be written and when it was recorded
+type received struct {
+	envelope *loggregator_v2.Envelope
+	at       time.Time
+}
+
+//Recorder writes envelopes to capture files in the RecordDirectory that the replay source can read, rotated by size or age.
+//Envelopes are written from a goroutine of its own so disk writes do not hold up the streams.
+type Recorder struct {
+	dropped uint64 // accessed atomically, first to stay 64-bit aligned
+	sync.Mutex
+	dir            string
+	format         string
+	origins        map[string]bool
+	maxFileSize    int64
+	maxDiskSize    int64
+	rotateInterval time.Duration
+	stopAt         time.Time
+	logger         *gosteno.Logger
+
+	file     *os.File
+	writer   *bufio.Writer
+	written  int64
+	opened   time.Time
+	captures []capture
+	total    int64
+	stopped  bool
+
+	pending   chan received
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+//NewRecorder creates a recorder, capture files left in the directory by an earlier recording count against the disk budget
+func NewRecorder(config *configuration.Configuration, logger *gosteno.Logger) (*Recorder, error) {
+	if err := os.MkdirAll(config.RecordDirectory, 0755); err != nil {
+		return nil, fmt.Errorf("Unable to create record directory: %s", err.Error())
+	}
+
+	format := config.RecordFormat
+	if format == "" {
+		format = configuration.RecordFormatJSONL
+	}
+
+	maxFileSize := int64(config.RecordMaxFileSizeMB) * bytesPerMB
+	if maxFileSize <= 0 {
+		maxFileSize = defaultMaxFileSizeMB * bytesPerMB
+	}
+
+	maxDiskSize := int64(config.RecordMaxDiskMB) * bytesPerMB
+	if maxDiskSize <= 0 {
+		maxDiskSize = defaultMaxDiskMB * bytesPerMB
+	}
+	if maxFileSize > maxDiskSize {
+		maxFileSize = maxDiskSize
+	}
+
+	r := &Recorder{
+		dir:            config.RecordDirectory,
+		format:         format,
+		origins:        map[string]bool{},
+		maxFileSize:    maxFileSize,
+		maxDiskSize:    maxDiskSize,
+		rotateInterval: time.Duration(config.RecordRotateSeconds) * time.Second,
+		logger:         logger,
+		pending:        make(chan received, recorderQueueSize),
+		done:           make(chan struct{}),
+	}
+
+	for _, o := range config.RecordOriginAllowList {
+		r.origins[o] = true
+	}
+
+	if config.RecordDurationSeconds > 0 {
+		r.stopAt = time.Now().Add(time.Duration(config.RecordDurationSeconds) * time.Second)
+	}
+
+	if err := r.loadCaptures(); err != nil {
+		return nil, fmt.Errorf("Unable to read record directory: %s", err.Error())
+	}
+
+	logger.Infof("Recording %s envelopes to %s", format, config.RecordDirectory)
+	go r.run()
+	return r, nil
+}
+
+//Record queues an envelope to be written to the current capture file, it is dropped rather than wait when the disk falls behind.
+//Recording stops after an error or once RecordDurationSeconds have passed.
+func (r *Recorder) Record(e *loggregator_v2.Envelope) {
+	if len(r.origins) > 0 && !r.origins[e.GetTags()["origin"]] {
+		return
+	}
+
+	select {
+	case r.pending <- received{envelope: e, at: time.Now()}:
+	default:
+		if atomic.AddUint64(&r.dropped, 1) == 1 {
+			r.logger.Warn("Recording can not keep up with the envelopes read, dropping envelopes from the capture")
+		}
+	}
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+	for p := range r.pending {
+		r.write(p.envelope, p.at)
+	}
+}
+
+func (r *Recorder) write(e *loggregator_v2.Envelope, now time.Time) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.stopped {
+		return
+	}
+
+	if !r.stopAt.IsZero() && now.After(r.stopAt) {
+		r.logger.Info("Finished recording envelopes")
+		r.stop()
+		return
+	}
+
+	data, err := encodeEnvelope(e, r.format)
+	if err != nil {
+		r.logger.Warnf("Unable to record envelope: %s", err.Error())
+		return
+	}
+
+	if err := r.writeData(data, now); err != nil {
+		r.logger.Errorf("Stopped recording envelopes: %s", err.Error())
+		r.stop()
+	}
+}
+
+//Close writes the envelopes still queued, then flushes and closes the current capture file. Record must not be called after Close.
+func (r *Recorder) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.pending)
+	})
+	<-r.done
+
+	if dropped := atomic.LoadUint64(&r.dropped); dropped > 0 {
+		r.logger.Warnf("Dropped %d envelopes from the capture because recording could not keep up", dropped)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if r.stopped {
+		return nil
+	}
+	r.stopped = true
+	return r.closeFile()
+}
+
+func (r *Recorder) writeData(data []byte, now time.Time) error {
+	if r.file != nil && r.shouldRotate(int64(len(data)), now) {
+		if err := r.closeFile(); err != nil {
+			return err
+		}
+	}
+
+	if r.file == nil {
+		if err := r.openFile(now); err != nil {
+			return err
+		}
+	}
+
+	if _, err := r.writer.Write(data); err != nil {
+		return err
+	}
+	r.written += int64(len(data))
+	r.total += int64(len(data))
+
+	r.enforceBudget()
+	return nil
+}
+
+func (r *Recorder) shouldRotate(size int64, now time.Time) bool {
+	if r.written > 0 && r.written+size > r.maxFileSize {
+		return true
+	}
+	return r.rotateInterval > 0 && now.Sub(r.opened) >= r.rotateInterval
+}
+
+func (r *Recorder) openFile(now time.Time) error {
+	path := filepath.Join(r.dir, capturePrefix+now.UTC().Format(captureTimeFormat)+extensionOf(r.format))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	r.logger.Debugf("Recording envelopes to %s", path)
+	r.file = f
+	r.writer = bufio.NewWriterSize(f, recorderBufferSize)
+	r.written = 0
+	r.opened = now
+	return nil
+}
+
+func (r *Recorder) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.writer.Flush()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	r.captures = append(r.captures, capture{path: r.file.Name(), size: r.written})
+	r.file = nil
+	r.writer = nil
+	r.written = 0
+	return err
+}
+
+func (r *Recorder) stop() {
+	r.stopped = true
+	if err := r.closeFile(); err != nil {
+		r.logger.Warnf("Error closing capture file: %s", err.Error())
+	}
+}
+
+//enforceBudget removes the oldest finished capture files until the recording fits in RecordMaxDiskMB
+func (r *Recorder) enforceBudget() {
+	for r.total > r.maxDiskSize && len(r.captures) > 0 {
+		oldest := r.captures[0]
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			r.logger.Warnf("Unable to remove capture file %s: %s", oldest.path, err.Error())
+		}
+
+		r.logger.Debugf("Removed capture file %s to stay within the disk budget", oldest.path)
+		r.captures = r.captures[1:]
+		r.total -= oldest.size
+	}
+}
+
+//loadCaptures finds capture files from earlier recordings, their names sort oldest first
+func (r *Recorder) loadCaptures() error {
+	files, err := ioutil.ReadDir(r.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.IsDir() || !strings.HasPrefix(f.Name(), capturePrefix) {
+			continue
+		}
+		r.captures = append(r.captures, capture{path: filepath.Join(r.dir, f.Name()), size: f.Size()})
+		r.total += f.Size()
+	}
+
+	sort.Slice(r.captures, func(i, j int) bool {
+		return r.captures[i].path < r.captures[j].path
+	})
+	return nil
+}
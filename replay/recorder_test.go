@@ -0,0 +1,168 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package replay
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	for _, format := range []string{configuration.RecordFormatJSONL, configuration.RecordFormatProtobuf} {
+		dir := createTempDir(t)
+		defer os.RemoveAll(dir)
+
+		r := createRecorder(t, &configuration.Configuration{RecordDirectory: dir, RecordFormat: format})
+		for _, e := range createEnvelopes() {
+			r.Record(e)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatalf("Error closing recorder: %s", err.Error())
+		}
+
+		files := listCaptures(t, dir)
+		if len(files) != 1 {
+			t.Fatalf("Expected a single %s capture file, got %v", format, files)
+		}
+
+		s := createSource(t, &configuration.Configuration{ReplayFile: files[0], ReplaySpeed: 100})
+		s.Start(context.Background())
+
+		if e := readEnvelope(t, s); e.GetSourceId() != "rep" || e.GetGauge().GetMetrics()["cpu"].GetValue() != 12 {
+			t.Errorf("Unexpected first %s envelope %v", format, e)
+		}
+		if e := readEnvelope(t, s); e.GetSourceId() != "gorouter" || e.GetCounter().GetTotal() != 42 {
+			t.Errorf("Unexpected second %s envelope %v", format, e)
+		}
+	}
+}
+
+func TestRecordRotatesBySizeWithinBudget(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a capture from an earlier recording is the first to go
+	earlier := filepath.Join(dir, capturePrefix+"20190301T120000.000000000Z.jsonl")
+	writeFile(t, earlier, make([]byte, 200))
+
+	r := createRecorder(t, &configuration.Configuration{RecordDirectory: dir})
+	r.maxFileSize = 250
+	r.maxDiskSize = 600
+
+	e := createEnvelopes()[1]
+	data, _ := encodeEnvelope(e, configuration.RecordFormatJSONL)
+	for i := 0; i < 20; i++ {
+		r.Record(e)
+	}
+	r.Close()
+
+	if _, err := os.Stat(earlier); !os.IsNotExist(err) {
+		t.Error("Expected the oldest capture file to be removed")
+	}
+
+	var total int64
+	files := listCaptures(t, dir)
+	for _, f := range files {
+		info, _ := os.Stat(f)
+		if info.Size() > r.maxFileSize {
+			t.Errorf("Expected %s to be rotated before %d bytes, got %d", f, r.maxFileSize, info.Size())
+		}
+		total += info.Size()
+	}
+
+	if len(files) < 2 || total > r.maxDiskSize {
+		t.Errorf("Expected rotated files within %d bytes, got %d files of %d bytes", r.maxDiskSize, len(files), total)
+	}
+
+	if total < r.maxDiskSize-2*r.maxFileSize || total%int64(len(data)) != 0 {
+		t.Errorf("Expected only whole envelopes to be kept up to the budget, got %d bytes", total)
+	}
+}
+
+func TestRecordRotatesByTime(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+
+	r := createRecorder(t, &configuration.Configuration{RecordDirectory: dir})
+	r.rotateInterval = 10 * time.Millisecond
+
+	r.Record(createEnvelopes()[0])
+	time.Sleep(20 * time.Millisecond)
+	r.Record(createEnvelopes()[0])
+	r.Close()
+
+	if files := listCaptures(t, dir); len(files) != 2 {
+		t.Errorf("Expected a capture file per rotation interval, got %v", files)
+	}
+}
+
+func TestRecordOriginAllowList(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+
+	r := createRecorder(t, &configuration.Configuration{RecordDirectory: dir, RecordOriginAllowList: []string{"rep"}})
+	for _, e := range createEnvelopes() {
+		r.Record(e)
+	}
+	r.Record(&loggregator_v2.Envelope{SourceId: "bbs", Tags: map[string]string{"origin": "bbs"}})
+	r.Close()
+
+	files := listCaptures(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("Expected a single capture file, got %v", files)
+	}
+
+	f, _ := os.Open(files[0])
+	defer f.Close()
+
+	reader := newEnvelopeReader(f, configuration.RecordFormatJSONL)
+	if e, err := reader.Next(); err != nil || e.GetTags()["origin"] != "rep" {
+		t.Errorf("Expected the rep envelope to be recorded, got %v (%v)", e, err)
+	}
+	if e, err := reader.Next(); err == nil {
+		t.Errorf("Expected envelopes from other origins not to be recorded, got %v", e)
+	}
+}
+
+func TestRecordDuration(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+
+	r := createRecorder(t, &configuration.Configuration{RecordDirectory: dir, RecordDurationSeconds: 1})
+	r.stopAt = time.Now().Add(50 * time.Millisecond)
+	r.Record(createEnvelopes()[0])
+	time.Sleep(100 * time.Millisecond)
+	r.Record(createEnvelopes()[1])
+	r.Close()
+
+	data, _ := ioutil.ReadFile(listCaptures(t, dir)[0])
+	expected, _ := encodeEnvelope(createEnvelopes()[0], configuration.RecordFormatJSONL)
+	if string(data) != string(expected) {
+		t.Errorf("Expected only the envelope recorded in time to be flushed, got %s", data)
+	}
+}
+
+func listCaptures(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, capturePrefix+"*"))
+	if err != nil {
+		t.Fatalf("Error listing capture files: %s", err.Error())
+	}
+	return files
+}
+
+func createRecorder(t *testing.T, config *configuration.Configuration) *Recorder {
+	r, err := NewRecorder(config, GetTestLogger())
+	if err != nil {
+		t.Fatalf("Error creating recorder: %s", err.Error())
+	}
+	return r
+}
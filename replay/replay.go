@@ -0,0 +1,136 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const messageBufferSize = 1000
+
+//Source replays envelopes recorded to a file with the gaps between their timestamps divided by the replay speed
+type Source struct {
+	path     string
+	format   string
+	speed    float64
+	loop     bool
+	logger   *gosteno.Logger
+	messages chan *loggregator_v2.Envelope
+}
+
+//New creates a replay source for the ReplayFile
+func New(config *configuration.Configuration, logger *gosteno.Logger) (*Source, error) {
+	f, err := os.Open(config.ReplayFile)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open replay file: %s", err.Error())
+	}
+	f.Close()
+
+	speed := config.ReplaySpeed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	return &Source{
+		path:     config.ReplayFile,
+		format:   formatOf(config.ReplayFile),
+		speed:    speed,
+		loop:     config.ReplayLoop,
+		logger:   logger,
+		messages: make(chan *loggregator_v2.Envelope, messageBufferSize),
+	}, nil
+}
+
+//Start replays the file until it ends, or until ctx is cancelled when looping
+func (s *Source) Start(ctx context.Context) {
+	s.logger.Infof("Replaying %s envelopes from %s at %vx speed", s.format, s.path, s.speed)
+
+	go func() {
+		defer close(s.messages)
+
+		for {
+			if err := s.replay(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Errorf("Error replaying %s: %s", s.path, err.Error())
+				return
+			}
+
+			if !s.loop || ctx.Err() != nil {
+				s.logger.Infof("Finished replaying %s", s.path)
+				return
+			}
+			s.logger.Debugf("Restarting replay of %s", s.path)
+		}
+	}()
+}
+
+//Envelopes returns the replayed envelopes
+func (s *Source) Envelopes() <-chan *loggregator_v2.Envelope {
+	return s.messages
+}
+
+//replay sends every envelope in the file, stamped with the time it is replayed so cached metrics look current
+func (s *Source) replay(ctx context.Context) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := newEnvelopeReader(f, s.format)
+	start := time.Now()
+	var first int64
+
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if t := e.GetTimestamp(); t > 0 {
+			if first == 0 {
+				first = t
+			}
+
+			if err := s.wait(ctx, start.Add(time.Duration(float64(t-first)/s.speed))); err != nil {
+				return err
+			}
+			e.Timestamp = time.Now().UnixNano()
+		}
+
+		select {
+		case s.messages <- e:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Source) wait(ctx context.Context, until time.Time) error {
+	d := time.Until(until)
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
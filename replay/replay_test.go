@@ -0,0 +1,199 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/logger"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	defaultLogDirectory = "../logs"
+	replayLogFile       = "replay.log"
+	replayLogName       = "replay"
+	replayLogLevel      = "debug"
+)
+
+var testLogger *gosteno.Logger
+
+func GetTestLogger() *gosteno.Logger {
+	if testLogger == nil {
+		logger.CreateLogDirectory(defaultLogDirectory)
+		testLogger = logger.New(defaultLogDirectory, replayLogFile, replayLogName, replayLogLevel)
+	}
+
+	return testLogger
+}
+
+func TestReplayJSONL(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "envelopes.jsonl")
+	var buf bytes.Buffer
+	m := &jsonpb.Marshaler{}
+	for _, e := range createEnvelopes() {
+		m.Marshal(&buf, e)
+		buf.WriteString("\n\n")
+	}
+	writeFile(t, path, buf.Bytes())
+
+	checkReplay(t, path)
+}
+
+func TestReplayProtobuf(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "envelopes.pb")
+	var buf bytes.Buffer
+	for _, e := range createEnvelopes() {
+		data, _ := proto.Marshal(e)
+		size := make([]byte, binary.MaxVarintLen64)
+		buf.Write(size[:binary.PutUvarint(size, uint64(len(data)))])
+		buf.Write(data)
+	}
+	writeFile(t, path, buf.Bytes())
+
+	checkReplay(t, path)
+}
+
+func TestReplayLoop(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "envelopes.jsonl")
+	writeFile(t, path, []byte(`{"sourceId":"gorouter","counter":{"name":"requests","total":"1"}}`+"\n"))
+
+	s := createSource(t, &configuration.Configuration{ReplayFile: path, ReplayLoop: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+
+	for i := 0; i < 3; i++ {
+		readEnvelope(t, s)
+	}
+
+	cancel()
+	for range s.Envelopes() {
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	dir := createTempDir(t)
+	defer os.RemoveAll(dir)
+
+	if _, err := New(&configuration.Configuration{ReplayFile: filepath.Join(dir, "missing.jsonl")}, GetTestLogger()); err == nil {
+		t.Error("Expected error replaying a missing file")
+	}
+
+	// a JSON file read as protobuf looks like a huge envelope
+	path := filepath.Join(dir, "envelopes.pb")
+	writeFile(t, path, bytes.Repeat([]byte(`{"sourceId":"gorouter"}`), 10))
+
+	s := createSource(t, &configuration.Configuration{ReplayFile: path})
+	s.Start(context.Background())
+
+	for e := range s.Envelopes() {
+		t.Errorf("Expected no envelopes from an invalid file, got %v", e)
+	}
+}
+
+func checkReplay(t *testing.T, path string) {
+	s := createSource(t, &configuration.Configuration{ReplayFile: path, ReplaySpeed: 10})
+	start := time.Now()
+	s.Start(context.Background())
+
+	first := readEnvelope(t, s)
+	if first.GetSourceId() != "rep" || first.GetGauge().GetMetrics()["cpu"].GetValue() != 12 {
+		t.Errorf("Unexpected first envelope %v", first)
+	}
+
+	second := readEnvelope(t, s)
+	if second.GetSourceId() != "gorouter" || second.GetCounter().GetTotal() != 42 {
+		t.Errorf("Unexpected second envelope %v", second)
+	}
+
+	// the envelopes were recorded a second apart and are replayed at 10x speed
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected envelopes to be replayed about 100ms apart, took %s", elapsed)
+	}
+
+	if d := time.Duration(second.GetTimestamp() - first.GetTimestamp()); d < 90*time.Millisecond {
+		t.Errorf("Expected timestamps to be shifted to the replay time, got %s apart", d)
+	}
+
+	if _, ok := <-s.Envelopes(); ok {
+		t.Error("Expected envelopes to be closed at the end of the file")
+	}
+}
+
+func createEnvelopes() []*loggregator_v2.Envelope {
+	recorded := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC).UnixNano()
+	return []*loggregator_v2.Envelope{
+		{
+			Timestamp: recorded,
+			SourceId:  "rep",
+			Tags:      map[string]string{"origin": "rep", "job": "diego_cell"},
+			Message: &loggregator_v2.Envelope_Gauge{
+				Gauge: &loggregator_v2.Gauge{Metrics: map[string]*loggregator_v2.GaugeValue{"cpu": {Unit: "percentage", Value: 12}}},
+			},
+		},
+		{
+			Timestamp: recorded + int64(time.Second),
+			SourceId:  "gorouter",
+			Message: &loggregator_v2.Envelope_Counter{
+				Counter: &loggregator_v2.Counter{Name: "requests", Total: 42},
+			},
+		},
+	}
+}
+
+func readEnvelope(t *testing.T, s *Source) *loggregator_v2.Envelope {
+	select {
+	case e, ok := <-s.Envelopes():
+		if !ok {
+			t.Fatal("Envelopes closed before the expected envelope")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for envelope")
+	}
+	return nil
+}
+
+func createSource(t *testing.T, config *configuration.Configuration) *Source {
+	s, err := New(config, GetTestLogger())
+	if err != nil {
+		t.Fatalf("Error creating replay source: %s", err.Error())
+	}
+	return s
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Error writing %s: %s", path, err.Error())
+	}
+}
+
+func createTempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err.Error())
+	}
+	return dir
+}
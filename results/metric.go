@@ -49,3 +49,38 @@ func (m *Metric) HasExpired() bool {
 
 	return m.expires.Before(time.Now())
 }
+
+//TimerMetric represents a single timer envelope such as a gorouter http request
+type TimerMetric struct {
+	Metric
+	start int64
+	stop  int64
+}
+
+func NewTimerMetric(start, stop, t int64, ttl time.Duration) *TimerMetric {
+	metric := &TimerMetric{
+		start: start,
+		stop:  stop,
+	}
+	metric.Update(float64(stop-start), t, ttl)
+	return metric
+}
+
+func (m *TimerMetric) GetStart() int64 {
+	m.RLock()
+	defer m.RUnlock()
+	return m.start
+}
+
+func (m *TimerMetric) GetStop() int64 {
+	m.RLock()
+	defer m.RUnlock()
+	return m.stop
+}
+
+//GetDuration returns the timer duration in nanoseconds
+func (m *TimerMetric) GetDuration() int64 {
+	m.RLock()
+	defer m.RUnlock()
+	return m.stop - m.start
+}
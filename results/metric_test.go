@@ -54,3 +54,25 @@ func TestUpdate(t *testing.T) {
 		t.Errorf("Expected timestamp %d got %d", timestamp, metricTime)
 	}
 }
+
+func TestTimerMetric(t *testing.T) {
+	timestamp := time.Now().UnixNano()
+	start, stop := timestamp-int64(time.Second), timestamp
+
+	metric := NewTimerMetric(start, stop, timestamp, time.Second)
+	if metric.HasExpired() {
+		t.Error("Expected timer metric to not be expired after creation")
+	}
+
+	if metric.GetStart() != start || metric.GetStop() != stop {
+		t.Errorf("Expected start %d and stop %d got start %d and stop %d", start, stop, metric.GetStart(), metric.GetStop())
+	}
+
+	if duration := metric.GetDuration(); duration != int64(time.Second) {
+		t.Errorf("Expected duration %d got %d", int64(time.Second), duration)
+	}
+
+	if data := metric.GetData(); data != float64(time.Second) {
+		t.Errorf("Expected data %f got %f", float64(time.Second), data)
+	}
+}
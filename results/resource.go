@@ -2,6 +2,7 @@ package results
 
 import (
 	"encoding/json"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -10,15 +11,37 @@ import (
 	"github.com/cloudfoundry/gosteno"
 )
 
+//maxTimerMetrics bounds the timers kept per name, a busy gorouter emits one for every request
+const maxTimerMetrics = 1000
+
 //Resource represents cloud controller data
 type Resource struct {
 	sync.RWMutex
+	sourceID       string
 	deployment     string
 	job            string
 	index          string
 	ip             string
 	ValueMetrics   map[string][]*Metric
 	CounterMetrics map[string][]*Metric
+	TimerMetrics   map[string][]*TimerMetric
+}
+
+//Labels identify a resource, resources tracked by source id have no bosh labels
+type Labels struct {
+	SourceID   string
+	Deployment string
+	Job        string
+	Index      string
+	IP         string
+}
+
+//Sample is the latest value of a value or counter metric
+type Sample struct {
+	Name      string
+	Counter   bool
+	Value     float64
+	Timestamp int64
 }
 
 //CreateResource Creates a new resource
@@ -32,10 +55,37 @@ func NewResource(deployment, job, index, ip string) *Resource {
 		ip:             ip,
 		ValueMetrics:   make(map[string][]*Metric),
 		CounterMetrics: make(map[string][]*Metric),
+		TimerMetrics:   make(map[string][]*TimerMetric),
+	}
+}
+
+//NewSourceResource creates a resource identified by its loggregator source id
+func NewSourceResource(sourceID string) *Resource {
+	return &Resource{
+		sourceID:       sourceID,
+		ValueMetrics:   make(map[string][]*Metric),
+		CounterMetrics: make(map[string][]*Metric),
+		TimerMetrics:   make(map[string][]*TimerMetric),
 	}
 }
 
 func (r *Resource) AddMetric(e *loggregator_v2.Envelope, l *gosteno.Logger, ttl time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	r.addMetric(e, l, ttl)
+}
+
+//AddMetrics adds a batch of envelopes for this resource under a single lock
+func (r *Resource) AddMetrics(envelopes []*loggregator_v2.Envelope, l *gosteno.Logger, ttl time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	for _, e := range envelopes {
+		r.addMetric(e, l, ttl)
+	}
+}
+
+// private utility funcs, methods using them are expected to have mutex lock
+func (r *Resource) addMetric(e *loggregator_v2.Envelope, l *gosteno.Logger, ttl time.Duration) {
 	t := e.GetTimestamp()
 
 	if g := e.GetGauge(); g != nil {
@@ -45,11 +95,13 @@ func (r *Resource) AddMetric(e *loggregator_v2.Envelope, l *gosteno.Logger, ttl
 	if c := e.GetCounter(); c != nil {
 		r.addCounterMetric(c, l, t, ttl)
 	}
+
+	if tm := e.GetTimer(); tm != nil {
+		r.addTimerMetric(tm, l, t, ttl)
+	}
 }
 
 func (r *Resource) addCounterMetric(c *loggregator_v2.Counter, l *gosteno.Logger, timestamp int64, ttl time.Duration) {
-	r.Lock()
-	defer r.Unlock()
 	r.CounterMetrics[c.GetName()] = append(
 		r.getMetrics(r.CounterMetrics, c.GetName()),
 		NewMetric(float64(c.GetTotal()), timestamp, ttl),
@@ -58,14 +110,80 @@ func (r *Resource) addCounterMetric(c *loggregator_v2.Counter, l *gosteno.Logger
 }
 
 func (r *Resource) addGaugeMetrics(g *loggregator_v2.Gauge, l *gosteno.Logger, timestamp int64, ttl time.Duration) {
-	r.Lock()
-	defer r.Unlock()
 	for k, v := range g.Metrics {
 		r.ValueMetrics[k] = append(r.ValueMetrics[k], NewMetric(v.GetValue(), timestamp, ttl))
 		l.Debugf("Adding Value Event Name %s, Value %f", k, v.GetValue())
 	}
 }
 
+func (r *Resource) addTimerMetric(tm *loggregator_v2.Timer, l *gosteno.Logger, timestamp int64, ttl time.Duration) {
+	timers := append(r.TimerMetrics[tm.GetName()], NewTimerMetric(tm.GetStart(), tm.GetStop(), timestamp, ttl))
+	if len(timers) > maxTimerMetrics {
+		// the oldest quarter is dropped at once so the copy is not made for every timer
+		keep := maxTimerMetrics * 3 / 4
+		timers = append(make([]*TimerMetric, 0, maxTimerMetrics), timers[len(timers)-keep:]...)
+	}
+	r.TimerMetrics[tm.GetName()] = timers
+	l.Debugf("Adding Timer Event Name %s, Duration %d", tm.GetName(), tm.GetStop()-tm.GetStart())
+}
+
+//Counts returns the number of metric series and the samples they hold
+func (r *Resource) Counts() (series, samples int) {
+	r.RLock()
+	defer r.RUnlock()
+	for _, metrics := range r.ValueMetrics {
+		series, samples = series+1, samples+len(metrics)
+	}
+	for _, metrics := range r.CounterMetrics {
+		series, samples = series+1, samples+len(metrics)
+	}
+	for _, metrics := range r.TimerMetrics {
+		series, samples = series+1, samples+len(metrics)
+	}
+	return series, samples
+}
+
+//Labels returns what identifies the resource, they are set when it is created so no lock is needed
+func (r *Resource) Labels() Labels {
+	return Labels{
+		SourceID:   r.sourceID,
+		Deployment: r.deployment,
+		Job:        r.job,
+		Index:      r.index,
+		IP:         r.ip,
+	}
+}
+
+//Latest returns the newest unexpired sample of every value and counter metric, sorted by name
+func (r *Resource) Latest() []Sample {
+	r.RLock()
+	defer r.RUnlock()
+
+	samples := make([]Sample, 0, len(r.ValueMetrics)+len(r.CounterMetrics))
+	samples = appendLatest(samples, r.ValueMetrics, false)
+	samples = appendLatest(samples, r.CounterMetrics, true)
+
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].Name != samples[j].Name {
+			return samples[i].Name < samples[j].Name
+		}
+		return !samples[i].Counter && samples[j].Counter
+	})
+	return samples
+}
+
+func appendLatest(samples []Sample, metricMap map[string][]*Metric, counter bool) []Sample {
+	for name, metrics := range metricMap {
+		for i := len(metrics) - 1; i >= 0; i-- {
+			if !metrics[i].HasExpired() {
+				samples = append(samples, Sample{Name: name, Counter: counter, Value: metrics[i].GetData(), Timestamp: metrics[i].GetTimestamp()})
+				break
+			}
+		}
+	}
+	return samples
+}
+
 func (r *Resource) IsEmpty() bool {
 	r.RLock()
 	defer r.RUnlock()
@@ -76,6 +194,9 @@ func (r *Resource) IsEmpty() bool {
 	for _, metrics := range r.CounterMetrics {
 		count += len(metrics)
 	}
+	for _, metrics := range r.TimerMetrics {
+		count += len(metrics)
+	}
 	return count == 0
 }
 
@@ -90,6 +211,10 @@ func (r *Resource) Cleanup() {
 	for key, metrics := range r.CounterMetrics {
 		r.CounterMetrics[key] = nonExpiredMetric(metrics)
 	}
+
+	for key, metrics := range r.TimerMetrics {
+		r.TimerMetrics[key] = nonExpiredTimerMetric(metrics)
+	}
 }
 
 func nonExpiredMetric(metrics []*Metric) []*Metric {
@@ -102,6 +227,16 @@ func nonExpiredMetric(metrics []*Metric) []*Metric {
 	return metricsToKeep
 }
 
+func nonExpiredTimerMetric(metrics []*TimerMetric) []*TimerMetric {
+	var metricsToKeep []*TimerMetric
+	for _, metric := range metrics {
+		if !metric.HasExpired() {
+			metricsToKeep = append(metricsToKeep, metric)
+		}
+	}
+	return metricsToKeep
+}
+
 func (r *Resource) getMetrics(metricMap map[string][]*Metric, metricName string) []*Metric {
 	var metrics []*Metric
 	if value, ok := metricMap[metricName]; ok {
@@ -123,23 +258,41 @@ type metricsJSON struct {
 	Metrics []metricJSON `json:"metrics"`
 }
 
+//timerJSON is a private struct for structure timer metrics in JSON
+type timerJSON struct {
+	Start     int64 `json:"start"`
+	Stop      int64 `json:"stop"`
+	Duration  int64 `json:"duration"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+//timersJSON is a struct to make a slice out of the timer metrics
+type timersJSON struct {
+	Metrics []timerJSON `json:"metrics"`
+}
+
 func (r *Resource) MarshalJSON() ([]byte, error) {
 	ValueMetrics, CounterMetrics := convertMap(r.ValueMetrics), convertMap(r.CounterMetrics)
+	TimerMetrics := convertTimerMap(r.TimerMetrics)
 
 	return json.Marshal(&struct {
+		SourceID       string `json:",omitempty"`
 		Deployment     string
 		Job            string
 		Index          string
 		IP             string
 		ValueMetrics   map[string]metricsJSON
 		CounterMetrics map[string]metricsJSON
+		TimerMetrics   map[string]timersJSON
 	}{
+		SourceID:       r.sourceID,
 		Deployment:     r.deployment,
 		Job:            r.job,
 		Index:          r.index,
 		IP:             r.ip,
 		ValueMetrics:   ValueMetrics,
 		CounterMetrics: CounterMetrics,
+		TimerMetrics:   TimerMetrics,
 	})
 }
 
@@ -155,3 +308,21 @@ func convertMap(inputMap map[string][]*Metric) map[string]metricsJSON {
 	}
 	return outputMap
 }
+
+func convertTimerMap(inputMap map[string][]*TimerMetric) map[string]timersJSON {
+	outputMap := make(map[string]timersJSON)
+	for key, metrics := range inputMap {
+		var emptyList []timerJSON
+		var jsonMetrics = timersJSON{Metrics: emptyList}
+		for _, metric := range metrics {
+			jsonMetrics.Metrics = append(jsonMetrics.Metrics, timerJSON{
+				Start:     metric.GetStart(),
+				Stop:      metric.GetStop(),
+				Duration:  metric.GetDuration(),
+				Timestamp: metric.GetTimestamp(),
+			})
+		}
+		outputMap[key] = jsonMetrics
+	}
+	return outputMap
+}
@@ -27,6 +27,7 @@ func TestCreateResource(t *testing.T) {
 				ip:             ip,
 				ValueMetrics:   make(map[string][]*Metric),
 				CounterMetrics: make(map[string][]*Metric),
+				TimerMetrics:   make(map[string][]*TimerMetric),
 			},
 		},
 	}
@@ -40,6 +41,22 @@ func TestCreateResource(t *testing.T) {
 	}
 }
 
+func TestSourceResourceMarshalJSON(t *testing.T) {
+	want := `{"SourceID":"app-guid","Deployment":"","Job":"","Index":"","IP":"","ValueMetrics":{},"CounterMetrics":{"logs.out":{"metrics":[{"value":3,"timestamp":1257894000000000000}]}},"TimerMetrics":{}}`
+
+	resource := NewSourceResource("app-guid")
+	resource.CounterMetrics["logs.out"] = []*Metric{&Metric{data: 3, timestamp: int64(1257894000000000000)}}
+
+	messageBytes, err := json.Marshal(resource)
+	if err != nil {
+		t.Errorf("Error marshalling json %s", err.Error())
+	}
+
+	if jsonString := string(messageBytes); jsonString != want {
+		t.Errorf("Expecting %s\n got %s", want, jsonString)
+	}
+}
+
 func TestGetMetric(t *testing.T) {
 
 	//Test not passing
@@ -75,6 +92,7 @@ func TestCleanup(t *testing.T) {
 
 	resource.ValueMetrics["test"] = []*Metric{&Metric{expires: &expiration}, &Metric{expires: &expiration}}
 	resource.CounterMetrics["test"] = []*Metric{&Metric{expires: &expiration}, &Metric{expires: &expiration}}
+	resource.TimerMetrics["test"] = []*TimerMetric{&TimerMetric{Metric: Metric{expires: &expiration}}}
 
 	resource.Cleanup()
 
@@ -124,10 +142,31 @@ func TestRetainedDataAfterCleanup(t *testing.T) {
 	}
 }
 
+func TestLatest(t *testing.T) {
+	expired := time.Now().Add(-time.Second)
+	expiration := time.Now().Add(time.Minute)
+	resource := newTestResource()
+
+	resource.ValueMetrics["b"] = []*Metric{&Metric{data: 1, expires: &expiration}, &Metric{data: 2, timestamp: 20, expires: &expiration}}
+	resource.ValueMetrics["expired"] = []*Metric{&Metric{data: 3, expires: &expired}}
+	resource.CounterMetrics["a"] = []*Metric{&Metric{data: 4, expires: &expiration}, &Metric{data: 5, expires: &expired}}
+	resource.CounterMetrics["b"] = []*Metric{&Metric{data: 6, expires: &expiration}}
+
+	want := []Sample{
+		{Name: "a", Counter: true, Value: 4},
+		{Name: "b", Value: 2, Timestamp: 20},
+		{Name: "b", Counter: true, Value: 6},
+	}
+	if got := resource.Latest(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expecting the newest unexpired samples %v, got %v", want, got)
+	}
+}
+
 func TestAddMetric(t *testing.T) {
 	origin, deployment, job, index, ip := "origin", "deployment", "job", "index", "ip"
 	timestamp := time.Now().UnixNano()
-	metricName, counterName := "metric", "counter"
+	metricName, counterName, timerName := "metric", "counter", "http"
+	start, stop := timestamp-int64(250*time.Millisecond), timestamp
 	value, delta, total := float64(24), uint64(24), uint64(24)
 	logger := createLogger()
 
@@ -174,6 +213,26 @@ func TestAddMetric(t *testing.T) {
 		},
 	}
 
+	timerEnvelope := &loggregator_v2.Envelope{
+		Timestamp:  timestamp,
+		SourceId:   "sourceid",
+		InstanceId: "instanceid",
+		Tags: map[string]string{
+			"deployment": deployment,
+			"job":        job,
+			"index":      index,
+			"ip":         ip,
+			"origin":     origin,
+		},
+		Message: &loggregator_v2.Envelope_Timer{
+			Timer: &loggregator_v2.Timer{
+				Name:  timerName,
+				Start: start,
+				Stop:  stop,
+			},
+		},
+	}
+
 	resource := newTestResource()
 	ttl := 10 * time.Second
 
@@ -204,6 +263,50 @@ func TestAddMetric(t *testing.T) {
 	}
 
 	delete(resource.CounterMetrics, counterName)
+
+	//Test adding timer metric
+	resource.AddMetric(timerEnvelope, logger, ttl)
+
+	if resource.IsEmpty() {
+		t.Error("No metrics found in resource")
+	}
+
+	timers := resource.TimerMetrics[timerName]
+	if len(timers) == 0 || timers[0].GetDuration() != stop-start || timers[0].GetTimestamp() != timestamp {
+		t.Errorf("Metric %s not stored correctly", timerName)
+	}
+
+	delete(resource.TimerMetrics, timerName)
+}
+
+func TestTimerMetricsBound(t *testing.T) {
+	logger := createLogger()
+	resource := NewResource("cf", "router", "0", "10.0.0.1")
+
+	total := 5 * maxTimerMetrics
+	for i := 0; i < total; i++ {
+		resource.AddMetric(&loggregator_v2.Envelope{
+			Timestamp: int64(i),
+			Message: &loggregator_v2.Envelope_Timer{
+				Timer: &loggregator_v2.Timer{Name: "http", Start: 0, Stop: int64(i)},
+			},
+		}, logger, time.Minute)
+
+		if count := len(resource.TimerMetrics["http"]); count > maxTimerMetrics {
+			t.Fatalf("Expected at most %d timers, but found %d after adding %d", maxTimerMetrics, count, i+1)
+		}
+	}
+
+	timers := resource.TimerMetrics["http"]
+	if last := timers[len(timers)-1]; last.GetTimestamp() != int64(total-1) {
+		t.Errorf("Expected the newest timer to be kept, but the last has timestamp %d", last.GetTimestamp())
+	}
+	for i := 1; i < len(timers); i++ {
+		if timers[i].GetTimestamp() != timers[i-1].GetTimestamp()+1 {
+			t.Errorf("Expected the newest timers in order, but %d follows %d", timers[i].GetTimestamp(), timers[i-1].GetTimestamp())
+			break
+		}
+	}
 }
 
 func TestConvertMap(t *testing.T) {
@@ -250,8 +353,22 @@ func TestConvertMap(t *testing.T) {
 	}
 }
 
+func TestConvertTimerMap(t *testing.T) {
+	input := map[string][]*TimerMetric{
+		"http": []*TimerMetric{NewTimerMetric(100, 350, 400, time.Minute)},
+	}
+	want := map[string]timersJSON{
+		"http": timersJSON{[]timerJSON{timerJSON{Start: 100, Stop: 350, Duration: 250, Timestamp: 400}}},
+	}
+
+	output := convertTimerMap(input)
+	if !reflect.DeepEqual(output, want) {
+		t.Errorf("Got %v expected %v", output, want)
+	}
+}
+
 func TestMarshalJSON(t *testing.T) {
-	want := `{"Deployment":"deployment","Job":"job","Index":"index","IP":"ip","ValueMetrics":{"one":{"metrics":[{"value":1,"timestamp":1257894000000000000}]}},"CounterMetrics":{"one":{"metrics":[{"value":1,"timestamp":1257894000000000000}]}}}`
+	want := `{"Deployment":"deployment","Job":"job","Index":"index","IP":"ip","ValueMetrics":{"one":{"metrics":[{"value":1,"timestamp":1257894000000000000}]}},"CounterMetrics":{"one":{"metrics":[{"value":1,"timestamp":1257894000000000000}]}},"TimerMetrics":{"http":{"metrics":[{"start":1257893999750000000,"stop":1257894000000000000,"duration":250000000,"timestamp":1257894000000000000}]}}}`
 
 	resource := newTestResource()
 
@@ -259,6 +376,8 @@ func TestMarshalJSON(t *testing.T) {
 
 	resource.CounterMetrics["one"] = []*Metric{&Metric{data: 1, timestamp: int64(1257894000000000000)}}
 
+	resource.TimerMetrics["http"] = []*TimerMetric{NewTimerMetric(int64(1257893999750000000), int64(1257894000000000000), int64(1257894000000000000), time.Minute)}
+
 	messageBytes, err := resource.MarshalJSON()
 	if err != nil {
 		t.Errorf("Error marshalling json %s", err.Error())
@@ -0,0 +1,267 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package stats
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/nozzle"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/ttlcache"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+//Origin and source id of the envelopes the nozzle's own stats are cached under
+const (
+	Origin   = "bluemedora_nozzle"
+	SourceID = "bluemedora-firehose-nozzle"
+)
+
+const (
+	statsInterval = 10 * time.Second
+
+	envelopesReceived  = "envelopes_received"
+	envelopesPerSecond = "envelopes_per_second"
+	apiRequests        = "api_requests"
+	apiRequestMean     = "api_request_mean_ms"
+	apiRequestMax      = "api_request_max_ms"
+)
+
+//Stats counts what the nozzle itself is doing, other packages report through registered counter and gauge funcs
+type Stats struct {
+	sync.Mutex
+	logger   *gosteno.Logger
+	received map[string]uint64
+	total    uint64
+	last     time.Time
+	rate     float64
+	sampled  uint64
+	requests map[string]*requestTimer
+	counters []func() map[string]uint64
+	gauges   []func() map[string]float64
+}
+
+//requestTimer accumulates the latency of an API endpoint
+type requestTimer struct {
+	count uint64
+	total time.Duration
+	max   time.Duration
+}
+
+//RequestStats is the latency of an API endpoint since the nozzle started
+type RequestStats struct {
+	Count      uint64
+	MeanMillis float64
+	MaxMillis  float64
+}
+
+//Snapshot is the body of the /internal/stats endpoint
+type Snapshot struct {
+	Counters map[string]uint64
+	Gauges   map[string]float64
+	Requests map[string]RequestStats
+}
+
+var instance *Stats
+var once sync.Once
+
+//GetInstance retrieves the singleton stats
+func GetInstance() *Stats {
+	return instance
+}
+
+func CreateInstance(logger *gosteno.Logger) {
+	once.Do(func() {
+		if logger == nil {
+			panic("Stats initialized without logger")
+		}
+		instance = newStats(logger)
+	})
+}
+
+func newStats(logger *gosteno.Logger) *Stats {
+	return &Stats{
+		logger:   logger,
+		received: make(map[string]uint64),
+		requests: make(map[string]*requestTimer),
+	}
+}
+
+//AddEnvelopes counts a batch of envelopes handed to the caches by origin, skipping the ones the nozzle builds itself
+func (s *Stats) AddEnvelopes(envelopes []*loggregator_v2.Envelope) {
+	s.Lock()
+	defer s.Unlock()
+
+	var count uint64
+	for _, e := range envelopes {
+		origin := e.GetTags()["origin"]
+		if origin == Origin || origin == nozzle.LogRateOrigin {
+			continue
+		}
+		s.received[origin]++
+		count++
+	}
+	s.total += count
+	if count > 0 {
+		s.last = time.Now()
+	}
+}
+
+//LastEnvelope is when envelopes were last handed to the caches, zero if none have been
+func (s *Stats) LastEnvelope() time.Time {
+	s.Lock()
+	defer s.Unlock()
+	return s.last
+}
+
+//ObserveRequest records how long an API endpoint took to answer
+func (s *Stats) ObserveRequest(endpoint string, d time.Duration) {
+	if s == nil {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	t, ok := s.requests[endpoint]
+	if !ok {
+		t = &requestTimer{}
+		s.requests[endpoint] = t
+	}
+
+	t.count++
+	t.total += d
+	if d > t.max {
+		t.max = d
+	}
+}
+
+//RegisterCounters adds counters read from another package each time the stats are reported
+func (s *Stats) RegisterCounters(f func() map[string]uint64) {
+	s.Lock()
+	defer s.Unlock()
+	s.counters = append(s.counters, f)
+}
+
+//RegisterGauges adds gauges read from another package each time the stats are reported
+func (s *Stats) RegisterGauges(f func() map[string]float64) {
+	s.Lock()
+	defer s.Unlock()
+	s.gauges = append(s.gauges, f)
+}
+
+//Snapshot reads every counter, gauge and request timer
+func (s *Stats) Snapshot() Snapshot {
+	s.Lock()
+	snapshot := Snapshot{
+		Counters: map[string]uint64{envelopesReceived: s.total},
+		Gauges:   map[string]float64{envelopesPerSecond: s.rate},
+		Requests: make(map[string]RequestStats, len(s.requests)),
+	}
+
+	for origin, count := range s.received {
+		snapshot.Counters[envelopesReceived+"."+origin] = count
+	}
+
+	for endpoint, t := range s.requests {
+		snapshot.Requests[endpoint] = RequestStats{
+			Count:      t.count,
+			MeanMillis: millis(t.total) / float64(t.count),
+			MaxMillis:  millis(t.max),
+		}
+	}
+
+	counters, gauges := s.counters, s.gauges
+	s.Unlock()
+
+	// registered funcs may take other locks, so they are read without holding this one
+	for _, f := range counters {
+		for name, value := range f() {
+			snapshot.Counters[name] = value
+		}
+	}
+	for _, f := range gauges {
+		for name, value := range f() {
+			snapshot.Gauges[name] = value
+		}
+	}
+	return snapshot
+}
+
+//Start samples the envelope rate and caches the stats under the Origin every statsInterval until ctx is cancelled
+func (s *Stats) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(statsInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.sample(statsInterval)
+				ttlcache.GetInstance().UpdateResources(s.Envelopes(time.Now()))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+//sample updates the envelope rate over the time since the previous sample
+func (s *Stats) sample(interval time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.rate = float64(s.total-s.sampled) / interval.Seconds()
+	s.sampled = s.total
+}
+
+//Envelopes converts a snapshot to a counter envelope per counter and one gauge envelope, request timers become counters and gauges per endpoint
+func (s *Stats) Envelopes(now time.Time) []*loggregator_v2.Envelope {
+	snapshot := s.Snapshot()
+
+	gauges := make(map[string]*loggregator_v2.GaugeValue, len(snapshot.Gauges)+2*len(snapshot.Requests))
+	for name, value := range snapshot.Gauges {
+		gauges[name] = &loggregator_v2.GaugeValue{Value: value}
+	}
+
+	for endpoint, r := range snapshot.Requests {
+		snapshot.Counters[apiRequests+"."+endpoint] = r.Count
+		gauges[apiRequestMean+"."+endpoint] = &loggregator_v2.GaugeValue{Unit: "ms", Value: r.MeanMillis}
+		gauges[apiRequestMax+"."+endpoint] = &loggregator_v2.GaugeValue{Unit: "ms", Value: r.MaxMillis}
+	}
+
+	names := make([]string, 0, len(snapshot.Counters))
+	for name := range snapshot.Counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	g := newEnvelope(now)
+	g.Message = &loggregator_v2.Envelope_Gauge{Gauge: &loggregator_v2.Gauge{Metrics: gauges}}
+	envelopes := []*loggregator_v2.Envelope{g}
+
+	for _, name := range names {
+		c := newEnvelope(now)
+		c.Message = &loggregator_v2.Envelope_Counter{Counter: &loggregator_v2.Counter{Name: name, Total: snapshot.Counters[name]}}
+		envelopes = append(envelopes, c)
+	}
+	return envelopes
+}
+
+//newEnvelope has no deployment or job tags, so the cache keeps the stats under the SourceID
+func newEnvelope(now time.Time) *loggregator_v2.Envelope {
+	return &loggregator_v2.Envelope{
+		Timestamp: now.UnixNano(),
+		SourceId:  SourceID,
+		Tags:      map[string]string{"origin": Origin},
+	}
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/logger"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/nozzle"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultLogDirectory = "../logs"
+	statsLogFile        = "stats.log"
+	statsLogName        = "stats"
+	statsLogLevel       = "debug"
+)
+
+var testLogger *gosteno.Logger
+
+func GetTestLogger() *gosteno.Logger {
+	if testLogger == nil {
+		logger.CreateLogDirectory(defaultLogDirectory)
+		testLogger = logger.New(defaultLogDirectory, statsLogFile, statsLogName, statsLogLevel)
+	}
+
+	return testLogger
+}
+
+func TestStatsCountsEnvelopesByOrigin(t *testing.T) {
+	s := newStats(GetTestLogger())
+	if !s.LastEnvelope().IsZero() {
+		t.Errorf("Expected no last envelope before any are received, got %v", s.LastEnvelope())
+	}
+
+	s.AddEnvelopes([]*loggregator_v2.Envelope{
+		{Tags: map[string]string{"origin": "gorouter"}},
+		{Tags: map[string]string{"origin": "gorouter"}},
+		{Tags: map[string]string{"origin": "rep"}},
+	})
+
+	snapshot := s.Snapshot()
+	if snapshot.Counters[envelopesReceived] != 3 {
+		t.Errorf("Expected 3 envelopes received, got %d", snapshot.Counters[envelopesReceived])
+	}
+	if snapshot.Counters[envelopesReceived+".gorouter"] != 2 || snapshot.Counters[envelopesReceived+".rep"] != 1 {
+		t.Errorf("Expected envelopes counted by origin, got %v", snapshot.Counters)
+	}
+	if time.Since(s.LastEnvelope()) > time.Second {
+		t.Errorf("Expected the last envelope time to be set, got %v", s.LastEnvelope())
+	}
+
+	s.sample(time.Second)
+	if rate := s.Snapshot().Gauges[envelopesPerSecond]; rate != 3 {
+		t.Errorf("Expected 3 envelopes per second, got %v", rate)
+	}
+
+	s.sample(time.Second)
+	if rate := s.Snapshot().Gauges[envelopesPerSecond]; rate != 0 {
+		t.Errorf("Expected the rate to only count new envelopes, got %v", rate)
+	}
+}
+
+func TestStatsSkipsNozzleEnvelopes(t *testing.T) {
+	s := newStats(GetTestLogger())
+	s.AddEnvelopes([]*loggregator_v2.Envelope{
+		{Tags: map[string]string{"origin": nozzle.LogRateOrigin}},
+		{Tags: map[string]string{"origin": Origin}},
+	})
+
+	snapshot := s.Snapshot()
+	if snapshot.Counters[envelopesReceived] != 0 || len(snapshot.Counters) != 1 {
+		t.Errorf("Expected envelopes built by the nozzle not to be counted, got %v", snapshot.Counters)
+	}
+	if !s.LastEnvelope().IsZero() {
+		t.Errorf("Expected no last envelope from envelopes built by the nozzle, got %v", s.LastEnvelope())
+	}
+
+	s.AddEnvelopes([]*loggregator_v2.Envelope{
+		{Tags: map[string]string{"origin": nozzle.LogRateOrigin}},
+		{Tags: map[string]string{"origin": "gorouter"}},
+	})
+	if received := s.Snapshot().Counters[envelopesReceived]; received != 1 {
+		t.Errorf("Expected 1 envelope received from the stream, got %d", received)
+	}
+}
+
+func TestStatsRequestLatency(t *testing.T) {
+	s := newStats(GetTestLogger())
+	s.ObserveRequest("gorouters", 10*time.Millisecond)
+	s.ObserveRequest("gorouters", 30*time.Millisecond)
+
+	r := s.Snapshot().Requests["gorouters"]
+	if r.Count != 2 || r.MeanMillis != 20 || r.MaxMillis != 30 {
+		t.Errorf("Expected 2 requests with a 20ms mean and 30ms max, got %+v", r)
+	}
+
+	// handlers are served before the stats are created when the web server is used on its own
+	var missing *Stats
+	missing.ObserveRequest("gorouters", time.Millisecond)
+}
+
+func TestStatsRegisteredFuncs(t *testing.T) {
+	s := newStats(GetTestLogger())
+	s.RegisterCounters(func() map[string]uint64 { return map[string]uint64{"reconnects": 4} })
+	s.RegisterGauges(func() map[string]float64 { return map[string]float64{"message_backlog": 12} })
+
+	snapshot := s.Snapshot()
+	if snapshot.Counters["reconnects"] != 4 || snapshot.Gauges["message_backlog"] != 12 {
+		t.Errorf("Expected the registered counters and gauges, got %v and %v", snapshot.Counters, snapshot.Gauges)
+	}
+}
+
+func TestStatsEnvelopes(t *testing.T) {
+	s := newStats(GetTestLogger())
+	s.AddEnvelopes([]*loggregator_v2.Envelope{{Tags: map[string]string{"origin": "rep"}}})
+	s.ObserveRequest("reps", time.Millisecond)
+
+	now := time.Now()
+	envelopes := s.Envelopes(now)
+
+	counters := map[string]uint64{}
+	var gauges map[string]*loggregator_v2.GaugeValue
+	for _, e := range envelopes {
+		if e.GetTags()["origin"] != Origin || e.GetSourceId() != SourceID || e.GetTimestamp() != now.UnixNano() {
+			t.Fatalf("Expected envelopes from the nozzle origin, got %v", e)
+		}
+
+		if c := e.GetCounter(); c != nil {
+			counters[c.GetName()] = c.GetTotal()
+		} else if g := e.GetGauge(); g != nil {
+			gauges = g.GetMetrics()
+		}
+	}
+
+	if counters[envelopesReceived] != 1 || counters[envelopesReceived+".rep"] != 1 || counters[apiRequests+".reps"] != 1 {
+		t.Errorf("Expected envelope and request counters, got %v", counters)
+	}
+	if _, ok := gauges[envelopesPerSecond]; !ok {
+		t.Errorf("Expected the envelope rate gauge, got %v", gauges)
+	}
+	if g, ok := gauges[apiRequestMean+".reps"]; !ok || g.GetUnit() != "ms" || g.GetValue() != 1 {
+		t.Errorf("Expected the request latency gauge in ms, got %v", g)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package statsd
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+//maxPacketSize keeps a packet inside a 1500 byte MTU with room for IPv6, UDP and tunnel headers
+const maxPacketSize = 1432
+
+//counterTTL is how long the total of a counter that stopped reporting is kept, app instances come and go
+const counterTTL = 10 * time.Minute
+
+//Exporter forwards gauge, counter and timer envelopes to a StatsD or DogStatsD UDP listener as they are read.
+//It is not safe for concurrent use, the nozzle forwards from its main loop.
+type Exporter struct {
+	address string
+	prefix  string
+	tags    bool
+	logger  *gosteno.Logger
+	conn    net.Conn
+	packet  bytes.Buffer
+	totals  map[string]counterTotal
+	evicted time.Time
+	failing bool
+}
+
+//counterTotal is the last total read for a counter and when it was read
+type counterTotal struct {
+	total uint64
+	seen  time.Time
+}
+
+//New creates an exporter for the StatsdAddress
+func New(config *configuration.Configuration, logger *gosteno.Logger) (*Exporter, error) {
+	conn, err := net.Dial("udp", config.StatsdAddress)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to resolve StatsdAddress <%s>: %s", config.StatsdAddress, err.Error())
+	}
+
+	logger.Infof("Forwarding metrics to %s %s", mode(config), config.StatsdAddress)
+	return &Exporter{
+		address: config.StatsdAddress,
+		prefix:  config.StatsdPrefix,
+		tags:    config.StatsdMode == configuration.StatsdModeDogStatsd,
+		logger:  logger,
+		conn:    conn,
+		totals:  make(map[string]counterTotal),
+		evicted: time.Now(),
+	}, nil
+}
+
+//Forward sends the metrics in envelopes, packed into as few packets as fit, other envelopes are ignored
+func (e *Exporter) Forward(envelopes []*loggregator_v2.Envelope) {
+	now := time.Now()
+	for _, envelope := range envelopes {
+		switch m := envelope.GetMessage().(type) {
+		case *loggregator_v2.Envelope_Gauge:
+			for name, g := range m.Gauge.GetMetrics() {
+				if math.IsNaN(g.GetValue()) || math.IsInf(g.GetValue(), 0) {
+					continue
+				}
+				e.add(envelope, name, strconv.FormatFloat(g.GetValue(), 'f', -1, 64), "g")
+			}
+		case *loggregator_v2.Envelope_Counter:
+			if delta, ok := e.delta(envelope, m.Counter, now); ok && delta > 0 {
+				e.add(envelope, m.Counter.GetName(), strconv.FormatUint(delta, 10), "c")
+			}
+		case *loggregator_v2.Envelope_Timer:
+			t := m.Timer
+			if t.GetStop() < t.GetStart() {
+				continue
+			}
+			ms := float64(t.GetStop()-t.GetStart()) / 1e6
+			e.add(envelope, t.GetName(), strconv.FormatFloat(ms, 'f', -1, 64), "ms")
+		}
+	}
+	e.send()
+
+	if now.Sub(e.evicted) >= counterTTL {
+		e.evictTotals(now)
+	}
+}
+
+//Close closes the UDP socket, every packet is already sent by Forward
+func (e *Exporter) Close() error {
+	return e.conn.Close()
+}
+
+//delta uses the counter's delta, or the change in its total since it was last read when only the total is set
+func (e *Exporter) delta(envelope *loggregator_v2.Envelope, c *loggregator_v2.Counter, now time.Time) (uint64, bool) {
+	key := counterKey(envelope, c.GetName())
+	last, found := e.totals[key]
+	e.totals[key] = counterTotal{total: c.GetTotal(), seen: now}
+
+	switch {
+	case c.GetDelta() > 0:
+		return c.GetDelta(), true
+	case !found:
+		// the first total has nothing to be compared to
+		return 0, false
+	case c.GetTotal() < last.total:
+		// the emitter restarted and its total started over
+		return c.GetTotal(), true
+	}
+	return c.GetTotal() - last.total, true
+}
+
+//evictTotals forgets the totals of counters not read for the counterTTL
+func (e *Exporter) evictTotals(now time.Time) {
+	for key, t := range e.totals {
+		if now.Sub(t.seen) > counterTTL {
+			delete(e.totals, key)
+		}
+	}
+	e.evicted = now
+}
+
+//add appends a line to the packet, sending the packet first if the line would not fit
+func (e *Exporter) add(envelope *loggregator_v2.Envelope, name, value, metricType string) {
+	line := e.metricName(envelope, name) + ":" + value + "|" + metricType
+	if e.tags {
+		line += formatTags(envelope)
+	}
+
+	if e.packet.Len() > 0 && e.packet.Len()+1+len(line) > maxPacketSize {
+		e.send()
+	}
+	if e.packet.Len() > 0 {
+		e.packet.WriteByte('\n')
+	}
+	e.packet.WriteString(line)
+}
+
+func (e *Exporter) send() {
+	if e.packet.Len() == 0 {
+		return
+	}
+
+	_, err := e.conn.Write(e.packet.Bytes())
+	e.packet.Reset()
+
+	// a listener that is down makes every write fail, so only the change is logged
+	if err != nil && !e.failing {
+		e.logger.Warnf("Error sending metrics to %s, dropping them until it recovers: %s", e.address, err.Error())
+	} else if err == nil && e.failing {
+		e.logger.Infof("Sending metrics to %s again", e.address)
+	}
+	e.failing = err != nil
+}
+
+//metricName is the prefix, origin and metric name joined with dots, the origin is the source ID when there is no origin tag.
+//Without tags the emitter is added after the origin so the same metric from each instance is kept apart.
+func (e *Exporter) metricName(envelope *loggregator_v2.Envelope, name string) string {
+	origin := envelope.GetTags()["origin"]
+	if origin == "" {
+		origin = envelope.GetSourceId()
+	}
+
+	path := []string{e.prefix, origin}
+	if !e.tags {
+		path = append(path, emitter(envelope, origin)...)
+	}
+	path = append(path, name)
+
+	var parts []string
+	for _, p := range path {
+		if p != "" {
+			parts = append(parts, sanitize(p))
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+//formatTags formats the envelope's tags, source ID and instance ID as DogStatsD tags, sorted so lines are stable
+func formatTags(envelope *loggregator_v2.Envelope) string {
+	tags := make(map[string]string, len(envelope.GetTags())+2)
+	for k, v := range envelope.GetTags() {
+		tags[k] = v
+	}
+	if envelope.GetSourceId() != "" {
+		tags["source_id"] = envelope.GetSourceId()
+	}
+	if envelope.GetInstanceId() != "" {
+		tags["instance_id"] = envelope.GetInstanceId()
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, sanitize(k)+":"+sanitizeTagValue(v))
+	}
+	sort.Strings(pairs)
+	return "|#" + strings.Join(pairs, ",")
+}
+
+//emitter is the deployment, job and index of a BOSH job's envelope, or the source and instance IDs of an app's
+func emitter(envelope *loggregator_v2.Envelope, origin string) []string {
+	tags := envelope.GetTags()
+	if tags["job"] != "" {
+		return []string{tags["deployment"], tags["job"], tags["index"]}
+	}
+
+	sourceID := envelope.GetSourceId()
+	if sourceID == origin {
+		sourceID = ""
+	}
+	return []string{sourceID, envelope.GetInstanceId()}
+}
+
+func counterKey(envelope *loggregator_v2.Envelope, name string) string {
+	tags := envelope.GetTags()
+	return strings.Join([]string{envelope.GetSourceId(), envelope.GetInstanceId(), tags["deployment"], tags["job"], tags["index"], tags["ip"], name}, "|")
+}
+
+func mode(config *configuration.Configuration) string {
+	if config.StatsdMode == "" {
+		return configuration.StatsdModeStatsd
+	}
+	return config.StatsdMode
+}
+
+//sanitize replaces the characters StatsD and DogStatsD use as separators in names and tag keys
+func sanitize(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '#', ',', ' ', '\t', '\n', '\r':
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+//sanitizeTagValue is sanitize for tag values, which may contain colons
+func sanitizeTagValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '|', '@', '#', ',', ' ', '\t', '\n', '\r':
+			return '_'
+		}
+		return r
+	}, value)
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/logger"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultLogDirectory = "../logs"
+	statsdLogFile       = "statsd.log"
+	statsdLogName       = "statsd"
+	statsdLogLevel      = "debug"
+)
+
+var testLogger *gosteno.Logger
+
+func GetTestLogger() *gosteno.Logger {
+	if testLogger == nil {
+		logger.CreateLogDirectory(defaultLogDirectory)
+		testLogger = logger.New(defaultLogDirectory, statsdLogFile, statsdLogName, statsdLogLevel)
+	}
+
+	return testLogger
+}
+
+func TestForwardStatsd(t *testing.T) {
+	listener := createListener(t)
+	defer listener.Close()
+	e := createExporter(t, listener, "", "cf")
+	defer e.Close()
+
+	e.Forward([]*loggregator_v2.Envelope{
+		newGauge("memoryStats.numBytesAllocated", 1024.5),
+		newCounter("total_requests", 5, 0),
+		newTimer("http", 1000000, 13500000),
+		{SourceId: "gorouter", Message: &loggregator_v2.Envelope_Log{Log: &loggregator_v2.Log{Payload: []byte("log")}}},
+	})
+
+	expected := "cf.gorouter.cf.router.0.memoryStats.numBytesAllocated:1024.5|g\n" +
+		"cf.gorouter.cf.router.0.total_requests:5|c\n" +
+		"cf.gorouter.cf.router.0.http:12.5|ms"
+	if packet := readPacket(t, listener); packet != expected {
+		t.Errorf("Expected packet:\n%s\nbut received:\n%s", expected, packet)
+	}
+}
+
+func TestStatsdAppNames(t *testing.T) {
+	listener := createListener(t)
+	defer listener.Close()
+	e := createExporter(t, listener, "", "")
+	defer e.Close()
+
+	g := newGauge("cpu", 0.5)
+	g.SourceId = "app-guid"
+	g.InstanceId = "1"
+	g.Tags = map[string]string{"origin": "rep"}
+	e.Forward([]*loggregator_v2.Envelope{g})
+
+	if packet := readPacket(t, listener); packet != "rep.app-guid.1.cpu:0.5|g" {
+		t.Errorf("Expected the app's source and instance IDs in the name, but received %s", packet)
+	}
+}
+
+func TestForwardCounterTotals(t *testing.T) {
+	listener := createListener(t)
+	defer listener.Close()
+	e := createExporter(t, listener, "", "")
+	defer e.Close()
+
+	t.Log("Checking the first total is only recorded...")
+	e.Forward([]*loggregator_v2.Envelope{newCounter("total_requests", 0, 100)})
+	e.Forward([]*loggregator_v2.Envelope{newCounter("total_requests", 0, 130)})
+	if packet := readPacket(t, listener); packet != "gorouter.cf.router.0.total_requests:30|c" {
+		t.Errorf("Expected the change in total, but received %s", packet)
+	}
+
+	t.Log("Checking a total that went down is sent whole...")
+	e.Forward([]*loggregator_v2.Envelope{newCounter("total_requests", 0, 20)})
+	if packet := readPacket(t, listener); packet != "gorouter.cf.router.0.total_requests:20|c" {
+		t.Errorf("Expected the total after a restart, but received %s", packet)
+	}
+}
+
+func TestEvictCounterTotals(t *testing.T) {
+	listener := createListener(t)
+	defer listener.Close()
+	e := createExporter(t, listener, "", "")
+	defer e.Close()
+
+	e.Forward([]*loggregator_v2.Envelope{newCounter("total_requests", 0, 100), newCounter("total_routes", 0, 10)})
+	if len(e.totals) != 2 {
+		t.Fatalf("Expected 2 totals, but found %d", len(e.totals))
+	}
+
+	now := time.Now()
+	key := counterKey(newCounter("total_requests", 0, 0), "total_requests")
+	e.totals[key] = counterTotal{total: 100, seen: now.Add(-counterTTL - time.Second)}
+
+	e.evictTotals(now)
+	if _, found := e.totals[key]; found || len(e.totals) != 1 {
+		t.Errorf("Expected only the total not read for %s to be evicted, but found %v", counterTTL, e.totals)
+	}
+}
+
+func TestForwardDogStatsdTags(t *testing.T) {
+	listener := createListener(t)
+	defer listener.Close()
+	e := createExporter(t, listener, configuration.StatsdModeDogStatsd, "")
+	defer e.Close()
+
+	g := newGauge("numCPUS", 4)
+	g.Tags["status|code"] = "2xx, ok"
+	e.Forward([]*loggregator_v2.Envelope{g})
+
+	expected := "gorouter.numCPUS:4|g|#deployment:cf,index:0,ip:10.0.0.1,job:router,origin:gorouter,source_id:gorouter,status_code:2xx__ok"
+	if packet := readPacket(t, listener); packet != expected {
+		t.Errorf("Expected %s, but received %s", expected, packet)
+	}
+}
+
+func TestForwardPacketSize(t *testing.T) {
+	listener := createListener(t)
+	defer listener.Close()
+	e := createExporter(t, listener, configuration.StatsdModeDogStatsd, "")
+	defer e.Close()
+
+	var envelopes []*loggregator_v2.Envelope
+	for i := 0; i < 100; i++ {
+		envelopes = append(envelopes, newGauge(fmt.Sprintf("memoryStats.numBytesAllocated.%d", i), float64(i)))
+	}
+	e.Forward(envelopes)
+
+	lines := 0
+	for lines < len(envelopes) {
+		packet := readPacket(t, listener)
+		if packet == "" {
+			break
+		}
+		if len(packet) > maxPacketSize {
+			t.Errorf("Expected packets of at most %d bytes, but received %d", maxPacketSize, len(packet))
+		}
+		lines += len(strings.Split(packet, "\n"))
+	}
+
+	if lines != len(envelopes) {
+		t.Errorf("Expected %d lines, but received %d", len(envelopes), lines)
+	}
+}
+
+func createExporter(t *testing.T, listener net.PacketConn, mode, prefix string) *Exporter {
+	config := &configuration.Configuration{
+		StatsdAddress: listener.LocalAddr().String(),
+		StatsdMode:    mode,
+		StatsdPrefix:  prefix,
+	}
+
+	e, err := New(config, GetTestLogger())
+	if err != nil {
+		t.Fatalf("Error creating statsd exporter: %s", err.Error())
+	}
+	return e
+}
+
+func createListener(t *testing.T) net.PacketConn {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error creating listener: %s", err.Error())
+	}
+	return listener
+}
+
+func readPacket(t *testing.T, listener net.PacketConn) string {
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 65536)
+	n, _, err := listener.ReadFrom(b)
+	if err != nil {
+		t.Errorf("Error reading packet: %s", err.Error())
+		return ""
+	}
+	return string(b[:n])
+}
+
+func newEnvelope() *loggregator_v2.Envelope {
+	return &loggregator_v2.Envelope{
+		Timestamp: time.Now().UnixNano(),
+		SourceId:  "gorouter",
+		Tags: map[string]string{
+			"origin":     "gorouter",
+			"deployment": "cf",
+			"job":        "router",
+			"index":      "0",
+			"ip":         "10.0.0.1",
+		},
+	}
+}
+
+func newGauge(name string, value float64) *loggregator_v2.Envelope {
+	e := newEnvelope()
+	e.Message = &loggregator_v2.Envelope_Gauge{
+		Gauge: &loggregator_v2.Gauge{Metrics: map[string]*loggregator_v2.GaugeValue{name: {Value: value}}},
+	}
+	return e
+}
+
+func newCounter(name string, delta, total uint64) *loggregator_v2.Envelope {
+	e := newEnvelope()
+	e.Message = &loggregator_v2.Envelope_Counter{
+		Counter: &loggregator_v2.Counter{Name: name, Delta: delta, Total: total},
+	}
+	return e
+}
+
+func newTimer(name string, start, stop int64) *loggregator_v2.Envelope {
+	e := newEnvelope()
+	e.Message = &loggregator_v2.Envelope_Timer{
+		Timer: &loggregator_v2.Timer{Name: name, Start: start, Stop: stop},
+	}
+	return e
+}
@@ -0,0 +1,77 @@
+package ttlcache
+
+import (
+	"sync"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/results"
+)
+
+const shardCount = 32
+
+//shard holds the resources whose origin and key hash to it, each shard is locked on its own
+type shard struct {
+	sync.RWMutex
+	origins map[string]map[string]*results.Resource
+}
+
+func newShards() []*shard {
+	shards := make([]*shard, shardCount)
+	for i := range shards {
+		shards[i] = &shard{origins: make(map[string]map[string]*results.Resource)}
+	}
+	return shards
+}
+
+//shardIndex hashes the origin and resource key with FNV-1a, inlined to avoid allocating a hash per envelope
+func shardIndex(originKey, key string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(originKey); i++ {
+		h = (h ^ uint32(originKey[i])) * 16777619
+	}
+	// a zero byte separates the origin from the key
+	h *= 16777619
+	for i := 0; i < len(key); i++ {
+		h = (h ^ uint32(key[i])) * 16777619
+	}
+	return int(h % shardCount)
+}
+
+// private utility func, methods using it are expected to have the shard lock
+func (s *shard) setResource(originKey, key string, resource *results.Resource) {
+	var origin map[string]*results.Resource
+	if value, ok := s.origins[originKey]; ok {
+		origin = value
+	} else {
+		origin = make(map[string]*results.Resource)
+		s.origins[originKey] = origin
+	}
+
+	origin[key] = resource
+}
+
+// private utility func, methods using it are expected to have the shard RLock minimum
+func (s *shard) getResource(originKey, key string) (resource *results.Resource, found bool) {
+	if origin, exists := s.origins[originKey]; exists {
+		resource, found = origin[key]
+	}
+	return resource, found
+}
+
+//cleanup drops expired metrics and the resources and origins they leave empty
+func (s *shard) cleanup() {
+	s.Lock()
+	defer s.Unlock()
+
+	for originKey, origin := range s.origins {
+		for key, resource := range origin {
+			resource.Cleanup()
+			if resource.IsEmpty() {
+				delete(origin, key)
+			}
+		}
+
+		if len(origin) == 0 {
+			delete(s.origins, originKey)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package ttlcache
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/results"
@@ -15,11 +16,21 @@ const (
 	cacheFlushInterval = 10 * time.Second
 )
 
+//TTLCache spreads resources over shards by origin and key so ingestion, API reads and expiry only contend within a shard
 type TTLCache struct {
-	sync.RWMutex
-	TTL     time.Duration
-	logger  *gosteno.Logger
-	origins map[string]map[string]*results.Resource
+	lastSweep int64 // nanoseconds, accessed atomically, first to stay 64-bit aligned
+	TTL       time.Duration
+	logger    *gosteno.Logger
+	shards    []*shard
+	nextSweep int
+}
+
+//CacheStats counts what the cache holds
+type CacheStats struct {
+	Resources     int
+	Series        int
+	Samples       int
+	SweepDuration time.Duration
 }
 
 var instance *TTLCache
@@ -30,111 +41,222 @@ func GetInstance() *TTLCache {
 	return instance
 }
 
-func CreateInstance(logger *gosteno.Logger) {
+//CreateInstance creates the singleton cache, metrics expire ttl after they are read
+func CreateInstance(logger *gosteno.Logger, ttl time.Duration) {
 	once.Do(func() {
 		if logger == nil {
 			panic("Cache initialized without logger")
 		}
-		instance = createTTLCache(logger)
+		instance = createTTLCache(logger, ttl)
 	})
 }
 
 // todo channel for storing messages and having time to update this without locking reading
 func (c *TTLCache) UpdateResource(e *loggregator_v2.Envelope) {
-	c.Lock()
-	defer c.Unlock()
+	originKey, k := e.Tags["origin"], createEnvelopeKey(e)
+	s := c.shards[shardIndex(originKey, k)]
+	s.Lock()
+	defer s.Unlock()
 
-	k := createEnvelopeKey(e)
 	var r *results.Resource
-	if value, ok := c.getResource(e.Tags["origin"], k); ok {
+	if value, ok := s.getResource(originKey, k); ok {
 		r = value
 	} else {
-		r = results.NewResource(e.Tags["deployment"], e.Tags["job"], e.Tags["index"], e.Tags["ip"])
-		c.setResource(e.Tags["origin"], k, r)
+		r = newEnvelopeResource(e)
+		s.setResource(originKey, k, r)
 	}
 
 	r.AddMetric(e, c.logger, c.TTL)
 }
 
+type resourceKey struct {
+	origin string
+	key    string
+}
+
+//resourceBatch is the envelopes of a batch that belong to one resource, in the order they were read
+type resourceBatch struct {
+	resourceKey
+	shard     int
+	envelopes []*loggregator_v2.Envelope
+}
+
+//UpdateResources applies a batch of envelopes taking each shard's write lock once, keys are built before locking so readers wait less
+func (c *TTLCache) UpdateResources(envelopes []*loggregator_v2.Envelope) {
+	if len(envelopes) == 0 {
+		return
+	}
+
+	byShard := make([][]*resourceBatch, len(c.shards))
+	for _, b := range groupByResource(envelopes) {
+		byShard[b.shard] = append(byShard[b.shard], b)
+	}
+
+	for i, batches := range byShard {
+		if len(batches) > 0 {
+			c.updateShard(c.shards[i], batches)
+		}
+	}
+}
+
+func (c *TTLCache) updateShard(s *shard, batches []*resourceBatch) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, b := range batches {
+		r, ok := s.getResource(b.origin, b.key)
+		if !ok {
+			r = newEnvelopeResource(b.envelopes[0])
+			s.setResource(b.origin, b.key, r)
+		}
+
+		r.AddMetrics(b.envelopes, c.logger, c.TTL)
+	}
+}
+
+func groupByResource(envelopes []*loggregator_v2.Envelope) []*resourceBatch {
+	var batches []*resourceBatch
+	index := make(map[resourceKey]*resourceBatch)
+
+	for _, e := range envelopes {
+		k := resourceKey{origin: e.Tags["origin"], key: createEnvelopeKey(e)}
+		b, ok := index[k]
+		if !ok {
+			b = &resourceBatch{resourceKey: k, shard: shardIndex(k.origin, k.key)}
+			index[k] = b
+			batches = append(batches, b)
+		}
+		b.envelopes = append(b.envelopes, e)
+	}
+	return batches
+}
+
 func createEnvelopeKey(e *loggregator_v2.Envelope) string {
+	if isSourceEnvelope(e) {
+		return fmt.Sprintf("source_id | %s", e.GetSourceId())
+	}
 	return fmt.Sprintf("%s | %s | %s | %s", e.Tags["deployment"], e.Tags["job"], e.Tags["index"], e.Tags["ip"])
 }
 
-// private utility func, public methods using it are expected to have mutex lock
-func (c *TTLCache) setResource(originKey, key string, resource *results.Resource) {
-	var origin map[string]*results.Resource
-	if value, ok := c.origins[originKey]; ok {
-		origin = value
-	} else {
-		origin = make(map[string]*results.Resource)
-		c.origins[originKey] = origin
+func newEnvelopeResource(e *loggregator_v2.Envelope) *results.Resource {
+	if isSourceEnvelope(e) {
+		return results.NewSourceResource(e.GetSourceId())
 	}
+	return results.NewResource(e.Tags["deployment"], e.Tags["job"], e.Tags["index"], e.Tags["ip"])
+}
 
-	origin[key] = resource
+// envelopes without a bosh deployment or job, such as the log rate counters, are tracked by source id
+func isSourceEnvelope(e *loggregator_v2.Envelope) bool {
+	return e.Tags["deployment"] == "" && e.Tags["job"] == "" && e.GetSourceId() != ""
 }
 
-func (c *TTLCache) GetResource(originKey, key string) (resource *results.Resource, found bool) {
-	c.RLock()
-	defer c.RUnlock()
-	return c.getResource(originKey, key)
+func (c *TTLCache) setResource(originKey, key string, resource *results.Resource) {
+	s := c.shards[shardIndex(originKey, key)]
+	s.Lock()
+	defer s.Unlock()
+	s.setResource(originKey, key, resource)
 }
 
-// private utility func, public methods using it are expected to have mutex RLock minimum
-func (c *TTLCache) getResource(originKey, key string) (resource *results.Resource, found bool) {
-	if origin, exists := c.origins[originKey]; exists {
-		resource, found = origin[key]
-	}
-	return resource, found
+func (c *TTLCache) GetResource(originKey, key string) (resource *results.Resource, found bool) {
+	s := c.shards[shardIndex(originKey, key)]
+	s.RLock()
+	defer s.RUnlock()
+	return s.getResource(originKey, key)
 }
 
+//GetOrigin collects the resources of an origin from every shard into a new map
 func (c *TTLCache) GetOrigin(originKey string) (origin map[string]*results.Resource, found bool) {
 	c.logger.Info("Get Origin")
-	c.RLock()
-	defer c.RUnlock()
+	origin = make(map[string]*results.Resource)
+
+	for _, s := range c.shards {
+		s.RLock()
+		for key, resource := range s.origins[originKey] {
+			origin[key] = resource
+		}
+		s.RUnlock()
+	}
 
-	origin, found = c.origins[originKey]
 	c.logger.Info("Returning from origin")
-	return origin, found
+	return origin, len(origin) > 0
 }
 
-func (c *TTLCache) cleanup() {
-	c.Lock()
-	defer c.Unlock()
-
-	for originKey, origin := range c.origins {
-		for key, resource := range origin {
-			resource.Cleanup()
-			if resource.IsEmpty() {
-				delete(origin, key)
+//GetOrigins collects every origin's resources from every shard into new maps
+func (c *TTLCache) GetOrigins() map[string]map[string]*results.Resource {
+	origins := make(map[string]map[string]*results.Resource)
+
+	for _, s := range c.shards {
+		s.RLock()
+		for originKey, resources := range s.origins {
+			origin, ok := origins[originKey]
+			if !ok {
+				origin = make(map[string]*results.Resource, len(resources))
+				origins[originKey] = origin
+			}
+			for key, resource := range resources {
+				origin[key] = resource
 			}
 		}
+		s.RUnlock()
+	}
+	return origins
+}
 
-		if len(origin) == 0 {
-			delete(c.origins, originKey)
-		}
+//cleanup sweeps every shard
+func (c *TTLCache) cleanup() {
+	for _, s := range c.shards {
+		s.cleanup()
 	}
 }
 
+//startCleanupTimer sweeps one shard per tick, so each shard is swept every cacheFlushInterval without stalling the others
 func (c *TTLCache) startCleanupTimer() {
 	duration := time.Duration(cacheFlushInterval)
 	if duration < time.Second {
 		duration = time.Second
 	}
-	ticker := time.Tick(duration)
+	ticker := time.Tick(duration / time.Duration(len(c.shards)))
 	go (func() {
 		for {
 			select {
 			case <-ticker:
-				c.cleanup()
+				c.sweepNext()
 			}
 		}
 	})()
 }
 
-func createTTLCache(logger *gosteno.Logger) *TTLCache {
+func (c *TTLCache) sweepNext() {
+	start := time.Now()
+	c.shards[c.nextSweep].cleanup()
+	atomic.StoreInt64(&c.lastSweep, int64(time.Since(start)))
+	c.nextSweep = (c.nextSweep + 1) % len(c.shards)
+}
+
+//Stats counts the resources, metric series and samples in every shard, and how long the last shard sweep took
+func (c *TTLCache) Stats() CacheStats {
+	stats := CacheStats{SweepDuration: time.Duration(atomic.LoadInt64(&c.lastSweep))}
+
+	for _, s := range c.shards {
+		s.RLock()
+		for _, origin := range s.origins {
+			for _, resource := range origin {
+				series, samples := resource.Counts()
+				stats.Resources++
+				stats.Series += series
+				stats.Samples += samples
+			}
+		}
+		s.RUnlock()
+	}
+	return stats
+}
+
+func createTTLCache(logger *gosteno.Logger, ttl time.Duration) *TTLCache {
 	c := &TTLCache{
-		origins: make(map[string]map[string]*results.Resource),
-		logger:  logger,
+		TTL:    ttl,
+		shards: newShards(),
+		logger: logger,
 	}
 	c.logger.Info("Built Cache")
 
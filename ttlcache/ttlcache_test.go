@@ -1,12 +1,15 @@
 package ttlcache
 
 import (
+	"fmt"
+	"math/rand"
 	"testing"
 	"time"
 
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/logger"
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/results"
 
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
 	"github.com/cloudfoundry/gosteno"
 )
 
@@ -47,16 +50,16 @@ func TestCreateTTLCache(t *testing.T) {
 		{
 			testName: "Normal Creation",
 			want: &TTLCache{
-				origins: make(map[string]map[string]*results.Resource),
-				logger:  GetTestLogger(),
+				TTL:    time.Minute,
+				logger: GetTestLogger(),
 			},
 		},
 	}
 
 	for _, tc := range testCases {
-		createdCache := createTTLCache(GetTestLogger())
+		createdCache := createTTLCache(GetTestLogger(), time.Minute)
 
-		if createdCache.TTL != tc.want.TTL || createdCache.origins == nil {
+		if createdCache.TTL != tc.want.TTL || len(createdCache.shards) != shardCount {
 			t.Errorf("Test Case %s returned %v expected %v", tc.testName, createdCache, tc.want)
 		}
 	}
@@ -66,14 +69,11 @@ func TestSetResource(t *testing.T) {
 	resource := &results.Resource{}
 	origin, key := "origin", "key"
 
-	cache := &TTLCache{
-		origins: make(map[string]map[string]*results.Resource),
-		logger:  GetTestLogger(),
-	}
+	cache := createTestCache()
 
 	cache.setResource(origin, key, resource)
 
-	if originMap, ok := cache.origins[origin]; ok {
+	if originMap, ok := cache.shards[shardIndex(origin, key)].origins[origin]; ok {
 		getResource := originMap[key]
 
 		if getResource == nil || getResource != resource {
@@ -88,10 +88,7 @@ func TestGetResource(t *testing.T) {
 	resource := &results.Resource{}
 	origin, key := "origin", "key"
 
-	cache := &TTLCache{
-		origins: make(map[string]map[string]*results.Resource),
-		logger:  GetTestLogger(),
-	}
+	cache := createTestCache()
 
 	//Testing with empty cache
 	if _, found := cache.GetResource(origin, key); found {
@@ -117,10 +114,7 @@ func TestGetOrigin(t *testing.T) {
 		"key": resource,
 	}
 
-	cache := &TTLCache{
-		origins: make(map[string]map[string]*results.Resource),
-		logger:  GetTestLogger(),
-	}
+	cache := createTestCache()
 
 	//Test empty cache
 	if _, found := cache.GetOrigin(origin); found {
@@ -128,7 +122,7 @@ func TestGetOrigin(t *testing.T) {
 	}
 
 	//Test Non empty cache
-	cache.origins[origin] = originMap
+	cache.setResource(origin, "key", resource)
 
 	if getOriginMap, found := cache.GetOrigin(origin); found {
 		for key, value := range originMap {
@@ -142,13 +136,28 @@ func TestGetOrigin(t *testing.T) {
 	}
 }
 
+func TestGetOrigins(t *testing.T) {
+	cache := createTestCache()
+
+	if origins := cache.GetOrigins(); len(origins) != 0 {
+		t.Errorf("Expecting no origins in empty cache, got %v", origins)
+	}
+
+	for i := 0; i < 100; i++ {
+		cache.setResource("origin", fmt.Sprintf("key-%d", i), &results.Resource{})
+	}
+	cache.setResource("other", "key", &results.Resource{})
+
+	origins := cache.GetOrigins()
+	if len(origins) != 2 || len(origins["origin"]) != 100 || len(origins["other"]) != 1 {
+		t.Errorf("Expecting the resources of both origins from every shard, got %d origins", len(origins))
+	}
+}
+
 func TestCacheCleanup(t *testing.T) {
 	expiration := time.Second * 1
 
-	cache := &TTLCache{
-		origins: make(map[string]map[string]*results.Resource),
-		logger:  GetTestLogger(),
-	}
+	cache := createTestCache()
 
 	resource := newTestResource()
 	m1 := &results.Metric{}
@@ -162,18 +171,303 @@ func TestCacheCleanup(t *testing.T) {
 
 	cache.cleanup()
 
-	if len(cache.origins) != 1 {
+	if _, found := cache.GetOrigin("origin"); !found {
 		t.Error("Cache cleaned up before expiration")
 	}
 
 	time.Sleep(2 * time.Second)
 	cache.cleanup()
 
-	if len(cache.origins) != 0 {
+	if _, found := cache.GetOrigin("origin"); found {
 		t.Error("Failed to fully clean out cache after expiration")
 	}
 }
 
+func TestCacheCleanupIsIncremental(t *testing.T) {
+	cache := createTestCache()
+	cache.TTL = -time.Second
+
+	envelopes := createBenchmarkEnvelopes()
+	cache.UpdateResources(envelopes)
+
+	first := cache.shards[0]
+	if len(first.origins) == 0 {
+		t.Fatal("Expected resources in the first shard")
+	}
+
+	cache.sweepNext()
+	if len(first.origins) != 0 {
+		t.Error("Expected the swept shard to be emptied of expired resources")
+	}
+	if len(cache.shards[1].origins) == 0 {
+		t.Error("Expected shards not yet swept to keep their resources")
+	}
+
+	for i := 1; i < shardCount; i++ {
+		cache.sweepNext()
+	}
+	if cache.nextSweep != 0 {
+		t.Errorf("Expected the sweep to wrap around to the first shard, got %d", cache.nextSweep)
+	}
+	for i, s := range cache.shards {
+		if len(s.origins) != 0 {
+			t.Errorf("Expected shard %d to be emptied once every shard was swept", i)
+		}
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	cache := createTestCache()
+	cache.UpdateResources(createBenchmarkEnvelopes())
+	cache.UpdateResource(newCounterEnvelope("origin-0", "0", "metric-0", 1))
+	cache.sweepNext()
+
+	stats := cache.Stats()
+	if stats.Resources != 250 || stats.Series != 2500 || stats.Samples != 2501 {
+		t.Errorf("Expected 250 resources with 2500 series and 2501 samples, got %+v", stats)
+	}
+	if stats.SweepDuration <= 0 {
+		t.Errorf("Expected the sweep duration to be recorded, got %s", stats.SweepDuration)
+	}
+}
+
+func TestShardsSpreadResources(t *testing.T) {
+	cache := createTestCache()
+	cache.UpdateResources(createBenchmarkEnvelopes())
+
+	for i, s := range cache.shards {
+		if len(s.origins) == 0 {
+			t.Errorf("Expected resources in shard %d", i)
+		}
+	}
+
+	// an origin's resources are spread over shards but read back together
+	if origin, found := cache.GetOrigin("origin-3"); !found || len(origin) != 10 {
+		t.Errorf("Expected the 10 resources of origin-3, got %d", len(origin))
+	}
+}
+
+func TestCreateEnvelopeKey(t *testing.T) {
+	testCases := []struct {
+		testName string
+		envelope *loggregator_v2.Envelope
+		want     string
+	}{
+		{
+			testName: "Bosh Envelope",
+			envelope: &loggregator_v2.Envelope{
+				SourceId: "gorouter",
+				Tags: map[string]string{
+					"deployment": "cf",
+					"job":        "router",
+					"index":      "0",
+					"ip":         "10.0.0.1",
+				},
+			},
+			want: "cf | router | 0 | 10.0.0.1",
+		},
+		{
+			testName: "Source Envelope",
+			envelope: &loggregator_v2.Envelope{
+				SourceId: "app-guid",
+				Tags: map[string]string{
+					"origin": "log_rate",
+				},
+			},
+			want: "source_id | app-guid",
+		},
+	}
+
+	for _, tc := range testCases {
+		if key := createEnvelopeKey(tc.envelope); key != tc.want {
+			t.Errorf("Test Case %s returned %s expected %s", tc.testName, key, tc.want)
+		}
+	}
+}
+
+func TestUpdateResources(t *testing.T) {
+	cache := createTestCache()
+
+	envelopes := []*loggregator_v2.Envelope{
+		newCounterEnvelope("gorouter", "0", "requests", 1),
+		newCounterEnvelope("gorouter", "1", "requests", 5),
+		newCounterEnvelope("gorouter", "0", "requests", 2),
+		newCounterEnvelope("bbs", "0", "requests", 7),
+		{SourceId: "app-guid", Tags: map[string]string{"origin": "log_rate"}, Message: &loggregator_v2.Envelope_Counter{
+			Counter: &loggregator_v2.Counter{Name: "logs", Total: 3},
+		}},
+	}
+	cache.UpdateResources(envelopes)
+
+	resource, found := cache.GetResource("gorouter", "cf | router | 0 | 10.0.0.0")
+	if !found {
+		t.Fatal("Expected gorouter instance 0 to be cached")
+	}
+	if metrics := resource.CounterMetrics["requests"]; len(metrics) != 2 || metrics[0].GetData() != 1 || metrics[1].GetData() != 2 {
+		t.Errorf("Expected both counters for the instance in the order they were read, got %v", metrics)
+	}
+
+	if origin, _ := cache.GetOrigin("gorouter"); len(origin) != 2 {
+		t.Errorf("Expected 2 gorouter resources, got %d", len(origin))
+	}
+	if _, found := cache.GetResource("bbs", "cf | router | 0 | 10.0.0.0"); !found {
+		t.Error("Expected resources to be kept per origin")
+	}
+	if _, found := cache.GetResource("log_rate", "source_id | app-guid"); !found {
+		t.Error("Expected source envelopes to be cached by source id")
+	}
+}
+
+func TestGroupByResource(t *testing.T) {
+	batches := groupByResource([]*loggregator_v2.Envelope{
+		newCounterEnvelope("gorouter", "1", "requests", 1),
+		newCounterEnvelope("gorouter", "0", "requests", 2),
+		newCounterEnvelope("gorouter", "1", "requests", 3),
+	})
+
+	if len(batches) != 2 || batches[0].key != "cf | router | 1 | 10.0.0.1" || len(batches[0].envelopes) != 2 || len(batches[1].envelopes) != 1 {
+		t.Fatalf("Expected envelopes grouped by resource in the order first read, got %v", batches)
+	}
+	if batches[0].envelopes[1].GetCounter().GetTotal() != 3 {
+		t.Error("Expected envelopes to keep their order within a resource")
+	}
+}
+
+const (
+	benchmarkBatchSize     = 1000
+	benchmarkResetInterval = 100 * benchmarkBatchSize
+)
+
+func BenchmarkUpdateResource(b *testing.B) {
+	cache, envelopes := createTestCache(), createBenchmarkEnvelopes()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		resetBenchmarkCache(b, cache, i)
+		cache.UpdateResource(envelopes[i%len(envelopes)])
+	}
+}
+
+func BenchmarkUpdateResources(b *testing.B) {
+	cache, envelopes := createTestCache(), createBenchmarkEnvelopes()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i += benchmarkBatchSize {
+		resetBenchmarkCache(b, cache, i)
+		cache.UpdateResources(benchmarkBatch(envelopes, i, b.N))
+	}
+}
+
+func BenchmarkUpdateResourceWithReaders(b *testing.B) {
+	cache, envelopes := createTestCache(), createBenchmarkEnvelopes()
+	stop := startReaders(cache)
+	defer stop()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		resetBenchmarkCache(b, cache, i)
+		cache.UpdateResource(envelopes[i%len(envelopes)])
+	}
+}
+
+func BenchmarkUpdateResourcesWithReaders(b *testing.B) {
+	cache, envelopes := createTestCache(), createBenchmarkEnvelopes()
+	stop := startReaders(cache)
+	defer stop()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i += benchmarkBatchSize {
+		resetBenchmarkCache(b, cache, i)
+		cache.UpdateResources(benchmarkBatch(envelopes, i, b.N))
+	}
+}
+
+//resetBenchmarkCache empties the cache now and then so metrics kept for the TTL do not grow with b.N
+func resetBenchmarkCache(b *testing.B, cache *TTLCache, i int) {
+	if i == 0 || i%benchmarkResetInterval != 0 {
+		return
+	}
+
+	b.StopTimer()
+	for _, s := range cache.shards {
+		s.Lock()
+		s.origins = make(map[string]map[string]*results.Resource)
+		s.Unlock()
+	}
+	b.StartTimer()
+}
+
+//benchmarkBatch returns the batch starting at envelope i of n, wrapping around the envelopes
+func benchmarkBatch(envelopes []*loggregator_v2.Envelope, i, n int) []*loggregator_v2.Envelope {
+	size := benchmarkBatchSize
+	if n-i < size {
+		size = n - i
+	}
+
+	start := i % len(envelopes)
+	if start+size > len(envelopes) {
+		start = 0
+	}
+	return envelopes[start : start+size]
+}
+
+//startReaders reads origins the way web server requests do until stopped
+func startReaders(cache *TTLCache) func() {
+	done := make(chan struct{})
+	for r := 0; r < 4; r++ {
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				cache.GetOrigin(fmt.Sprintf("origin-%d", rand.Intn(25)))
+			}
+		}()
+	}
+	return func() { close(done) }
+}
+
+//createBenchmarkEnvelopes spreads counters over 25 origins with 10 instances of 10 metrics each
+func createBenchmarkEnvelopes() []*loggregator_v2.Envelope {
+	var envelopes []*loggregator_v2.Envelope
+	for m := 0; m < 10; m++ {
+		for o := 0; o < 25; o++ {
+			for i := 0; i < 10; i++ {
+				envelopes = append(envelopes, newCounterEnvelope(fmt.Sprintf("origin-%d", o), fmt.Sprint(i), fmt.Sprintf("metric-%d", m), uint64(m)))
+			}
+		}
+	}
+	return envelopes
+}
+
+func newCounterEnvelope(origin, index, name string, total uint64) *loggregator_v2.Envelope {
+	return &loggregator_v2.Envelope{
+		Timestamp: time.Now().UnixNano(),
+		SourceId:  origin,
+		Tags: map[string]string{
+			"origin":     origin,
+			"deployment": "cf",
+			"job":        "router",
+			"index":      index,
+			"ip":         "10.0.0." + index,
+		},
+		Message: &loggregator_v2.Envelope_Counter{
+			Counter: &loggregator_v2.Counter{Name: name, Total: total},
+		},
+	}
+}
+
+func createTestCache() *TTLCache {
+	return &TTLCache{
+		TTL:    time.Minute,
+		shards: newShards(),
+		logger: GetTestLogger(),
+	}
+}
+
 func newTestResource() *results.Resource {
 	deployment, job, index, ip := "deployment", "job", "index", "ip"
 	return results.NewResource(deployment, job, index, ip)
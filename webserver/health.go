@@ -0,0 +1,162 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/nozzle"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/results"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/stats"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/ttlcache"
+)
+
+const defaultReadinessTimeout = 60 * time.Second
+
+//healthJSON is the body of the /healthz endpoint
+type healthJSON struct {
+	Alive         bool
+	UptimeSeconds int64
+}
+
+//readinessJSON is the body of the /readyz endpoint, the nozzle is ready when every check is
+type readinessJSON struct {
+	Ready  bool
+	Checks []readinessCheck
+}
+
+type readinessCheck struct {
+	Name   string
+	Ready  bool
+	Detail string
+}
+
+//healthzHandler answers while the process can serve requests, it needs no token so platform health checks can use it
+func (ws *WebServer) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	ws.logger.Debug("Received /healthz request")
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		io.WriteString(w, fmt.Sprintf("Unsupported http method %s", r.Method))
+		return
+	}
+
+	ws.sendHealthBytes(w, http.StatusOK, healthJSON{
+		Alive:         true,
+		UptimeSeconds: int64(time.Since(ws.start).Seconds()),
+	})
+}
+
+//readyzHandler answers 503 until the nozzle is receiving envelopes, holds a valid UAA token and has cached metrics
+func (ws *WebServer) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ws.logger.Debug("Received /readyz request")
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		io.WriteString(w, fmt.Sprintf("Unsupported http method %s", r.Method))
+		return
+	}
+
+	readiness := ws.readiness(time.Now())
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	ws.sendHealthBytes(w, status, readiness)
+}
+
+func (ws *WebServer) readiness(now time.Time) readinessJSON {
+	readiness := readinessJSON{
+		Ready:  true,
+		Checks: []readinessCheck{ws.envelopesCheck(now), ws.tokenCheck(), cacheCheck()},
+	}
+
+	for _, c := range readiness.Checks {
+		readiness.Ready = readiness.Ready && c.Ready
+	}
+	return readiness
+}
+
+func (ws *WebServer) envelopesCheck(now time.Time) readinessCheck {
+	timeout := time.Duration(ws.config.ReadinessTimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = defaultReadinessTimeout
+	}
+
+	ws.Lock()
+	status := ws.status
+	ws.Unlock()
+
+	// the nozzle's own log rate counters keep reaching the caches after its streams stop, so only envelopes read from a stream count
+	last := stats.GetInstance().LastEnvelope()
+	if status != nil {
+		last = status.LastEnvelope()
+	}
+
+	switch {
+	case last.IsZero():
+		return readinessCheck{Name: "envelopes", Detail: "No envelopes received"}
+	case now.Sub(last) > timeout:
+		return readinessCheck{Name: "envelopes", Detail: fmt.Sprintf("Last envelope received %s ago, longer than %s", now.Sub(last)/time.Second*time.Second, timeout)}
+	}
+	return readinessCheck{Name: "envelopes", Ready: true, Detail: fmt.Sprintf("Last envelope received at %s", last.UTC().Format(time.RFC3339))}
+}
+
+func (ws *WebServer) tokenCheck() readinessCheck {
+	ws.Lock()
+	status := ws.status
+	ws.Unlock()
+
+	// replay and generator sources read no foundation, so there is no token to check
+	if status == nil {
+		return readinessCheck{Name: "uaa_token", Ready: true, Detail: "No UAA token required"}
+	}
+
+	token := status.TokenStatus()
+	switch {
+	case !token.Required:
+		return readinessCheck{Name: "uaa_token", Ready: true, Detail: "Access control disabled"}
+	case !token.Valid && token.LastError != "":
+		return readinessCheck{Name: "uaa_token", Detail: fmt.Sprintf("No valid UAA token: %s", token.LastError)}
+	case !token.Valid:
+		return readinessCheck{Name: "uaa_token", Detail: "No valid UAA token"}
+	case token.Expiry.IsZero():
+		return readinessCheck{Name: "uaa_token", Ready: true, Detail: "UAA token valid"}
+	}
+	return readinessCheck{Name: "uaa_token", Ready: true, Detail: fmt.Sprintf("UAA token valid until %s", token.Expiry.UTC().Format(time.RFC3339))}
+}
+
+func cacheCheck() readinessCheck {
+	resources := foundationResources(ttlcache.GetInstance().GetOrigins())
+	if resources == 0 {
+		return readinessCheck{Name: "cache", Detail: "Metric cache is empty"}
+	}
+	return readinessCheck{Name: "cache", Ready: true, Detail: fmt.Sprintf("%d resources cached", resources)}
+}
+
+//foundationResources counts the cached resources reported by the foundation, the nozzle caches its own stats and log rate counters without any
+func foundationResources(origins map[string]map[string]*results.Resource) int {
+	count := 0
+	for origin, resources := range origins {
+		if origin == stats.Origin || origin == nozzle.LogRateOrigin {
+			continue
+		}
+		count += len(resources)
+	}
+	return count
+}
+
+func (ws *WebServer) sendHealthBytes(w http.ResponseWriter, status int, body interface{}) {
+	messageBytes, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_, err := w.Write(messageBytes)
+
+	if err != nil {
+		ws.logger.Errorf("Error while answering health end point call: %s", err.Error())
+	}
+}
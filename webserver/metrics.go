@@ -0,0 +1,76 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/prometheus"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/ttlcache"
+)
+
+const (
+	prometheusRealm = `Basic realm="bluemedora-firehose-nozzle"`
+	bearerPrefix    = "Bearer "
+)
+
+//metricsHandler renders the cache for Prometheus, scrapes can not fetch a token so /metrics has its own authentication
+func (ws *WebServer) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	ws.logger.Info("Received /metrics request")
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		io.WriteString(w, fmt.Sprintf("Unsupported http method %s", r.Method))
+		return
+	}
+
+	if !ws.prometheusAuthorized(r) {
+		ws.logger.Debug("Unauthorized /metrics request")
+		if ws.config.PrometheusAuth != configuration.PrometheusAuthBearer {
+			w.Header().Set("WWW-Authenticate", prometheusRealm)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, "Invalid Prometheus credentials")
+		return
+	}
+
+	openMetrics := prometheus.WantsOpenMetrics(r)
+	if openMetrics {
+		w.Header().Set("Content-Type", prometheus.OpenMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", prometheus.TextContentType)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if err := prometheus.Write(w, ttlcache.GetInstance().GetOrigins(), openMetrics); err != nil {
+		ws.logger.Errorf("Error while answering metrics end point call: %s", err.Error())
+	}
+}
+
+//prometheusAuthorized checks the scrape against PrometheusAuth, basic authentication falls back to the UAA credentials /token accepts
+func (ws *WebServer) prometheusAuthorized(r *http.Request) bool {
+	switch ws.config.PrometheusAuth {
+	case configuration.PrometheusAuthNone:
+		return true
+	case configuration.PrometheusAuthBearer:
+		auth := r.Header.Get("Authorization")
+		return strings.HasPrefix(auth, bearerPrefix) && secureCompare(strings.TrimPrefix(auth, bearerPrefix), ws.config.PrometheusBearerToken)
+	}
+
+	username, password := ws.config.PrometheusUsername, ws.config.PrometheusPassword
+	if username == "" {
+		username, password = ws.apiCredentials()
+	}
+
+	u, p, ok := r.BasicAuth()
+	return ok && secureCompare(u, username) && secureCompare(p, password)
+}
+
+func secureCompare(given, expected string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
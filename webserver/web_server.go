@@ -4,15 +4,24 @@
 package webserver
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/appcache"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/applatency"
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/eventlog"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/nozzle"
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/results"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/stats"
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/ttlcache"
 
 	"github.com/cloudfoundry/gosteno"
@@ -25,12 +34,32 @@ const (
 	headerTokenKey    = "token"
 )
 
+//NozzleStatusProvider reports the state of the nozzle for the /nozzle_status endpoint
+type NozzleStatusProvider interface {
+	ConnectionStatus() nozzle.ConnectionStatus
+	Backpressure() nozzle.BackpressureStatus
+	Streams() []nozzle.StreamStatus
+	TokenStatus() nozzle.TokenStatus
+	LastEnvelope() time.Time
+}
+
+//nozzleStatusJSON is the body of the /nozzle_status endpoint
+type nozzleStatusJSON struct {
+	Connection   nozzle.ConnectionStatus
+	Backpressure nozzle.BackpressureStatus
+	Streams      []nozzle.StreamStatus
+	Token        nozzle.TokenStatus
+}
+
 //WebServer REST endpoint for sending data
 type WebServer struct {
 	sync.Mutex
 	logger *gosteno.Logger
 	config *configuration.Configuration
 	tokens map[string]*Token //Maps token string to token object
+	status NozzleStatusProvider
+	server *http.Server
+	start  time.Time
 }
 
 //New creates a new WebServer
@@ -39,54 +68,100 @@ func New(c *configuration.Configuration, l *gosteno.Logger) *WebServer {
 		logger: l,
 		config: c,
 		tokens: make(map[string]*Token),
+		start:  time.Now(),
 	}
 
 	ws.logger.Info("Registering handlers")
 	//setup http handlers
-	http.HandleFunc("/token", ws.tokenHandler)
-	http.HandleFunc("/metron_agents", ws.metronAgentsHandler)
-	http.HandleFunc("/syslog_drains", ws.syslogDrainBindersHandler)
-	http.HandleFunc("/tps_watchers", ws.tpsWatcherHandler)
-	http.HandleFunc("/tps_listeners", ws.tpsListenersHandler)
-	http.HandleFunc("/stagers", ws.stagerHandler)
-	http.HandleFunc("/ssh_proxies", ws.sshProxyHandler)
-	http.HandleFunc("/senders", ws.senderHandler)
-	http.HandleFunc("/route_emitters", ws.routeEmitterHandler)
-	http.HandleFunc("/reps", ws.repHandler)
-	http.HandleFunc("/receptors", ws.receptorHandler)
-	http.HandleFunc("/nsync_listeners", ws.nsyncListenerHandler)
-	http.HandleFunc("/nsync_bulkers", ws.nsyncBulkerHandler)
-	http.HandleFunc("/garden_linuxs", ws.gardenLinuxHandler)
-	http.HandleFunc("/file_servers", ws.fileServersHandler)
-	http.HandleFunc("/fetchers", ws.fetcherHandler)
-	http.HandleFunc("/convergers", ws.convergerHandler)
-	http.HandleFunc("/cc_uploaders", ws.ccUploaderHandler)
-	http.HandleFunc("/bbs", ws.bbsHandler)
-	http.HandleFunc("/auctioneers", ws.auctioneerHandler)
-	http.HandleFunc("/etcds", ws.etcdsHandler)
-	http.HandleFunc("/doppler_servers", ws.dopplerServersHandler)
-	http.HandleFunc("/cloud_controllers", ws.cloudControllersHandler)
-	http.HandleFunc("/traffic_controllers", ws.trafficControllersHandler)
-	http.HandleFunc("/gorouters", ws.gorouterHandler)
-	http.HandleFunc("/lockets", ws.locketsHandler)
+	ws.handleFunc("/token", ws.tokenHandler)
+	ws.handleFunc("/metron_agents", ws.metronAgentsHandler)
+	ws.handleFunc("/syslog_drains", ws.syslogDrainBindersHandler)
+	ws.handleFunc("/tps_watchers", ws.tpsWatcherHandler)
+	ws.handleFunc("/tps_listeners", ws.tpsListenersHandler)
+	ws.handleFunc("/stagers", ws.stagerHandler)
+	ws.handleFunc("/ssh_proxies", ws.sshProxyHandler)
+	ws.handleFunc("/senders", ws.senderHandler)
+	ws.handleFunc("/route_emitters", ws.routeEmitterHandler)
+	ws.handleFunc("/reps", ws.repHandler)
+	ws.handleFunc("/receptors", ws.receptorHandler)
+	ws.handleFunc("/nsync_listeners", ws.nsyncListenerHandler)
+	ws.handleFunc("/nsync_bulkers", ws.nsyncBulkerHandler)
+	ws.handleFunc("/garden_linuxs", ws.gardenLinuxHandler)
+	ws.handleFunc("/file_servers", ws.fileServersHandler)
+	ws.handleFunc("/fetchers", ws.fetcherHandler)
+	ws.handleFunc("/convergers", ws.convergerHandler)
+	ws.handleFunc("/cc_uploaders", ws.ccUploaderHandler)
+	ws.handleFunc("/bbs", ws.bbsHandler)
+	ws.handleFunc("/auctioneers", ws.auctioneerHandler)
+	ws.handleFunc("/etcds", ws.etcdsHandler)
+	ws.handleFunc("/doppler_servers", ws.dopplerServersHandler)
+	ws.handleFunc("/cloud_controllers", ws.cloudControllersHandler)
+	ws.handleFunc("/traffic_controllers", ws.trafficControllersHandler)
+	ws.handleFunc("/gorouters", ws.gorouterHandler)
+	ws.handleFunc("/lockets", ws.locketsHandler)
+	ws.handleFunc("/log_rates", ws.logRatesHandler)
+	ws.handleFunc("/app_latencies", ws.appLatenciesHandler)
+	ws.handleFunc("/events", ws.eventsHandler)
+	ws.handleFunc("/apps", ws.appsHandler)
+	ws.handleFunc("/apps/", ws.appsHandler)
+	ws.handleFunc("/nozzle_status", ws.nozzleStatusHandler)
+	ws.handleFunc("/bluemedora_nozzles", ws.bluemedoraNozzlesHandler)
+	ws.handleFunc("/internal/stats", ws.internalStatsHandler)
+	ws.handleFunc("/healthz", ws.healthzHandler)
+	ws.handleFunc("/readyz", ws.readyzHandler)
+	ws.handleFunc("/metrics", ws.metricsHandler)
 
 	return ws
 }
 
+//handleFunc registers a handler whose latency is recorded in the nozzle stats under its endpoint
+func (ws *WebServer) handleFunc(pattern string, handler http.HandlerFunc) {
+	endpoint := strings.Trim(pattern, "/")
+	http.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		handler(w, r)
+		stats.GetInstance().ObserveRequest(endpoint, time.Since(start))
+	})
+}
+
 func (ws *WebServer) Start() <-chan error {
 	ws.logger.Infof("Start listening on port %v", ws.config.WebServerPort)
+	ws.server = &http.Server{Addr: fmt.Sprintf(":%v", ws.config.WebServerPort)}
+
 	errors := make(chan error, 1)
 	go func() {
 		defer close(errors)
+		var err error
 		if ws.config.WebServerUseSSL {
-			errors <- http.ListenAndServeTLS(fmt.Sprintf(":%v", ws.config.WebServerPort), getAbsolutePath(ws.config.WebServerCertLocation, ws.logger), getAbsolutePath(ws.config.WebServerKeyLocation, ws.logger), nil)
+			err = ws.server.ListenAndServeTLS(getAbsolutePath(ws.config.WebServerCertLocation, ws.logger), getAbsolutePath(ws.config.WebServerKeyLocation, ws.logger))
 		} else {
-			errors <- http.ListenAndServe(fmt.Sprintf(":%v", ws.config.WebServerPort), nil)
+			err = ws.server.ListenAndServe()
+		}
+
+		if err != http.ErrServerClosed {
+			errors <- err
 		}
 	}()
 	return errors
 }
 
+//Shutdown stops accepting connections and waits for in-flight requests to finish or ctx to expire
+func (ws *WebServer) Shutdown(ctx context.Context) error {
+	if ws.server == nil {
+		return nil
+	}
+
+	ws.logger.Info("Shutting down web server")
+	return ws.server.Shutdown(ctx)
+}
+
+//SetNozzleStatusProvider sets the source of the /nozzle_status endpoint
+func (ws *WebServer) SetNozzleStatusProvider(p NozzleStatusProvider) {
+	ws.Lock()
+	defer ws.Unlock()
+	ws.status = p
+}
+
 func (ws *WebServer) TokenTimeout(token *Token) {
 	ws.Lock()
 	defer ws.Unlock()
@@ -94,6 +169,14 @@ func (ws *WebServer) TokenTimeout(token *Token) {
 	delete(ws.tokens, token.Value)
 }
 
+//apiCredentials are the UAA username and password, or the UAA client when there is no username as with the client_credentials grant
+func (ws *WebServer) apiCredentials() (string, string) {
+	if ws.config.UAAUsername == "" {
+		return ws.config.UAAClientID, ws.config.UAAClientSecret
+	}
+	return ws.config.UAAUsername, ws.config.UAAPassword
+}
+
 /**Handlers**/
 func (ws *WebServer) tokenHandler(w http.ResponseWriter, r *http.Request) {
 	ws.logger.Info("Received /token request")
@@ -108,7 +191,8 @@ func (ws *WebServer) tokenHandler(w http.ResponseWriter, r *http.Request) {
 			io.WriteString(w, "username and/or password not found in header")
 		} else {
 			//Check validity of username and password
-			if username != ws.config.UAAUsername || password != ws.config.UAAPassword {
+			expectedUsername, expectedPassword := ws.apiCredentials()
+			if username != expectedUsername || password != expectedPassword {
 				ws.logger.Debugf("Wrong username and password for user %s", username)
 				w.WriteHeader(http.StatusUnauthorized)
 				io.WriteString(w, "Invalid Username and/or Password")
@@ -257,7 +341,48 @@ func (ws *WebServer) locketsHandler(w http.ResponseWriter, r *http.Request) {
 	ws.processResourceRequest(locketOrigin, w, r)
 }
 
+func (ws *WebServer) logRatesHandler(w http.ResponseWriter, r *http.Request) {
+	ws.logger.Info("Received /log_rates request")
+	ws.processResourceRequest(logRateOrigin, w, r)
+}
+
+func (ws *WebServer) appLatenciesHandler(w http.ResponseWriter, r *http.Request) {
+	ws.logger.Info("Received /app_latencies request")
+	ws.processAuthenticatedRequest(w, r, ws.sendAppLatencyBytes)
+}
+
+func (ws *WebServer) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	ws.logger.Info("Received /events request")
+	ws.processAuthenticatedRequest(w, r, ws.sendEventBytes)
+}
+
+func (ws *WebServer) appsHandler(w http.ResponseWriter, r *http.Request) {
+	ws.logger.Infof("Received %s request", r.URL.Path)
+	ws.processAuthenticatedRequest(w, r, ws.sendAppBytes)
+}
+
+func (ws *WebServer) nozzleStatusHandler(w http.ResponseWriter, r *http.Request) {
+	ws.logger.Info("Received /nozzle_status request")
+	ws.processAuthenticatedRequest(w, r, ws.sendNozzleStatusBytes)
+}
+
+func (ws *WebServer) bluemedoraNozzlesHandler(w http.ResponseWriter, r *http.Request) {
+	ws.logger.Info("Received /bluemedora_nozzles request")
+	ws.processResourceRequest(stats.Origin, w, r)
+}
+
+func (ws *WebServer) internalStatsHandler(w http.ResponseWriter, r *http.Request) {
+	ws.logger.Info("Received /internal/stats request")
+	ws.processAuthenticatedRequest(w, r, ws.sendStatsBytes)
+}
+
 func (ws *WebServer) processResourceRequest(originType string, w http.ResponseWriter, r *http.Request) {
+	ws.processAuthenticatedRequest(w, r, func(w http.ResponseWriter, r *http.Request) {
+		ws.sendOriginBytes(originType, w)
+	})
+}
+
+func (ws *WebServer) processAuthenticatedRequest(w http.ResponseWriter, r *http.Request, handler http.HandlerFunc) {
 	ws.Lock()
 	defer ws.Unlock()
 
@@ -269,7 +394,7 @@ func (ws *WebServer) processResourceRequest(originType string, w http.ResponseWr
 		if token != nil && token.IsValid() {
 			ws.logger.Debugf("Valid token %s supplied", tokenString)
 			token.UseToken()
-			ws.sendOriginBytes(originType, w)
+			handler(w, r)
 		} else {
 			ws.logger.Debugf("Invalid token %s supplied", tokenString)
 			w.WriteHeader(http.StatusUnauthorized)
@@ -300,6 +425,145 @@ func (ws *WebServer) sendOriginBytes(originType string, w http.ResponseWriter) {
 	}
 }
 
+func (ws *WebServer) sendAppLatencyBytes(w http.ResponseWriter, r *http.Request) {
+	var latencies []*applatency.AppLatency
+	if appID := r.URL.Query().Get("app_id"); appID != "" {
+		if latency, ok := applatency.GetInstance().GetApp(appID); ok {
+			latencies = append(latencies, latency)
+		}
+	} else {
+		latencies = applatency.GetInstance().GetApps()
+	}
+
+	var messageBytes []byte
+	if len(latencies) > 0 {
+		w.WriteHeader(http.StatusOK)
+		messageBytes, _ = json.Marshal(latencies)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+		messageBytes = []byte("{}")
+	}
+
+	_, err := w.Write(messageBytes)
+
+	if err != nil {
+		ws.logger.Errorf("Error while answering app latency end point call: %s", err.Error())
+	}
+}
+
+func (ws *WebServer) sendAppBytes(w http.ResponseWriter, r *http.Request) {
+	var messageBytes []byte
+	if appID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/apps"), "/"); appID != "" {
+		if app, ok := appcache.GetInstance().GetApp(appID); ok {
+			w.WriteHeader(http.StatusOK)
+			messageBytes, _ = json.Marshal(app)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+			messageBytes = []byte(fmt.Sprintf("No container metrics found for app %s", appID))
+		}
+	} else if apps := appcache.GetInstance().GetApps(); len(apps) > 0 {
+		w.WriteHeader(http.StatusOK)
+		messageBytes, _ = json.Marshal(apps)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+		messageBytes = []byte("{}")
+	}
+
+	_, err := w.Write(messageBytes)
+
+	if err != nil {
+		ws.logger.Errorf("Error while answering apps end point call: %s", err.Error())
+	}
+}
+
+// private utility func, expected to be called with the mutex locked by processAuthenticatedRequest
+func (ws *WebServer) sendNozzleStatusBytes(w http.ResponseWriter, r *http.Request) {
+	var messageBytes []byte
+	if ws.status != nil {
+		w.WriteHeader(http.StatusOK)
+		messageBytes, _ = json.Marshal(nozzleStatusJSON{
+			Connection:   ws.status.ConnectionStatus(),
+			Backpressure: ws.status.Backpressure(),
+			Streams:      ws.status.Streams(),
+			Token:        ws.status.TokenStatus(),
+		})
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		messageBytes = []byte("Nozzle has not started")
+	}
+
+	_, err := w.Write(messageBytes)
+
+	if err != nil {
+		ws.logger.Errorf("Error while answering nozzle status end point call: %s", err.Error())
+	}
+}
+
+func (ws *WebServer) sendStatsBytes(w http.ResponseWriter, r *http.Request) {
+	messageBytes, _ := json.Marshal(stats.GetInstance().Snapshot())
+	w.WriteHeader(http.StatusOK)
+
+	_, err := w.Write(messageBytes)
+
+	if err != nil {
+		ws.logger.Errorf("Error while answering internal stats end point call: %s", err.Error())
+	}
+}
+
+func (ws *WebServer) sendEventBytes(w http.ResponseWriter, r *http.Request) {
+	since, err := parseQueryInt(r, "since")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, fmt.Sprintf("Invalid since parameter: %s", err.Error()))
+		return
+	}
+
+	limit, err := parseQueryInt(r, "limit")
+	if err != nil || limit < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, fmt.Sprintf("Invalid limit parameter %s", r.URL.Query().Get("limit")))
+		return
+	}
+
+	after, err := parseQueryInt(r, "after")
+	if err != nil || after < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, fmt.Sprintf("Invalid after parameter %s", r.URL.Query().Get("after")))
+		return
+	}
+
+	var events []*eventlog.Event
+	if r.URL.Query().Get("after") != "" {
+		events = eventlog.GetInstance().GetEventsAfter(uint64(after), int(limit))
+	} else {
+		events = eventlog.GetInstance().GetEvents(since, int(limit))
+	}
+
+	var messageBytes []byte
+	if len(events) > 0 {
+		w.WriteHeader(http.StatusOK)
+		messageBytes, _ = json.Marshal(events)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+		messageBytes = []byte("{}")
+	}
+
+	_, err = w.Write(messageBytes)
+
+	if err != nil {
+		ws.logger.Errorf("Error while answering events end point call: %s", err.Error())
+	}
+}
+
+// parseQueryInt returns 0 when the query parameter is not set
+func parseQueryInt(r *http.Request, key string) (int64, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
 const (
 	metronAgentOrigin       = "MetronAgent"
 	syslogDrainBinderOrigin = "syslog_drain_binder"
@@ -326,6 +590,7 @@ const (
 	trafficControllerOrigin = "LoggregatorTrafficController"
 	goRouterOrigin          = "gorouter"
 	locketOrigin            = "locket"
+	logRateOrigin           = "log_rate"
 )
 
 func getValues(resourceMap map[string]*results.Resource) []*results.Resource {
@@ -4,14 +4,25 @@
 package webserver
 
 import (
+	"context"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/appcache"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/applatency"
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/configuration"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/eventlog"
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/logger"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/nozzle"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/prometheus"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/results"
+	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/stats"
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/testhelpers"
 	"github.com/BlueMedoraPublic/bluemedora-firehose-nozzle/ttlcache"
 
@@ -422,6 +433,431 @@ func TestLocketEndpoint(t *testing.T) {
 	endPointTest(t, client, token, config.WebServerPort, locketOrigin, "lockets", server)
 }
 
+func TestBluemedoraNozzleEndpoint(t *testing.T) {
+	if server == nil {
+		t.Fatalf("Server failed to initialize in first test")
+	}
+
+	client := createHTTPClient(t)
+
+	//Retrieve token for other endpoint test
+	token := getToken(t, client, config)
+
+	endPointTest(t, client, token, config.WebServerPort, stats.Origin, "bluemedora_nozzles", server)
+}
+
+func TestAppLatenciesEndpoint(t *testing.T) {
+	if server == nil {
+		t.Fatalf("Server failed to initialize in first test")
+	}
+
+	client := createHTTPClient(t)
+
+	//Retrieve token for other endpoint test
+	token := getToken(t, client, config)
+
+	applatency.GetInstance().UpdateTimer(&loggregator_v2.Envelope{
+		SourceId: "app-guid",
+		Tags: map[string]string{
+			"origin":      goRouterOrigin,
+			"app_id":      "app-guid",
+			"status_code": "200",
+		},
+		Message: &loggregator_v2.Envelope_Timer{
+			Timer: &loggregator_v2.Timer{
+				Name:  "http",
+				Start: time.Now().Add(-time.Second).UnixNano(),
+				Stop:  time.Now().UnixNano(),
+			},
+		},
+	})
+
+	request := createResourceRequest(t, token, config.WebServerPort, "app_latencies")
+
+	t.Logf("Check if server response to valid /app_latencies request... (expecting status code: %v)", http.StatusOK)
+	response, err := client.Do(request)
+
+	if err != nil {
+		t.Fatalf("Error occured while hitting endpoint: %s", err.Error())
+	} else if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting status code %v, but received %v", http.StatusOK, response.StatusCode)
+	}
+	defer response.Body.Close()
+
+	var latencies []map[string]interface{}
+	if err := json.NewDecoder(response.Body).Decode(&latencies); err != nil {
+		t.Fatalf("Error decoding app latencies: %s", err.Error())
+	}
+
+	t.Log("Check if app latencies use lowercase keys...")
+	found := false
+	for _, latency := range latencies {
+		if latency["app_id"] != "app-guid" {
+			continue
+		}
+		found = true
+		if percentiles, ok := latency["latency"].(map[string]interface{}); !ok || percentiles["p99"] == nil {
+			t.Errorf("Expecting a p99 latency, but received %v", latency)
+		}
+		if latency["requests"] == nil || latency["status_codes"] == nil || latency["window_seconds"] == nil {
+			t.Errorf("Expecting requests, status_codes and window_seconds, but received %v", latency)
+		}
+	}
+	if !found {
+		t.Errorf("Expecting app_id app-guid in the app latencies, but received %v", latencies)
+	}
+}
+
+func TestEventsEndpoint(t *testing.T) {
+	if server == nil {
+		t.Fatalf("Server failed to initialize in first test")
+	}
+
+	client := createHTTPClient(t)
+
+	//Retrieve token for other endpoint test
+	token := getToken(t, client, config)
+
+	eventlog.GetInstance().AddEvent(&loggregator_v2.Envelope{
+		Timestamp: time.Now().UnixNano(),
+		SourceId:  "sourceid",
+		Message: &loggregator_v2.Envelope_Event{
+			Event: &loggregator_v2.Event{
+				Title: "title",
+				Body:  "body",
+			},
+		},
+	})
+
+	testCases := []struct {
+		endpoint string
+		want     int
+	}{
+		{endpoint: "events", want: http.StatusOK},
+		{endpoint: "events?since=0&limit=1", want: http.StatusOK},
+		{endpoint: fmt.Sprintf("events?since=%d", time.Now().Add(time.Hour).UnixNano()), want: http.StatusNoContent},
+		{endpoint: "events?after=0&limit=1", want: http.StatusOK},
+		{endpoint: fmt.Sprintf("events?after=%d", uint64(math.MaxInt64)), want: http.StatusNoContent},
+		{endpoint: "events?since=yesterday", want: http.StatusBadRequest},
+		{endpoint: "events?after=-1", want: http.StatusBadRequest},
+		{endpoint: "events?limit=-1", want: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		request := createResourceRequest(t, token, config.WebServerPort, tc.endpoint)
+
+		t.Logf("Check if server response to /%s request... (expecting status code: %v)", tc.endpoint, tc.want)
+		response, err := client.Do(request)
+
+		if err != nil {
+			t.Errorf("Error occured while hitting endpoint: %s", err.Error())
+		} else if response.StatusCode != tc.want {
+			t.Errorf("Expecting status code %v, but received %v", tc.want, response.StatusCode)
+		}
+	}
+}
+
+func TestAppsEndpoint(t *testing.T) {
+	if server == nil {
+		t.Fatalf("Server failed to initialize in first test")
+	}
+
+	client := createHTTPClient(t)
+
+	//Retrieve token for other endpoint test
+	token := getToken(t, client, config)
+
+	appcache.GetInstance().UpdateContainerMetrics(&loggregator_v2.Envelope{
+		Timestamp:  time.Now().UnixNano(),
+		SourceId:   "app-guid",
+		InstanceId: "0",
+		Message: &loggregator_v2.Envelope_Gauge{
+			Gauge: &loggregator_v2.Gauge{
+				Metrics: map[string]*loggregator_v2.GaugeValue{
+					"cpu":    &loggregator_v2.GaugeValue{Unit: "percentage", Value: 1},
+					"memory": &loggregator_v2.GaugeValue{Unit: "bytes", Value: 1},
+					"disk":   &loggregator_v2.GaugeValue{Unit: "bytes", Value: 1},
+				},
+			},
+		},
+	})
+
+	testCases := []struct {
+		endpoint string
+		want     int
+	}{
+		{endpoint: "apps", want: http.StatusOK},
+		{endpoint: "apps/app-guid", want: http.StatusOK},
+		{endpoint: "apps/missing-guid", want: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		request := createResourceRequest(t, token, config.WebServerPort, tc.endpoint)
+
+		t.Logf("Check if server response to /%s request... (expecting status code: %v)", tc.endpoint, tc.want)
+		response, err := client.Do(request)
+
+		if err != nil {
+			t.Errorf("Error occured while hitting endpoint: %s", err.Error())
+		} else if response.StatusCode != tc.want {
+			t.Errorf("Expecting status code %v, but received %v", tc.want, response.StatusCode)
+		}
+	}
+}
+
+type testStatusProvider struct {
+	lastEnvelope time.Time
+}
+
+func (p testStatusProvider) ConnectionStatus() nozzle.ConnectionStatus {
+	return nozzle.ConnectionStatus{State: nozzle.StateConnected}
+}
+
+func (p testStatusProvider) Backpressure() nozzle.BackpressureStatus {
+	return nozzle.BackpressureStatus{Policy: "drop_oldest", BufferSize: 10, Backlog: 10, Dropped: 5}
+}
+
+func (p testStatusProvider) Streams() []nozzle.StreamStatus {
+	return []nozzle.StreamStatus{{ID: 0, Connection: p.ConnectionStatus(), Envelopes: 100, EnvelopesPerSecond: 10}}
+}
+
+func (p testStatusProvider) TokenStatus() nozzle.TokenStatus {
+	return nozzle.TokenStatus{Required: true, Valid: true, Refreshes: 1}
+}
+
+func (p testStatusProvider) LastEnvelope() time.Time {
+	return p.lastEnvelope
+}
+
+func TestNozzleStatusEndpoint(t *testing.T) {
+	if server == nil {
+		t.Fatalf("Server failed to initialize in first test")
+	}
+
+	client := createHTTPClient(t)
+
+	//Retrieve token for other endpoint test
+	token := getToken(t, client, config)
+
+	t.Logf("Check if server response to /nozzle_status before the nozzle starts... (expecting status code: %v)", http.StatusServiceUnavailable)
+	response, err := client.Do(createResourceRequest(t, token, config.WebServerPort, "nozzle_status"))
+
+	if err != nil {
+		t.Errorf("Error occured while hitting endpoint: %s", err.Error())
+	} else if response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expecting status code %v, but received %v", http.StatusServiceUnavailable, response.StatusCode)
+	}
+
+	server.SetNozzleStatusProvider(testStatusProvider{})
+
+	t.Logf("Check if server response to valid /nozzle_status request... (expecting status code: %v)", http.StatusOK)
+	response, err = client.Do(createResourceRequest(t, token, config.WebServerPort, "nozzle_status"))
+
+	if err != nil {
+		t.Errorf("Error occured while hitting endpoint: %s", err.Error())
+	} else if response.StatusCode != http.StatusOK {
+		t.Errorf("Expecting status code %v, but received %v", http.StatusOK, response.StatusCode)
+	}
+}
+
+func TestInternalStatsEndpoint(t *testing.T) {
+	if server == nil {
+		t.Fatalf("Server failed to initialize in first test")
+	}
+
+	client := createHTTPClient(t)
+
+	//Retrieve token for other endpoint test
+	token := getToken(t, client, config)
+
+	t.Logf("Check if server response to valid /internal/stats request... (expecting status code: %v)", http.StatusOK)
+	response, err := client.Do(createResourceRequest(t, token, config.WebServerPort, "internal/stats"))
+
+	if err != nil {
+		t.Fatalf("Error occured while hitting endpoint: %s", err.Error())
+	} else if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting status code %v, but received %v", http.StatusOK, response.StatusCode)
+	}
+	defer response.Body.Close()
+
+	var snapshot stats.Snapshot
+	if err := json.NewDecoder(response.Body).Decode(&snapshot); err != nil {
+		t.Fatalf("Error decoding internal stats: %s", err.Error())
+	}
+
+	t.Log("Check if internal stats include the token endpoint latency...")
+	if r, ok := snapshot.Requests["token"]; !ok || r.Count == 0 {
+		t.Errorf("Expecting /token requests in the internal stats, but received %v", snapshot.Requests)
+	}
+}
+
+func TestHealthzEndpoint(t *testing.T) {
+	if server == nil {
+		t.Fatalf("Server failed to initialize in first test")
+	}
+
+	client := createHTTPClient(t)
+
+	t.Logf("Check if server response to /healthz without a token... (expecting status code: %v)", http.StatusOK)
+	response, err := client.Do(createResourceRequest(t, "", config.WebServerPort, "healthz"))
+
+	if err != nil {
+		t.Errorf("Error occured while hitting endpoint: %s", err.Error())
+	} else if response.StatusCode != http.StatusOK {
+		t.Errorf("Expecting status code %v, but received %v", http.StatusOK, response.StatusCode)
+	}
+}
+
+func TestReadyzEndpoint(t *testing.T) {
+	if server == nil {
+		t.Fatalf("Server failed to initialize in first test")
+	}
+
+	client := createHTTPClient(t)
+	server.SetNozzleStatusProvider(testStatusProvider{})
+
+	t.Logf("Check if server response to /readyz before envelopes are received... (expecting status code: %v)", http.StatusServiceUnavailable)
+	readiness := getReadiness(t, client, http.StatusServiceUnavailable)
+	if readiness.Ready || len(readiness.Checks) != 3 || readiness.Checks[0].Ready {
+		t.Errorf("Expecting the envelopes check to fail, but received %+v", readiness)
+	}
+
+	logRate := &loggregator_v2.Envelope{
+		Timestamp: time.Now().UnixNano(),
+		SourceId:  "app-guid",
+		Tags:      map[string]string{"origin": nozzle.LogRateOrigin},
+		Message: &loggregator_v2.Envelope_Counter{
+			Counter: &loggregator_v2.Counter{Name: "logs.out", Total: 1},
+		},
+	}
+	ttlcache.GetInstance().UpdateResource(logRate)
+	stats.GetInstance().AddEnvelopes([]*loggregator_v2.Envelope{logRate})
+
+	t.Logf("Check if server response to /readyz when only log rate counters arrive... (expecting status code: %v)", http.StatusServiceUnavailable)
+	readiness = getReadiness(t, client, http.StatusServiceUnavailable)
+	if readiness.Ready || readiness.Checks[0].Ready {
+		t.Errorf("Expecting the envelopes check to fail, but received %+v", readiness)
+	}
+
+	cacheEnvelope(goRouterOrigin, server)
+	server.SetNozzleStatusProvider(testStatusProvider{lastEnvelope: time.Now()})
+
+	t.Logf("Check if server response to /readyz once envelopes are cached... (expecting status code: %v)", http.StatusOK)
+	readiness = getReadiness(t, client, http.StatusOK)
+	if !readiness.Ready {
+		t.Errorf("Expecting every check to pass, but received %+v", readiness)
+	}
+}
+
+func TestFoundationResources(t *testing.T) {
+	origins := map[string]map[string]*results.Resource{
+		stats.Origin:         {"source_id | " + stats.SourceID: results.NewSourceResource(stats.SourceID)},
+		nozzle.LogRateOrigin: {"source_id | app-guid": results.NewSourceResource("app-guid")},
+	}
+
+	t.Log("Checking the nozzle's own resources are not counted... (expected value: 0)")
+	if count := foundationResources(origins); count != 0 {
+		t.Errorf("Expecting 0 foundation resources, but received %d", count)
+	}
+
+	origins[goRouterOrigin] = map[string]*results.Resource{"cf | router | 0 | 10.0.0.1": results.NewResource("cf", "router", "0", "10.0.0.1")}
+
+	t.Log("Checking foundation resources are counted... (expected value: 1)")
+	if count := foundationResources(origins); count != 1 {
+		t.Errorf("Expecting 1 foundation resource, but received %d", count)
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	ws := &WebServer{
+		logger: logger.New(defaultLogDirectory, webserverLogFile, webserverLogName, webserverLogLevel),
+		config: &configuration.Configuration{
+			UAAClientID:     "nozzle",
+			UAAClientSecret: "secret",
+			UAAGrantType:    configuration.GrantTypeClientCredentials,
+		},
+		tokens: make(map[string]*Token),
+	}
+
+	t.Logf("Check if a token is given for the UAA client when there is no UAA username... (expecting status code: %v)", http.StatusOK)
+	request := httptest.NewRequest("GET", "/token", nil)
+	request.Header.Set(headerUsernameKey, "nozzle")
+	request.Header.Set(headerPasswordKey, "secret")
+	recorder := httptest.NewRecorder()
+	ws.tokenHandler(recorder, request)
+
+	if recorder.Code != http.StatusOK || recorder.Header().Get(headerTokenKey) == "" {
+		t.Errorf("Expecting status code %v and a token, but received %v", http.StatusOK, recorder.Code)
+	}
+
+	t.Log("Check if /metrics accepts the UAA client for basic authentication... (expecting true)")
+	request = httptest.NewRequest("GET", "/metrics", nil)
+	request.SetBasicAuth("nozzle", "secret")
+	if !ws.prometheusAuthorized(request) {
+		t.Error("Expecting the UAA client to be authorized")
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	if server == nil {
+		t.Fatalf("Server failed to initialize in first test")
+	}
+
+	client := createHTTPClient(t)
+	cacheEnvelope(goRouterOrigin, server)
+
+	t.Logf("Check if server response to /metrics without credentials... (expecting status code: %v)", http.StatusUnauthorized)
+	response, err := client.Do(createResourceRequest(t, "", config.WebServerPort, "metrics"))
+
+	if err != nil {
+		t.Errorf("Error occured while hitting endpoint: %s", err.Error())
+	} else if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expecting status code %v, but received %v", http.StatusUnauthorized, response.StatusCode)
+	}
+
+	t.Logf("Check if server response to /metrics with basic authentication... (expecting status code: %v)", http.StatusOK)
+	request := createResourceRequest(t, "", config.WebServerPort, "metrics")
+	request.SetBasicAuth(config.UAAUsername, config.UAAPassword)
+	response, err = client.Do(request)
+
+	if err != nil {
+		t.Errorf("Error occured while hitting endpoint: %s", err.Error())
+	} else if response.StatusCode != http.StatusOK {
+		t.Errorf("Expecting status code %v, but received %v", http.StatusOK, response.StatusCode)
+	} else if response.Header.Get("Content-Type") != prometheus.TextContentType {
+		t.Errorf("Expecting content type %s, but received %s", prometheus.TextContentType, response.Header.Get("Content-Type"))
+	}
+
+	t.Log("Check if server response to /metrics in OpenMetrics when requested...")
+	request.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	response, err = client.Do(request)
+
+	if err != nil {
+		t.Errorf("Error occured while hitting endpoint: %s", err.Error())
+	} else if response.Header.Get("Content-Type") != prometheus.OpenMetricsContentType {
+		t.Errorf("Expecting content type %s, but received %s", prometheus.OpenMetricsContentType, response.Header.Get("Content-Type"))
+	}
+}
+
+func getReadiness(t *testing.T, client *http.Client, status int) readinessJSON {
+	var readiness readinessJSON
+	response, err := client.Do(createResourceRequest(t, "", config.WebServerPort, "readyz"))
+
+	if err != nil {
+		t.Errorf("Error occured while hitting endpoint: %s", err.Error())
+		return readiness
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != status {
+		t.Errorf("Expecting status code %v, but received %v", status, response.StatusCode)
+	}
+	if err := json.NewDecoder(response.Body).Decode(&readiness); err != nil {
+		t.Errorf("Error decoding readiness: %s", err.Error())
+	}
+	return readiness
+}
+
 func TestTokenTimeout(t *testing.T) {
 	if server == nil {
 		t.Fatalf("Server failed to initialize in first test")
@@ -447,6 +883,28 @@ func TestTokenTimeout(t *testing.T) {
 	}
 }
 
+func TestShutdown(t *testing.T) {
+	if server == nil {
+		t.Fatalf("Server failed to initialize in first test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Log("Shutting down server...")
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("Error occured while shutting down server: %s", err.Error())
+	}
+
+	client := createHTTPClient(t)
+	request := createTokenRequest("GET", config.UAAUsername, config.UAAPassword, config.WebServerPort, t)
+
+	t.Log("Check if server refuses requests after shutdown... (expecting error)")
+	if response, err := client.Do(request); err == nil {
+		t.Errorf("Expected request to fail after shutdown, but received status code %v", response.StatusCode)
+	}
+}
+
 /** Tests **/
 func tokenEndPointTest(t *testing.T, client *http.Client, config *configuration.Configuration) {
 	t.Log("Running token request tests...")
@@ -579,16 +1037,18 @@ func createWebServer(t *testing.T) (*WebServer, *configuration.Configuration) {
 	l := logger.New(defaultLogDirectory, webserverLogFile, webserverLogName, webserverLogLevel)
 
 	cacheLogger := logger.New(defaultLogDirectory, "wsCache.log", "wsCache", webserverLogLevel)
-	ttlcache.CreateInstance(cacheLogger)
+	//Metrics expire quickly so the cleared cache test does not wait out MetricCacheDurationSeconds
+	ttlcache.CreateInstance(cacheLogger, time.Second)
+	applatency.CreateInstance(cacheLogger, time.Minute)
+	eventlog.CreateInstance(cacheLogger, 100)
+	appcache.CreateInstance(cacheLogger, time.Minute)
+	stats.CreateInstance(cacheLogger)
 
 	c, err := configuration.New(defaultConfigLocation, l)
 	if err != nil {
 		t.Fatalf("Error while loading configuration: %s", err.Error())
 	}
 
-	cache := ttlcache.GetInstance()
-	cache.TTL = time.Second
-
 	t.Log("Created webserver")
 	return New(c, l), c
 }